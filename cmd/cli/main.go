@@ -7,9 +7,13 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/acai-travel/tech-challenge/internal/config"
 	"github.com/acai-travel/tech-challenge/internal/pb"
+	"github.com/twitchtv/twirp"
 )
 
 func main() {
@@ -19,6 +23,21 @@ func main() {
 		fmt.Println("  ask        Create a new conversation with assistant or continue an existing one")
 		fmt.Println("  list       List existing conversations")
 		fmt.Println("  show       Show conversation by ID")
+		fmt.Println("  export-ics Export the itinerary and events of a conversation as an .ics file")
+		fmt.Println("  export     Export conversations as JSON, Markdown or JSONL")
+		fmt.Println("  import     Import conversations from a JSON or JSONL file")
+		fmt.Println("  search     Search conversations by title and message content")
+		fmt.Println("  upload     Upload a text, Markdown or PDF document to the knowledge base")
+		fmt.Println("  documents  List the documents of the knowledge base")
+		fmt.Println("  delete-doc Delete a document from the knowledge base")
+		fmt.Println("  memories   List what the assistant remembers about you")
+		fmt.Println("  correct    Change the value of a memory")
+		fmt.Println("  forget     Make the assistant forget a memory")
+		fmt.Println("  tools      List the tools of the assistant, optionally for a conversation")
+		fmt.Println("  enable     Let the assistant use tools in a conversation")
+		fmt.Println("  disable    Stop the assistant from using tools in a conversation")
+		fmt.Println("  approve    Approve an action the assistant is waiting for")
+		fmt.Println("  reject     Reject an action the assistant is waiting for")
 	}
 
 	if len(os.Args) < 2 {
@@ -28,14 +47,25 @@ func main() {
 		os.Exit(-1)
 	}
 
-	url := "http://localhost:8080"
-	if v := os.Getenv("API_URL"); v != "" {
-		url = v
+	// Commands have their own flags, the client is configured by the file of
+	// ACAI_CONFIG and environment variables
+	cfg, err := config.Load(nil, nil)
+	if err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		os.Exit(1)
 	}
 
-	cli := pb.NewChatServiceJSONClient(url, http.DefaultClient)
+	cli := pb.NewChatServiceJSONClient(cfg.Client.APIURL, http.DefaultClient)
 	ctx := context.Background()
 
+	// Conversations are scoped to the user for semantic search and recall
+	if cfg.Client.User != "" {
+		if ctx, err = twirp.WithHTTPRequestHeaders(ctx, http.Header{"X-User-Id": []string{cfg.Client.User}}); err != nil {
+			fmt.Printf("Error setting user header: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	switch os.Args[1] {
 	case "ask":
 		fmt.Println("Press CMD+C to exit.")
@@ -91,7 +121,9 @@ func main() {
 				fmt.Println()
 
 				cid = out.GetConversationId()
-				fmt.Printf("ASSISTANT:\n%s\n\n", out.GetReply())
+				reply, citations := confirmActions(ctx, cli, reader, cid, out.GetReply(), out.GetCitations(), out.GetPendingActions())
+				fmt.Printf("ASSISTANT:\n%s\n\n", reply)
+				printCitations(citations)
 				continue
 			}
 
@@ -105,7 +137,9 @@ func main() {
 				os.Exit(1)
 			}
 
-			fmt.Printf("ASSISTANT:\n%s\n\n", out.GetReply())
+			reply, citations := confirmActions(ctx, cli, reader, cid, out.GetReply(), out.GetCitations(), out.GetPendingActions())
+			fmt.Printf("ASSISTANT:\n%s\n\n", reply)
+			printCitations(citations)
 		}
 
 	case "list":
@@ -146,5 +180,423 @@ func main() {
 		for _, msg := range resp.GetConversation().GetMessages() {
 			fmt.Printf("%s, %s:\n%s\n\n", msg.GetRole(), msg.GetTimestamp().AsTime().Format(time.TimeOnly), msg.GetContent())
 		}
+		printActions(resp.GetConversation().GetPendingActions())
+	case "export-ics":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: Conversation ID is required")
+			os.Exit(1)
+		}
+
+		resp, err := cli.ExportCalendar(ctx, &pb.ExportCalendarRequest{
+			ConversationId: os.Args[2],
+		})
+
+		if err != nil {
+			fmt.Printf("Error exporting calendar: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := os.WriteFile(resp.GetFilename(), []byte(resp.GetContent()), 0o644); err != nil {
+			fmt.Printf("Error writing calendar file: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Calendar saved to", resp.GetFilename())
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		format := fs.String("format", "json", "Export format: json, markdown or jsonl")
+		output := fs.String("o", "", "Output file (defaults to a generated file name)")
+		fs.Usage = func() {
+			fmt.Println("Usage: acai-cli export [-format json|markdown|jsonl] [-o file] [conversation IDs...]")
+			fs.PrintDefaults()
+		}
+		_ = fs.Parse(os.Args[2:])
+
+		resp, err := cli.ExportConversations(ctx, &pb.ExportConversationsRequest{
+			ConversationIds: fs.Args(),
+			Format:          exportFormat(*format),
+		})
+
+		if err != nil {
+			fmt.Printf("Error exporting conversations: %v\n", err)
+			os.Exit(1)
+		}
+
+		file := *output
+		if file == "" {
+			file = resp.GetFilename()
+		}
+
+		if err := os.WriteFile(file, []byte(resp.GetContent()), 0o644); err != nil {
+			fmt.Printf("Error writing export file: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Conversations exported to", file)
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		format := fs.String("format", "json", "Import format: json or jsonl")
+		fs.Usage = func() {
+			fmt.Println("Usage: acai-cli import [-format json|jsonl] <file>")
+			fs.PrintDefaults()
+		}
+		_ = fs.Parse(os.Args[2:])
+
+		if fs.NArg() < 1 {
+			fmt.Println("Error: File to import is required")
+			os.Exit(1)
+		}
+
+		content, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Printf("Error reading import file: %v\n", err)
+			os.Exit(1)
+		}
+
+		resp, err := cli.ImportConversations(ctx, &pb.ImportConversationsRequest{
+			Format:  exportFormat(*format),
+			Content: string(content),
+		})
+
+		if err != nil {
+			fmt.Printf("Error importing conversations: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Imported %d conversation(s):\n", len(resp.GetConversationIds()))
+		for _, id := range resp.GetConversationIds() {
+			fmt.Println(id)
+		}
+	case "search":
+		fs := flag.NewFlagSet("search", flag.ExitOnError)
+		pageSize := fs.Int("n", 10, "Number of results per page")
+		pageToken := fs.String("page", "", "Page token returned by a previous search")
+		semantic := fs.Bool("semantic", false, "Match messages by meaning instead of by words")
+		fs.Usage = func() {
+			fmt.Println("Usage: acai-cli search [-n count] [-page token] [-semantic] <query>")
+			fs.PrintDefaults()
+		}
+		_ = fs.Parse(os.Args[2:])
+
+		if fs.NArg() < 1 {
+			fmt.Println("Error: Search query is required")
+			os.Exit(1)
+		}
+
+		mode := pb.SearchMode_KEYWORD
+		if *semantic {
+			mode = pb.SearchMode_SEMANTIC
+		}
+
+		resp, err := cli.SearchConversations(ctx, &pb.SearchConversationsRequest{
+			Query:     strings.Join(fs.Args(), " "),
+			PageSize:  int32(*pageSize),
+			PageToken: *pageToken,
+			Mode:      mode,
+		})
+
+		if err != nil {
+			fmt.Printf("Error searching conversations: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(resp.GetResults()) == 0 {
+			fmt.Println("No conversations found.")
+			return
+		}
+
+		for _, r := range resp.GetResults() {
+			fmt.Printf("%s   %s\n", r.GetConversation().GetId(), r.GetConversation().GetTitle())
+			for _, m := range r.GetMatches() {
+				fmt.Printf("    %s\n", m.GetSnippet())
+			}
+			fmt.Println()
+		}
+
+		if resp.GetNextPageToken() != "" {
+			fmt.Printf("More results available, use -page %s to see them.\n", resp.GetNextPageToken())
+		}
+
+	case "upload":
+		fs := flag.NewFlagSet("upload", flag.ExitOnError)
+		title := fs.String("title", "", "Document title, defaults to the file name")
+		fs.Usage = func() {
+			fmt.Println("Usage: acai-cli upload [-title title] <file.txt|file.md|file.pdf>")
+			fs.PrintDefaults()
+		}
+		_ = fs.Parse(os.Args[2:])
+
+		if fs.NArg() < 1 {
+			fmt.Println("Error: File to upload is required")
+			os.Exit(1)
+		}
+
+		content, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Printf("Error reading document: %v\n", err)
+			os.Exit(1)
+		}
+
+		resp, err := cli.UploadDocument(ctx, &pb.UploadDocumentRequest{
+			Title:    *title,
+			Filename: filepath.Base(fs.Arg(0)),
+			Content:  content,
+		})
+
+		if err != nil {
+			fmt.Printf("Error uploading document: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Document %s uploaded, split into %d passages.\n", resp.GetDocument().GetId(), resp.GetDocument().GetChunks())
+
+	case "documents":
+		resp, err := cli.ListDocuments(ctx, &pb.ListDocumentsRequest{})
+		if err != nil {
+			fmt.Printf("Error listing documents: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(resp.GetDocuments()) == 0 {
+			fmt.Println("No documents found.")
+			return
+		}
+
+		fmt.Println("ID                         FORMAT     TITLE")
+		for _, d := range resp.GetDocuments() {
+			fmt.Printf("%s   %-8s   %s\n", d.GetId(), strings.ToLower(d.GetFormat().String()), d.GetTitle())
+		}
+
+	case "delete-doc":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: Document ID is required")
+			os.Exit(1)
+		}
+
+		if _, err := cli.DeleteDocument(ctx, &pb.DeleteDocumentRequest{DocumentId: os.Args[2]}); err != nil {
+			fmt.Printf("Error deleting document: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Document deleted.")
+
+	case "memories":
+		resp, err := cli.ListMemories(ctx, &pb.ListMemoriesRequest{})
+		if err != nil {
+			fmt.Printf("Error listing memories: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(resp.GetMemories()) == 0 {
+			fmt.Println("Nothing remembered yet.")
+			return
+		}
+
+		fmt.Println("ID                         KEY                  VALUE")
+		for _, m := range resp.GetMemories() {
+			fmt.Printf("%s   %-18s   %s\n", m.GetId(), m.GetKey(), m.GetValue())
+		}
+
+	case "correct":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: acai-cli correct <memory-id> <value>")
+			os.Exit(1)
+		}
+
+		resp, err := cli.UpdateMemory(ctx, &pb.UpdateMemoryRequest{
+			MemoryId: os.Args[2],
+			Value:    strings.Join(os.Args[3:], " "),
+		})
+
+		if err != nil {
+			fmt.Printf("Error updating memory: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Remembered %s: %s\n", resp.GetMemory().GetKey(), resp.GetMemory().GetValue())
+
+	case "forget":
+		if len(os.Args) < 3 {
+			fmt.Println("Error: Memory ID is required")
+			os.Exit(1)
+		}
+
+		if _, err := cli.DeleteMemory(ctx, &pb.DeleteMemoryRequest{MemoryId: os.Args[2]}); err != nil {
+			fmt.Printf("Error deleting memory: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Memory forgotten.")
+
+	case "tools":
+		req := &pb.ListToolsRequest{}
+		if len(os.Args) >= 3 {
+			req.ConversationId = os.Args[2]
+		}
+
+		resp, err := cli.ListTools(ctx, req)
+		if err != nil {
+			fmt.Printf("Error listing tools: %v\n", err)
+			os.Exit(1)
+		}
+
+		printTools(resp.GetTools())
+
+	case "enable", "disable":
+		if len(os.Args) < 4 {
+			fmt.Printf("Usage: acai-cli %s <conversation-id> <tool>...\n", os.Args[1])
+			os.Exit(1)
+		}
+
+		req := &pb.ConfigureToolsRequest{ConversationId: os.Args[2]}
+		if os.Args[1] == "enable" {
+			req.Enable = os.Args[3:]
+		} else {
+			req.Disable = os.Args[3:]
+		}
+
+		resp, err := cli.ConfigureTools(ctx, req)
+		if err != nil {
+			fmt.Printf("Error configuring tools: %v\n", err)
+			os.Exit(1)
+		}
+
+		printTools(resp.GetTools())
+
+	case "approve":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: acai-cli approve <conversation-id> <action-id>")
+			os.Exit(1)
+		}
+
+		resp, err := cli.ApproveAction(ctx, &pb.ApproveActionRequest{ConversationId: os.Args[2], ActionId: os.Args[3]})
+		if err != nil {
+			fmt.Printf("Error approving action: %v\n", err)
+			os.Exit(1)
+		}
+
+		printDecision(resp.GetReply(), resp.GetCitations(), resp.GetPendingActions())
+
+	case "reject":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: acai-cli reject <conversation-id> <action-id> [reason]")
+			os.Exit(1)
+		}
+
+		resp, err := cli.RejectAction(ctx, &pb.RejectActionRequest{
+			ConversationId: os.Args[2],
+			ActionId:       os.Args[3],
+			Reason:         strings.Join(os.Args[4:], " "),
+		})
+
+		if err != nil {
+			fmt.Printf("Error rejecting action: %v\n", err)
+			os.Exit(1)
+		}
+
+		printDecision(resp.GetReply(), resp.GetCitations(), resp.GetPendingActions())
 	}
 }
+
+// confirmActions asks the user to approve or reject each action the reply
+// waits for, until the assistant replies.
+func confirmActions(ctx context.Context, cli pb.ChatService, reader *bufio.Reader, cid, reply string, citations []*pb.Citation, actions []*pb.PendingAction) (string, []*pb.Citation) {
+	for len(actions) > 0 {
+		action := actions[0]
+		fmt.Printf("The assistant wants to run %s with %s\n", action.GetTool(), action.GetArguments())
+		fmt.Printf("Approve? [y/N]: ")
+
+		line, _, err := reader.ReadLine()
+		if err != nil {
+			fmt.Printf("Error reading input: %v\n", err)
+			os.Exit(1)
+		}
+
+		if answer := strings.ToLower(strings.TrimSpace(string(line))); answer == "y" || answer == "yes" {
+			resp, err := cli.ApproveAction(ctx, &pb.ApproveActionRequest{ConversationId: cid, ActionId: action.GetId()})
+			if err != nil {
+				fmt.Printf("Error approving action: %v\n", err)
+				os.Exit(1)
+			}
+			reply, citations, actions = resp.GetReply(), resp.GetCitations(), resp.GetPendingActions()
+		} else {
+			fmt.Printf("Reason (optional): ")
+			reason, _, err := reader.ReadLine()
+			if err != nil {
+				fmt.Printf("Error reading input: %v\n", err)
+				os.Exit(1)
+			}
+
+			resp, err := cli.RejectAction(ctx, &pb.RejectActionRequest{ConversationId: cid, ActionId: action.GetId(), Reason: string(reason)})
+			if err != nil {
+				fmt.Printf("Error rejecting action: %v\n", err)
+				os.Exit(1)
+			}
+			reply, citations, actions = resp.GetReply(), resp.GetCitations(), resp.GetPendingActions()
+		}
+
+		fmt.Println()
+	}
+
+	return reply, citations
+}
+
+func printDecision(reply string, citations []*pb.Citation, actions []*pb.PendingAction) {
+	if len(actions) > 0 {
+		printActions(actions)
+		return
+	}
+
+	fmt.Printf("ASSISTANT:\n%s\n\n", reply)
+	printCitations(citations)
+}
+
+func printActions(actions []*pb.PendingAction) {
+	if len(actions) == 0 {
+		return
+	}
+
+	fmt.Println("PENDING ACTIONS:")
+	fmt.Println("ID                         TOOL                      ARGUMENTS")
+	for _, a := range actions {
+		fmt.Printf("%s   %-24s  %s\n", a.GetId(), a.GetTool(), a.GetArguments())
+	}
+	fmt.Println()
+}
+
+func printTools(tools []*pb.Tool) {
+	fmt.Println("ENABLED   CONFIRM   TOOL")
+	for _, t := range tools {
+		enabled, confirm := "yes", "no"
+		if !t.GetEnabled() {
+			enabled = "no"
+		}
+		if t.GetRequiresConfirmation() {
+			confirm = "yes"
+		}
+		fmt.Printf("%-7s   %-7s   %s\n", enabled, confirm, t.GetName())
+	}
+}
+
+func printCitations(citations []*pb.Citation) {
+	if len(citations) == 0 {
+		return
+	}
+
+	fmt.Println("SOURCES:")
+	for _, c := range citations {
+		source := c.GetDocumentTitle()
+		if c.GetHeading() != "" {
+			source += ", " + c.GetHeading()
+		}
+		if c.GetPage() > 0 {
+			source += fmt.Sprintf(", page %d", c.GetPage())
+		}
+		fmt.Printf("[%d] %s\n", c.GetMarker(), source)
+	}
+	fmt.Println()
+}
+
+func exportFormat(name string) pb.ExportFormat {
+	return pb.ExportFormat(pb.ExportFormat_value[strings.ToUpper(name)])
+}
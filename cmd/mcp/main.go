@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/acai-travel/tech-challenge/internal/chat"
+	"github.com/acai-travel/tech-challenge/internal/chat/assistant"
+	"github.com/acai-travel/tech-challenge/internal/chat/llm"
+	"github.com/acai-travel/tech-challenge/internal/chat/mcpserver"
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"github.com/acai-travel/tech-challenge/internal/chat/tool"
+	"github.com/acai-travel/tech-challenge/internal/chat/toolcache"
+	"github.com/acai-travel/tech-challenge/internal/config"
+	"github.com/acai-travel/tech-challenge/internal/httpx"
+	"github.com/acai-travel/tech-challenge/internal/mongox"
+	"github.com/acai-travel/tech-challenge/internal/pii"
+	"github.com/openai/openai-go/v2"
+)
+
+func main() {
+	addr := flag.String("http", "", "serve streamable HTTP on this address, e.g. :8090, instead of stdio")
+	printConfig := flag.Bool("print-config", false, "print the configuration, with secrets redacted, and exit")
+
+	// Stdout carries the protocol over stdio, logs go to stderr
+	slog.SetDefault(slog.New(httpx.LogHandler(slog.NewTextHandler(os.Stderr, nil))))
+
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(2)
+	}
+
+	redactor := cfg.PII.Redactor()
+	if cfg.PII.Logs {
+		slog.SetDefault(slog.New(pii.LogHandler(slog.Default().Handler(), redactor)))
+	}
+
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			slog.Error("Failed to print configuration", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	openaiClient := openai.NewClient(cfg.OpenAI.ClientOptions()...)
+	weather := assistant.NewWeatherClient(cfg.Tools.WeatherAPIKey)
+
+	db, err := mongox.Connect(ctx, cfg.Mongo.URI, cfg.Mongo.Database, cfg.Mongo.ConnectOptions()...)
+	if err != nil {
+		slog.Error("Failed to connect to MongoDB", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = db.Client().Disconnect(context.Background())
+	}()
+
+	// The MCP server can run against a database the chat server never
+	// started on, e.g. to keep one itinerary per conversation
+	repo := model.New(db)
+	if err := repo.EnsureIndexes(ctx); err != nil {
+		slog.Error("Failed to create database indexes", "error", err)
+		os.Exit(1)
+	}
+
+	opts := []assistant.Option{
+		assistant.WithLLM(llm.NewOpenAI(openaiClient)),
+		assistant.WithReplyModels(cfg.OpenAI.ReplyModels...),
+		assistant.WithTitleModels(cfg.OpenAI.TitleModels...),
+		assistant.WithWeatherClient(weather),
+		assistant.WithHolidayCalendar(cfg.Tools.HolidayCalendar),
+		assistant.WithTravelSpeeds(cfg.Tools.Speeds()),
+	}
+	if cfg.PII.LLM {
+		opts = append(opts, assistant.WithRedactor(redactor))
+	}
+
+	server := chat.NewServer(repo, assistant.New(repo, nil, nil, opts...))
+
+	// Travel tools that don't depend on a conversation, with their results
+	// cached like in the assistant
+	cache := toolcache.New(toolcache.NewLRU(toolcache.DefaultSize))
+	mcpServer, err := mcpserver.New(server,
+		cache.Wrap(registry.Typed[tool.DateArgs](tool.NewDateTool())),
+		cache.Wrap(registry.Typed[tool.HolidaysArgs](tool.NewHolidaysTool(cfg.Tools.HolidayCalendar))),
+		cache.Wrap(registry.Typed[tool.WeatherArgs](tool.NewWeatherTool(weather))),
+		cache.Wrap(registry.Typed[tool.DistanceArgs](tool.NewDistanceTool(cfg.Tools.Speeds()))),
+	)
+
+	if err != nil {
+		slog.Error("Failed to create MCP server", "error", err)
+		os.Exit(1)
+	}
+
+	if *addr == "" {
+		// Over stdio, the user of the conversations is set with client.user
+		if cfg.Client.User != "" {
+			ctx = httpx.WithUser(ctx, cfg.Client.User)
+		}
+
+		if err := mcpServer.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil {
+			slog.Error("MCP server error", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: httpx.RequestID()(httpx.Logger()(httpx.Recovery()(httpx.User()(mcpServer)))),
+	}
+
+	go func() {
+		slog.Info("Starting the MCP server on " + *addr + "...")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server error", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("Shutting down MCP server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown error", "error", err)
+	}
+}
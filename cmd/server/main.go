@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -12,20 +13,48 @@ import (
 
 	"github.com/acai-travel/tech-challenge/internal/chat"
 	"github.com/acai-travel/tech-challenge/internal/chat/assistant"
+	"github.com/acai-travel/tech-challenge/internal/chat/embedding"
+	"github.com/acai-travel/tech-challenge/internal/chat/knowledge"
+	"github.com/acai-travel/tech-challenge/internal/chat/llm"
 	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/recall"
+	"github.com/acai-travel/tech-challenge/internal/chat/retention"
+	"github.com/acai-travel/tech-challenge/internal/chat/toolcache"
+	"github.com/acai-travel/tech-challenge/internal/config"
+	"github.com/acai-travel/tech-challenge/internal/health"
 	"github.com/acai-travel/tech-challenge/internal/httpx"
+	"github.com/acai-travel/tech-challenge/internal/mcp"
 	"github.com/acai-travel/tech-challenge/internal/mongox"
 	"github.com/acai-travel/tech-challenge/internal/pb"
+	"github.com/acai-travel/tech-challenge/internal/pii"
 	"github.com/acai-travel/tech-challenge/internal/telemetry"
+	"github.com/acai-travel/tech-challenge/internal/vector"
 	"github.com/gorilla/mux"
+	"github.com/openai/openai-go/v2"
 	"github.com/twitchtv/twirp"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 func main() {
 	ctx := context.Background()
 
+	printConfig := flag.Bool("print-config", false, "print the configuration, with secrets redacted, and exit")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(2)
+	}
+
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			slog.Error("Failed to print configuration", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize OpenTelemetry metrics
-	shutdownMetrics, err := telemetry.InitMetrics(ctx)
+	metricsHandler, shutdownMetrics, err := telemetry.InitMetrics(ctx, cfg.Telemetry.MetricsExporter)
 	if err != nil {
 		slog.Error("Failed to initialize metrics", "error", err)
 		os.Exit(1)
@@ -38,14 +67,170 @@ func main() {
 		}
 	}()
 
-	// Initialize dependencies
-	mongo := mongox.MustConnect()
+	// Initialize OpenTelemetry tracing, logs carry the IDs of the current span
+	// and request
+	shutdownTracing, err := telemetry.InitTracing(ctx, cfg.Telemetry.TracesExporter)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to shutdown tracing", "error", err)
+		}
+	}()
+	logHandler := httpx.LogHandler(telemetry.LogHandler(slog.Default().Handler()))
+
+	// Personal data is masked in logs, and replaced by placeholders in what
+	// is sent to the models
+	redactor := cfg.PII.Redactor()
+	if cfg.PII.Logs {
+		logHandler = pii.LogHandler(logHandler, redactor)
+	}
+	slog.SetDefault(slog.New(logHandler))
+
+	// Initialize dependencies, the server doesn't start without MongoDB
+	mongo, err := mongox.Connect(ctx, cfg.Mongo.URI, cfg.Mongo.Database, cfg.Mongo.ConnectOptions()...)
+	if err != nil {
+		slog.Error("Failed to connect to MongoDB", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := mongo.Client().Disconnect(ctx); err != nil {
+			slog.Error("Failed to disconnect from MongoDB", "error", err)
+		}
+	}()
+
+	openaiClient := openai.NewClient(cfg.OpenAI.ClientOptions()...)
 	repo := model.New(mongo)
-	assist := assistant.New()
-	server := chat.NewServer(repo, assist)
+	if err := repo.EnsureIndexes(ctx); err != nil {
+		slog.Error("Failed to create database indexes", "error", err)
+		os.Exit(1)
+	}
+
+	// Messages and documents are embedded into an in-process vector store,
+	// which starts empty, so existing ones are indexed in the background
+	var embedder embedding.Embedder = embedding.NewOpenAI(openaiClient, cfg.OpenAI.EmbeddingModel)
+	if cfg.PII.LLM {
+		embedder = embedding.Redacted(embedder, redactor)
+	}
+	vectors := vector.NewMemoryStore()
+	index := recall.NewIndex(embedder, vectors)
+	kb := knowledge.New(repo, embedder, vectors)
+
+	go func() {
+		conversations, err := repo.ListConversations(ctx)
+		if err == nil {
+			err = index.IndexConversations(ctx, conversations)
+		}
+
+		if err != nil {
+			slog.Error("Failed to index existing conversations", "error", err)
+			return
+		}
+
+		slog.Info("Indexed existing conversations", "count", len(conversations))
+	}()
+
+	go func() {
+		count, err := kb.Reindex(ctx)
+		if err != nil {
+			slog.Error("Failed to index some knowledge base documents", "error", err)
+		}
+
+		slog.Info("Indexed knowledge base documents", "count", count)
+	}()
+
+	opts := []assistant.Option{
+		assistant.WithLLM(llm.NewOpenAI(openaiClient)),
+		assistant.WithReplyModels(cfg.OpenAI.ReplyModels...),
+		assistant.WithTitleModels(cfg.OpenAI.TitleModels...),
+		assistant.WithWeatherClient(assistant.NewWeatherClient(cfg.Tools.WeatherAPIKey)),
+		assistant.WithHolidayCalendar(cfg.Tools.HolidayCalendar),
+		assistant.WithTravelSpeeds(cfg.Tools.Speeds()),
+	}
+
+	if cfg.PII.LLM {
+		opts = append(opts, assistant.WithRedactor(redactor))
+	}
+
+	// Tool results are cached in process, in MongoDB to share them between
+	// instances, or not at all
+	switch cfg.Tools.Cache {
+	case config.CacheMongo:
+		store := toolcache.NewMongoStore(mongo)
+		if err := store.EnsureIndexes(ctx); err != nil {
+			slog.Error("Failed to create tool cache indexes", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, assistant.WithToolCache(toolcache.New(store)))
+	case config.CacheOff:
+		opts = append(opts, assistant.WithToolCache(nil))
+	}
+
+	assist := assistant.New(repo, index, kb, opts...)
+
+	// Tools of external MCP servers, configured in the file of mcp.config.
+	// Servers that can't be reached are skipped.
+	if cfg.MCP.Config != "" {
+		servers, err := mcp.LoadConfig(cfg.MCP.Config)
+		if err != nil {
+			slog.Error("Failed to load MCP config", "error", err)
+			os.Exit(1)
+		}
+
+		for _, sc := range servers {
+			client, err := mcp.Connect(ctx, sc)
+			if err == nil {
+				defer client.Close()
+
+				var tools []string
+				if tools, err = assist.MountMCP(ctx, client); err == nil {
+					slog.Info("Mounted MCP server", "server", sc.Name, "tools", tools)
+					continue
+				}
+			}
+
+			slog.Error("Failed to mount MCP server", "server", sc.Name, "error", err)
+		}
+	}
+
+	// Conversations are deleted once expired by the retention policy, by a
+	// background worker and optionally by a TTL index, always ensured so it
+	// is dropped when disabled
+	policy, err := cfg.Retention.Policy()
+	if err != nil {
+		slog.Error("Invalid retention policy", "error", err)
+		os.Exit(1)
+	}
+
+	var ttl time.Duration
+	if cfg.Retention.TTLIndex {
+		ttl = policy.MaxAge
+	}
+	if err := repo.EnsureRetentionIndex(ctx, ttl); err != nil {
+		slog.Error("Failed to configure the retention index", "error", err)
+		os.Exit(1)
+	}
+
+	serverOpts := []chat.Option{chat.WithRecall(index), chat.WithKnowledgeBase(kb), chat.WithAdmins(cfg.Server.Admins...)}
+	if policy.Enabled() {
+		purger := retention.New(repo, policy, retention.WithRecall(index))
+		serverOpts = append(serverOpts, chat.WithRetention(purger))
+
+		if cfg.Retention.PurgeInterval > 0 {
+			go purger.Run(ctx, cfg.Retention.PurgeInterval, cfg.Retention.DryRun)
+		}
+	}
+
+	server := chat.NewServer(repo, assist, serverOpts...)
 
 	// Create metrics middleware
-	metricsMiddleware, err := httpx.NewMetricsMiddleware()
+	metricsMiddleware, err := httpx.NewMetricsMiddleware(httpx.WithExcludedPaths(telemetry.MetricsPath, "/healthz", "/readyz"))
 	if err != nil {
 		slog.Error("Failed to create metrics middleware", "error", err)
 		os.Exit(1)
@@ -55,21 +240,67 @@ func main() {
 	handler := mux.NewRouter()
 	handler.Use(
 		metricsMiddleware.Handler(), // Add metrics FIRST
+		httpx.RequestID(),
+		httpx.Tracing(),
 		httpx.Logger(),
 		httpx.Recovery(),
+		httpx.User(),
 	)
 
+	// Requests no route matches are measured under the unmatched route, see
+	// httpx.Route
+	unmatched := metricsMiddleware.Handler()
+	handler.NotFoundHandler = unmatched(http.NotFoundHandler())
+	handler.MethodNotAllowedHandler = unmatched(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}))
+
+	// Probes of the orchestrator, see checks
+	checks := health.New()
+	checks.Critical("mongo", func(ctx context.Context) error {
+		return mongo.Client().Ping(ctx, readpref.Primary())
+	})
+	checks.Critical("llm", health.Configured("openai.api_key", cfg.OpenAI.APIKey))
+
+	// External APIs of tools only fail some replies, they are checked once a
+	// minute at most
+	if cfg.Tools.WeatherAPIKey != "" {
+		checks.Optional("weather", health.Cached(health.Reachable(http.DefaultClient, assistant.WeatherAPIURL), time.Minute))
+	}
+	checks.Optional("holidays", health.Cached(health.Reachable(http.DefaultClient, cfg.Tools.HolidayCalendar), time.Minute))
+
+	handler.Handle("/healthz", checks.Liveness()).Methods(http.MethodGet)
+	handler.Handle("/readyz", checks.Readiness()).Methods(http.MethodGet)
+
 	handler.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = fmt.Fprint(w, "Hi, my name is Clippy!")
 	})
 
+	// Scraped by Prometheus, unless metrics are pushed
+	if metricsHandler != nil {
+		handler.Handle(telemetry.MetricsPath, metricsHandler).Methods(http.MethodGet)
+	}
+
+	handler.Handle("/conversations/{id}/calendar.ics", server.CalendarHandler()).Methods(http.MethodGet)
+
+	// Twirp requests share a route, their method and error code are measured
+	// by hooks
+	twirpMetrics, err := telemetry.TwirpMetrics()
+	if err != nil {
+		slog.Error("Failed to create twirp metrics", "error", err)
+		os.Exit(1)
+	}
+
 	handler.PathPrefix("/twirp/").Handler(
-		pb.NewChatServiceServer(server, twirp.WithServerJSONSkipDefaults(true)),
+		pb.NewChatServiceServer(server,
+			twirp.WithServerJSONSkipDefaults(true),
+			twirp.WithServerHooks(twirp.ChainHooks(telemetry.TwirpTracing(), twirpMetrics)),
+		),
 	)
 
 	// Start server with graceful shutdown
 	srv := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.Server.Addr,
 		Handler: handler,
 	}
 
@@ -79,7 +310,7 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		slog.Info("Starting the server on :8080...")
+		slog.Info("Starting the server on " + cfg.Server.Addr + "...")
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			slog.Error("Server error", "error", err)
 			os.Exit(1)
@@ -90,8 +321,12 @@ func main() {
 	<-stop
 	slog.Info("Shutting down server...")
 
+	// Not ready anymore, so no new requests are routed here
+	checks.Drain()
+	time.Sleep(cfg.Server.DrainDelay)
+
 	// Graceful shutdown with timeout
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
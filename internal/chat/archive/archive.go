@@ -0,0 +1,324 @@
+// Package archive converts conversations to and from portable file formats.
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Format string
+
+const (
+	// FormatJSON is a full-fidelity dump of the conversations, suitable for backups.
+	FormatJSON Format = "json"
+
+	// FormatMarkdown is a human readable transcript. It can't be imported.
+	FormatMarkdown Format = "markdown"
+
+	// FormatJSONL is the OpenAI fine-tuning format, one conversation per line.
+	FormatJSONL Format = "jsonl"
+)
+
+func (f Format) Extension() string {
+	switch f {
+	case FormatMarkdown:
+		return ".md"
+	case FormatJSONL:
+		return ".jsonl"
+	default:
+		return ".json"
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatJSONL:
+		return "application/jsonl"
+	default:
+		return "application/json"
+	}
+}
+
+// document is the envelope of the JSON format.
+type document struct {
+	Version       int                   `json:"version"`
+	ExportedAt    time.Time             `json:"exported_at"`
+	Conversations []*model.Conversation `json:"conversations"`
+}
+
+const documentVersion = 1
+
+// fineTuningExample is a line of the OpenAI fine-tuning JSONL format.
+type fineTuningExample struct {
+	Messages []fineTuningMessage `json:"messages"`
+}
+
+type fineTuningMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Encode writes the conversations to w in the given format.
+func Encode(w io.Writer, format Format, convs []*model.Conversation) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(document{Version: documentVersion, ExportedAt: time.Now().UTC(), Conversations: convs})
+
+	case FormatMarkdown:
+		return encodeMarkdown(w, convs)
+
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, c := range convs {
+			example := fineTuningExample{}
+			for _, m := range c.Messages {
+				example.Messages = append(example.Messages, fineTuningMessage{Role: string(m.Role), Content: m.Content})
+			}
+			if err := enc.Encode(example); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func encodeMarkdown(w io.Writer, convs []*model.Conversation) error {
+	bw := bufio.NewWriter(w)
+
+	for i, c := range convs {
+		if i > 0 {
+			fmt.Fprint(bw, "\n---\n\n")
+		}
+
+		fmt.Fprintf(bw, "# %s\n\n", c.Title)
+		fmt.Fprintf(bw, "- ID: `%s`\n", c.ID.Hex())
+		fmt.Fprintf(bw, "- Started: %s\n", c.CreatedAt.UTC().Format(time.RFC1123))
+		fmt.Fprintf(bw, "- Updated: %s\n", c.UpdatedAt.UTC().Format(time.RFC1123))
+
+		for _, m := range c.Messages {
+			role := string(m.Role)
+			if role != "" {
+				role = strings.ToUpper(role[:1]) + role[1:]
+			}
+
+			fmt.Fprintf(bw, "\n## %s, %s\n\n%s\n", role, m.CreatedAt.UTC().Format(time.DateTime), m.Content)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Decode reads conversations in the given format and validates them. IDs and
+// timestamps of the JSON format are preserved, while conversations imported
+// from JSONL get new IDs and the current time.
+func Decode(r io.Reader, format Format) ([]*model.Conversation, error) {
+	var convs []*model.Conversation
+
+	switch format {
+	case FormatJSON:
+		var doc document
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON document: %w", err)
+		}
+		if doc.Version != documentVersion {
+			return nil, fmt.Errorf("unsupported document version %d", doc.Version)
+		}
+		convs = doc.Conversations
+
+	case FormatJSONL:
+		var err error
+		if convs, err = decodeJSONL(r); err != nil {
+			return nil, err
+		}
+
+	case FormatMarkdown:
+		return nil, errors.New("markdown transcripts can't be imported, use the JSON format instead")
+
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	if len(convs) == 0 {
+		return nil, errors.New("no conversations found")
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	for i, c := range convs {
+		if err := normalize(c); err != nil {
+			return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+		}
+		if seen[c.ID] {
+			return nil, fmt.Errorf("conversation %d: duplicate ID %s", i+1, c.ID.Hex())
+		}
+		seen[c.ID] = true
+	}
+
+	return convs, nil
+}
+
+func decodeJSONL(r io.Reader) ([]*model.Conversation, error) {
+	var convs []*model.Conversation
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var example fineTuningExample
+		if err := json.Unmarshal(scanner.Bytes(), &example); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", line, err)
+		}
+
+		now := time.Now()
+		c := &model.Conversation{ID: primitive.NewObjectID(), CreatedAt: now, UpdatedAt: now}
+
+		for _, m := range example.Messages {
+			// System prompts are part of the assistant, not of the conversation
+			if m.Role == "system" {
+				continue
+			}
+
+			c.Messages = append(c.Messages, &model.Message{
+				ID:        primitive.NewObjectID(),
+				Role:      model.Role(m.Role),
+				Content:   m.Content,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+		}
+
+		convs = append(convs, c)
+	}
+
+	return convs, scanner.Err()
+}
+
+// normalize validates an imported conversation and fills in what can be
+// derived: missing IDs, message update times and the title. Pending replies
+// are dropped, actions confirmed in another deployment must not run here.
+func normalize(c *model.Conversation) error {
+	if c == nil {
+		return errors.New("conversation is empty")
+	}
+
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+
+	if c.CreatedAt.IsZero() || c.UpdatedAt.IsZero() {
+		return errors.New("created_at and updated_at are required")
+	}
+
+	if c.UpdatedAt.Before(c.CreatedAt) {
+		return errors.New("updated_at is before created_at")
+	}
+
+	if len(c.Messages) == 0 {
+		return errors.New("conversation has no messages")
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	for i, m := range c.Messages {
+		if m == nil {
+			return fmt.Errorf("message %d is empty", i+1)
+		}
+		if !m.Role.Valid() {
+			return fmt.Errorf("message %d: invalid role %q", i+1, m.Role)
+		}
+		if strings.TrimSpace(m.Content) == "" {
+			return fmt.Errorf("message %d: content is required", i+1)
+		}
+		if m.CreatedAt.IsZero() {
+			return fmt.Errorf("message %d: created_at is required", i+1)
+		}
+
+		if m.ID.IsZero() {
+			m.ID = primitive.NewObjectID()
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("message %d: duplicate ID %s", i+1, m.ID.Hex())
+		}
+		seen[m.ID] = true
+
+		if m.UpdatedAt.IsZero() {
+			m.UpdatedAt = m.CreatedAt
+		}
+	}
+
+	if err := normalizeEvents(c.Events); err != nil {
+		return err
+	}
+
+	tools := make([]string, 0, len(c.DisabledTools))
+	for i, name := range c.DisabledTools {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return fmt.Errorf("disabled tool %d: name is required", i+1)
+		}
+		tools = append(tools, name)
+	}
+	slices.Sort(tools)
+	c.DisabledTools = slices.Compact(tools)
+
+	c.Pending = nil
+
+	if strings.TrimSpace(c.Title) == "" {
+		c.Title = "Imported conversation"
+	}
+
+	return nil
+}
+
+func normalizeEvents(events []*model.Event) error {
+	seen := map[primitive.ObjectID]bool{}
+	for i, e := range events {
+		if e == nil {
+			return fmt.Errorf("event %d is empty", i+1)
+		}
+		if strings.TrimSpace(e.Title) == "" {
+			return fmt.Errorf("event %d: title is required", i+1)
+		}
+		if e.Start.IsZero() || e.End.IsZero() {
+			return fmt.Errorf("event %d: start and end are required", i+1)
+		}
+		if !e.End.After(e.Start) {
+			return fmt.Errorf("event %d: end must be after start", i+1)
+		}
+		if e.TimeZone != "" {
+			if _, err := time.LoadLocation(e.TimeZone); err != nil {
+				return fmt.Errorf("event %d: invalid time_zone %q", i+1, e.TimeZone)
+			}
+		}
+
+		if e.ID.IsZero() {
+			e.ID = primitive.NewObjectID()
+		}
+		if seen[e.ID] {
+			return fmt.Errorf("event %d: duplicate ID %s", i+1, e.ID.Hex())
+		}
+		seen[e.ID] = true
+	}
+
+	return nil
+}
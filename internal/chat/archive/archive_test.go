@@ -0,0 +1,229 @@
+package archive
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testConversation() *model.Conversation {
+	created := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	return &model.Conversation{
+		ID:        primitive.NewObjectID(),
+		Title:     "Weekend in Lisbon",
+		CreatedAt: created,
+		UpdatedAt: created.Add(time.Minute),
+		Messages: []*model.Message{
+			{ID: primitive.NewObjectID(), Role: model.RoleUser, Content: "Plan a weekend in Lisbon", CreatedAt: created, UpdatedAt: created},
+			{ID: primitive.NewObjectID(), Role: model.RoleAssistant, Content: "Sure, here is a plan.", CreatedAt: created.Add(time.Minute), UpdatedAt: created.Add(time.Minute)},
+		},
+		Events: []*model.Event{{
+			ID:       primitive.NewObjectID(),
+			Title:    "Flight BCN-LIS",
+			Start:    created.Add(24 * time.Hour),
+			End:      created.Add(26 * time.Hour),
+			TimeZone: "Europe/Madrid",
+		}},
+		DisabledTools: []string{"get_weather"},
+	}
+}
+
+func TestEncodeDecode_JSON(t *testing.T) {
+	c := testConversation()
+	c.Pending = &model.PendingReply{}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, FormatJSON, []*model.Conversation{c}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := Decode(&buf, FormatJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("got %d conversations, want 1", len(got))
+	}
+
+	g := got[0]
+	if g.ID != c.ID || g.Title != c.Title || !g.CreatedAt.Equal(c.CreatedAt) || !g.UpdatedAt.Equal(c.UpdatedAt) {
+		t.Errorf("got conversation %+v, want %+v", g, c)
+	}
+	if len(g.Messages) != 2 || g.Messages[0].ID != c.Messages[0].ID || g.Messages[1].Content != c.Messages[1].Content {
+		t.Errorf("unexpected messages: %+v", g.Messages)
+	}
+	if len(g.Events) != 1 || g.Events[0].ID != c.Events[0].ID || !g.Events[0].Start.Equal(c.Events[0].Start) {
+		t.Errorf("unexpected events: %+v", g.Events)
+	}
+	if len(g.DisabledTools) != 1 || g.DisabledTools[0] != "get_weather" {
+		t.Errorf("unexpected disabled tools: %v", g.DisabledTools)
+	}
+	if g.Pending != nil {
+		t.Errorf("expected pending reply to be dropped, got %+v", g.Pending)
+	}
+}
+
+func TestEncodeDecode_JSONL(t *testing.T) {
+	c := testConversation()
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, FormatJSONL, []*model.Conversation{c, testConversation()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", lines, buf.String())
+	}
+
+	got, err := Decode(&buf, FormatJSONL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d conversations, want 2", len(got))
+	}
+
+	// JSONL only carries the messages, the rest is generated on import
+	g := got[0]
+	if g.ID.IsZero() || g.ID == c.ID {
+		t.Errorf("expected a new conversation ID, got %s", g.ID.Hex())
+	}
+	if g.Title != "Imported conversation" {
+		t.Errorf("got title %q, want %q", g.Title, "Imported conversation")
+	}
+	if len(g.Messages) != 2 || g.Messages[0].Role != model.RoleUser || g.Messages[1].Content != c.Messages[1].Content {
+		t.Errorf("unexpected messages: %+v", g.Messages)
+	}
+}
+
+func TestEncode_Markdown(t *testing.T) {
+	c := testConversation()
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, FormatMarkdown, []*model.Conversation{c, testConversation()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"# Weekend in Lisbon\n",
+		"- ID: `" + c.ID.Hex() + "`\n",
+		"- Started: Sat, 01 Mar 2025 10:00:00 UTC\n",
+		"## User, 2025-03-01 10:00:00\n\nPlan a weekend in Lisbon\n",
+		"## Assistant, 2025-03-01 10:01:00\n\nSure, here is a plan.\n",
+		"\n---\n\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestDecode_Rejects(t *testing.T) {
+	encode := func(t *testing.T, convs ...*model.Conversation) string {
+		var buf bytes.Buffer
+		if err := Encode(&buf, FormatJSON, convs); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return buf.String()
+	}
+
+	tests := []struct {
+		name    string
+		format  Format
+		content func(t *testing.T) string
+		want    string
+	}{
+		{
+			name:    "markdown",
+			format:  FormatMarkdown,
+			content: func(t *testing.T) string { return "# Weekend in Lisbon" },
+			want:    "can't be imported",
+		},
+		{
+			name:    "unsupported version",
+			format:  FormatJSON,
+			content: func(t *testing.T) string { return `{"version": 2, "conversations": []}` },
+			want:    "unsupported document version 2",
+		},
+		{
+			name:    "unknown fields",
+			format:  FormatJSON,
+			content: func(t *testing.T) string { return `{"version": 1, "owner": "someone"}` },
+			want:    "invalid JSON document",
+		},
+		{
+			name:    "no conversations",
+			format:  FormatJSON,
+			content: func(t *testing.T) string { return `{"version": 1, "conversations": []}` },
+			want:    "no conversations found",
+		},
+		{
+			name:    "invalid role",
+			format:  FormatJSONL,
+			content: func(t *testing.T) string { return `{"messages":[{"role":"robot","content":"Beep"}]}` },
+			want:    `invalid role "robot"`,
+		},
+		{
+			name:    "empty content",
+			format:  FormatJSONL,
+			content: func(t *testing.T) string { return `{"messages":[{"role":"user","content":"  "}]}` },
+			want:    "content is required",
+		},
+		{
+			name:   "duplicate conversation IDs",
+			format: FormatJSON,
+			content: func(t *testing.T) string {
+				c := testConversation()
+				return encode(t, c, c)
+			},
+			want: "duplicate ID",
+		},
+		{
+			name:   "event ending before it starts",
+			format: FormatJSON,
+			content: func(t *testing.T) string {
+				c := testConversation()
+				c.Events[0].End = c.Events[0].Start.Add(-time.Hour)
+				return encode(t, c)
+			},
+			want: "event 1: end must be after start",
+		},
+		{
+			name:   "event with an invalid time zone",
+			format: FormatJSON,
+			content: func(t *testing.T) string {
+				c := testConversation()
+				c.Events[0].TimeZone = "Mars/Olympus_Mons"
+				return encode(t, c)
+			},
+			want: `event 1: invalid time_zone "Mars/Olympus_Mons"`,
+		},
+		{
+			name:   "empty disabled tool",
+			format: FormatJSON,
+			content: func(t *testing.T) string {
+				c := testConversation()
+				c.DisabledTools = []string{" "}
+				return encode(t, c)
+			},
+			want: "disabled tool 1: name is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.content(t)), tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
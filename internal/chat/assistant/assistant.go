@@ -5,68 +5,138 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/acai-travel/tech-challenge/internal/chat/knowledge"
+	"github.com/acai-travel/tech-challenge/internal/chat/llm"
 	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/recall"
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
 	"github.com/acai-travel/tech-challenge/internal/chat/tool"
+	"github.com/acai-travel/tech-challenge/internal/chat/toolcache"
+	"github.com/acai-travel/tech-challenge/internal/httpx"
+	"github.com/acai-travel/tech-challenge/internal/pii"
 	"github.com/openai/openai-go/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric/noop"
 )
 
-type Tool interface {
-	Name() string
-	Description() string
-	Parameters() openai.FunctionParameters
-	Execute(ctx context.Context, arguments string) (string, error)
-}
-
-type Registry struct {
-	tools map[string]Tool
-}
+// Tool is a function the model can call, see registry.Tool.
+type Tool = registry.Tool
 
 type Assistant struct {
-	cli   openai.Client
-	tools map[string]Tool
+	llm      *llm.Client
+	tools    *registry.Registry
+	memories tool.MemoryStore
+	metrics  *metrics
+	cache    *toolcache.Cache
+	redactor *pii.Redactor
+
+	toolConcurrency int
+	toolTimeout     time.Duration
+	toolTimeouts    map[string]time.Duration
+	toolBudget      time.Duration
+
+	// Models answering replies and titles, the first one that is available
+	replyModels []string
+	titleModels []string
+
+	// Settings of the travel tools
+	weather         *WeatherClient
+	holidayCalendar string
+	speeds          tool.Speeds
 }
 
-func New() *Assistant {
-	WeatherClient := NewWeatherClient()
+// New returns an assistant with the travel tools. The recall and knowledge
+// base tools are only available when a recall index and a knowledge base are
+// given.
+func New(repo *model.Repository, index *recall.Index, kb *knowledge.Base, opts ...Option) *Assistant {
+	metrics, err := newMetrics(otel.Meter("acai.chat.assistant"))
+	if err != nil {
+		// Replies are served without metrics rather than not at all
+		slog.Error("Failed to create assistant metrics", "error", err)
+		metrics, _ = newMetrics(noop.Meter{})
+	}
 
 	a := &Assistant{
-		cli:   openai.NewClient(),
-		tools: map[string]Tool{},
+		llm:      llm.NewOpenAI(openai.NewClient()),
+		tools:    registry.New(),
+		memories: repo,
+		metrics:  metrics,
+		cache:    toolcache.New(toolcache.NewLRU(toolcache.DefaultSize)),
+
+		toolConcurrency: defaultToolConcurrency,
+		toolTimeout:     defaultToolTimeout,
+		toolTimeouts:    map[string]time.Duration{},
+		toolBudget:      defaultToolBudget,
+
+		replyModels: []string{openai.ChatModelGPT4_1},
+		titleModels: []string{openai.ChatModelO1},
+
+		weather:         NewWeatherClient(""),
+		holidayCalendar: tool.DefaultHolidayCalendar,
+		speeds:          tool.DefaultSpeeds,
+	}
+
+	for _, opt := range opts {
+		opt(a)
 	}
 
-	a.registerTool(tool.NewDateTool())
-	a.registerTool(tool.NewHolidaysTool())
-	a.registerTool(tool.NewWeatherTool(WeatherClient))
+	a.registerTool(registry.Typed[tool.DateArgs](tool.NewDateTool()))
+	a.registerTool(registry.Typed[tool.HolidaysArgs](tool.NewHolidaysTool(a.holidayCalendar)))
+	a.registerTool(registry.Typed[tool.WeatherArgs](tool.NewWeatherTool(a.weather)))
+	a.registerTool(registry.Typed[tool.DistanceArgs](tool.NewDistanceTool(a.speeds)))
+
+	addItem, updateItem, listItinerary := tool.NewItineraryTools(repo)
+	a.registerTool(registry.Typed[tool.AddItineraryItemArgs](addItem))
+	a.registerTool(registry.Typed[tool.UpdateItineraryItemArgs](updateItem))
+	a.registerTool(registry.Typed[tool.ListItineraryArgs](listItinerary))
+	a.registerTool(registry.Typed[tool.ProposeEventArgs](tool.NewProposeEventTool()))
+
+	saveMemory, recallMemories := tool.NewMemoryTools(repo)
+	a.registerTool(registry.Typed[tool.SaveMemoryArgs](saveMemory))
+	a.registerTool(registry.Typed[tool.RecallMemoriesArgs](recallMemories))
+
+	if index != nil {
+		a.registerTool(registry.Typed[tool.RecallArgs](tool.NewRecallTool(index)))
+	}
+
+	if kb != nil {
+		a.registerTool(registry.Typed[tool.SearchKnowledgeArgs](tool.NewSearchKnowledgeTool(kb)))
+	}
 
 	return a
 }
 
-func (a *Assistant) registerTool(tool Tool) {
-	a.tools[tool.Name()] = tool
+// registerTool adds a built-in tool, caching its results unless it opts out.
+// Built-in tools have fixed, unique names, so failing to register one is a
+// programming error.
+func (a *Assistant) registerTool(t Tool) {
+	if a.cache != nil {
+		t = a.cache.Wrap(t)
+	}
+
+	if err := a.tools.Register(t); err != nil {
+		panic(err)
+	}
 }
 
-func (a *Assistant) toolDefinitions() []openai.ChatCompletionToolUnionParam {
-	defs := make([]openai.ChatCompletionToolUnionParam, 0, len(a.tools))
-	for _, tool := range a.tools {
-		defs = append(defs, openai.ChatCompletionFunctionTool(
-			openai.FunctionDefinitionParam{
-				Name:        tool.Name(),
-				Description: openai.String(tool.Description()),
-				Parameters:  tool.Parameters(),
-			},
-		))
-	}
-	return defs
+// Tools describes the tools the assistant can use, sorted by name.
+func (a *Assistant) Tools() []registry.Info {
+	return a.tools.Tools()
 }
 
+// executeTool runs the tool, unless it is disabled in the conversation of the
+// context. Arguments are validated against the schema of the tool first.
 func (a *Assistant) executeTool(ctx context.Context, name, args string) (string, error) {
-	tool, ok := a.tools[name]
-	if !ok {
-		return "", fmt.Errorf("unknown tool: %s", name)
+	if conv, ok := tool.ConversationFrom(ctx); ok && slices.Contains(conv.DisabledTools, name) {
+		return "", fmt.Errorf("tool %s is disabled in this conversation", name)
 	}
-	return tool.Execute(ctx, args)
+
+	return a.tools.Execute(ctx, name, args)
 }
 
 func (a *Assistant) Title(ctx context.Context, conv *model.Conversation) (string, error) {
@@ -76,15 +146,15 @@ func (a *Assistant) Title(ctx context.Context, conv *model.Conversation) (string
 
 	slog.InfoContext(ctx, "Generating title for conversation", "conversation_id", conv.ID)
 
+	vault := a.redactor.NewVault()
 	msgs := make([]openai.ChatCompletionMessageParamUnion, 0, len(conv.Messages)+1)
 
 	msgs = append(msgs, openai.AssistantMessage("Generate a concise, descriptive title for the conversation based on the user message. The title should be a single line, no more than 80 characters, and should not include any special characters or emojis."))
 	for _, m := range conv.Messages {
-		msgs = append(msgs, openai.UserMessage(m.Content))
+		msgs = append(msgs, openai.UserMessage(vault.Redact(m.Content)))
 	}
 
-	resp, err := a.cli.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model:    openai.ChatModelO1,
+	resp, err := a.complete(ctx, operationTitle, a.titleModels, openai.ChatCompletionNewParams{
 		Messages: msgs,
 	})
 
@@ -96,7 +166,7 @@ func (a *Assistant) Title(ctx context.Context, conv *model.Conversation) (string
 		return "", errors.New("empty response from OpenAI for title generation")
 	}
 
-	title := resp.Choices[0].Message.Content
+	title := vault.Restore(resp.Choices[0].Message.Content)
 	title = strings.ReplaceAll(title, "\n", " ")
 	title = strings.Trim(title, " \t\r\n-\"'")
 
@@ -107,66 +177,169 @@ func (a *Assistant) Title(ctx context.Context, conv *model.Conversation) (string
 	return title, nil
 }
 
-func (a *Assistant) Reply(ctx context.Context, conv *model.Conversation) (string, error) {
+// complete creates a chat completion for the operation with the first model
+// of the chain that answers, see llm.Client.Complete. The ID of the request
+// is sent as the user of the completion, so support can find the completions
+// of a request. Metadata would need completions to be stored by OpenAI.
+func (a *Assistant) complete(ctx context.Context, operation string, models []string, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	if id := httpx.RequestIDFrom(ctx); id != "" {
+		params.User = openai.String(id)
+	}
+
+	start := time.Now()
+	resp, err := a.llm.Complete(ctx, models, params)
+	a.metrics.recordCompletion(ctx, operation, resp, err, time.Since(start))
+
+	return resp, err
+}
+
+// promptMemories is the number of memories about the user added to the
+// system prompt, the model can recall more with the recall_memories tool.
+const promptMemories = 20
+
+// systemPrompt returns the instructions of the reply, with what is
+// remembered about the user most relevant to their last message.
+func (a *Assistant) systemPrompt(ctx context.Context, conv *model.Conversation) string {
+	prompt := "You are a helpful, concise AI assistant. Provide accurate, safe, and clear responses."
+	if a.redactor != nil {
+		prompt += "\n\nPersonal data, like card or phone numbers, is replaced by placeholders such as [CARD_1]. " +
+			"Use the placeholders as they are, also in tool arguments, they are replaced back with the data afterwards."
+	}
+
+	// Anonymous users have no memories, see tool.SaveMemoryTool
+	if conv.Owner == "" {
+		return prompt
+	}
+
+	memories, err := a.memories.ListMemories(ctx, conv.Owner)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load memories", "conversation_id", conv.ID, "error", err)
+		return prompt
+	}
+
+	if len(memories) == 0 {
+		return prompt
+	}
+
+	last := conv.Messages[len(conv.Messages)-1].Content
+	return prompt + "\n\nWhat you remember about the user from previous conversations, " +
+		"take it into account without repeating it back unless asked:\n" +
+		tool.FormatMemories(model.RankMemories(memories, last, promptMemories))
+}
+
+// Reply generates the next assistant message of the conversation. Knowledge
+// base passages the reply refers to are attached to it as citations.
+//
+// When the model calls tools requiring confirmation, the reply pauses: the
+// other calls of the turn run, the paused reply is stored as conv.Pending
+// and Reply returns a nil message. Once the user decided on all its pending
+// actions, Resume continues the reply.
+func (a *Assistant) Reply(ctx context.Context, conv *model.Conversation) (*model.Message, error) {
 	if len(conv.Messages) == 0 {
-		return "", errors.New("conversation has no messages")
+		return nil, errors.New("conversation has no messages")
 	}
 
 	slog.InfoContext(ctx, "Generating reply for conversation", "conversation_id", conv.ID)
 
+	return a.reply(ctx, conv, &tool.Citations{}, nil)
+}
+
+// reply runs the model loop, replaying the tool calling turns of the reply
+// so far, if any.
+func (a *Assistant) reply(ctx context.Context, conv *model.Conversation, citations *tool.Citations, turns []*model.ToolTurn) (*model.Message, error) {
+	ctx = tool.WithConversation(ctx, conv)
+	ctx = tool.WithCitations(ctx, citations)
+
+	// Personal data is replaced by placeholders in the whole prompt, which
+	// is rebuilt on each request, so the vault only lives for this reply
+	vault := a.redactor.NewVault()
+
 	msgs := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage("You are a helpful, concise AI assistant. Provide accurate, safe, and clear responses."),
+		openai.SystemMessage(vault.Redact(a.systemPrompt(ctx, conv))),
 	}
 
 	for _, m := range conv.Messages {
 		switch m.Role {
 		case model.RoleUser:
-			msgs = append(msgs, openai.UserMessage(m.Content))
+			msgs = append(msgs, openai.UserMessage(vault.Redact(m.Content)))
 		case model.RoleAssistant:
-			msgs = append(msgs, openai.AssistantMessage(m.Content))
+			msgs = append(msgs, openai.AssistantMessage(vault.Redact(m.Content)))
 		}
 	}
 
-	for i := 0; i < 15; i++ {
-		resp, err := a.cli.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-			Model:    openai.ChatModelGPT4_1,
+	for _, turn := range turns {
+		msgs = append(msgs, turnMessages(turn, vault)...)
+	}
+
+	// Tool time budget left for this reply
+	budget := a.toolBudget
+
+	// Model calls of this request, a resumed reply only counts the new ones
+	iterations := 0
+	defer func() { a.metrics.recordIterations(ctx, iterations) }()
+
+	for i := len(turns); i < 15; i++ {
+		iterations++
+		resp, err := a.complete(ctx, operationReply, a.replyModels, openai.ChatCompletionNewParams{
 			Messages: msgs,
-			Tools:    a.toolDefinitions(),
+			Tools:    a.tools.Definitions(conv.DisabledTools),
 		})
 
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		if len(resp.Choices) == 0 {
-			return "", errors.New("no choices returned by OpenAI")
+			return nil, errors.New("no choices returned by OpenAI")
 		}
 
 		message := resp.Choices[0].Message
+		content := vault.Restore(message.Content)
 
 		if len(message.ToolCalls) > 0 {
-			msgs = append(msgs, message.ToParam())
-
+			// Tools run and the user confirms actions with the actual data
+			turn := &model.ToolTurn{Content: content}
 			for _, call := range message.ToolCalls {
-				slog.InfoContext(ctx, "Tool call received",
-					"name", call.Function.Name,
-					"args", call.Function.Arguments)
-
-				result, err := a.executeTool(ctx, call.Function.Name, call.Function.Arguments)
-				if err != nil {
-					slog.ErrorContext(ctx, "Tool execution failed",
-						"tool", call.Function.Name,
-						"error", err)
-					result = fmt.Sprintf("Tool execution failed: %v", err)
-				}
+				turn.Calls = append(turn.Calls, &model.ToolCall{
+					ID:        call.ID,
+					Name:      call.Function.Name,
+					Arguments: vault.Restore(call.Function.Arguments),
+				})
+			}
 
-				msgs = append(msgs, openai.ToolMessage(result, call.ID))
+			// Calls requiring confirmation wait for the user, the others run now
+			now, actions := a.splitCalls(conv, turn.Calls)
+
+			spent := a.runCalls(ctx, now, budget)
+			budget -= spent
+
+			turns = append(turns, turn)
+
+			if len(actions) > 0 {
+				slog.InfoContext(ctx, "Reply paused for confirmation", "conversation_id", conv.ID, "actions", len(actions))
+
+				conv.Pending = &model.PendingReply{
+					Turns:     turns,
+					Actions:   actions,
+					Citations: citations.Items(),
+					CreatedAt: time.Now(),
+				}
+				return nil, nil
 			}
+
+			msgs = append(msgs, turnMessages(turn, vault)...)
 			continue
 		}
 
-		return message.Content, nil
+		return &model.Message{
+			ID:        primitive.NewObjectID(),
+			Role:      model.RoleAssistant,
+			Content:   content,
+			Citations: citations.Referenced(content),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}, nil
 	}
 
-	return "", errors.New("too many tool calls, unable to generate reply")
+	return nil, errors.New("too many tool calls, unable to generate reply")
 }
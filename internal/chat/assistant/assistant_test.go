@@ -0,0 +1,95 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/llm"
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"github.com/acai-travel/tech-challenge/internal/pii"
+	"github.com/openai/openai-go/v2"
+)
+
+type noMemories struct{}
+
+func (noMemories) SaveMemory(ctx context.Context, m *model.Memory) error { return nil }
+func (noMemories) ListMemories(ctx context.Context, owner string) ([]*model.Memory, error) {
+	return nil, nil
+}
+
+// echoTool returns its text, recording it.
+type echoTool struct {
+	got string
+}
+
+type echoArgs struct {
+	Text string `json:"text"`
+}
+
+func (t *echoTool) Name() string        { return "echo" }
+func (t *echoTool) Description() string { return "Echoes the text" }
+
+func (t *echoTool) Call(ctx context.Context, args echoArgs) (string, error) {
+	t.got = args.Text
+	return "echoed " + args.Text, nil
+}
+
+func completion(content string, calls ...openai.ChatCompletionMessageToolCallUnion) *openai.ChatCompletion {
+	return &openai.ChatCompletion{Choices: []openai.ChatCompletionChoice{{
+		Message: openai.ChatCompletionMessage{Content: content, ToolCalls: calls},
+	}}}
+}
+
+func TestAssistant_Redaction(t *testing.T) {
+	ctx := context.Background()
+	card := "4111 1111 1111 1111"
+
+	var prompts []string
+	fake := func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+		b, err := json.Marshal(params.Messages)
+		if err != nil {
+			t.Fatalf("failed to marshal messages: %v", err)
+		}
+		prompts = append(prompts, string(b))
+
+		switch len(prompts) {
+		case 1:
+			var call openai.ChatCompletionMessageToolCallUnion
+			call.ID, call.Function.Name, call.Function.Arguments = "call_1", "echo", `{"text": "[CARD_1]"}`
+			return completion("", call), nil
+		default:
+			return completion("Charged [CARD_1]"), nil
+		}
+	}
+
+	a, _ := newTestAssistant(WithLLM(llm.New("test", fake)), WithReplyModels("gpt-4.1"), WithRedactor(pii.New()))
+	a.memories = noMemories{}
+	echo := &echoTool{}
+	a.registerTool(registry.Typed[echoArgs](echo))
+
+	conv := &model.Conversation{Messages: []*model.Message{{Role: model.RoleUser, Content: "Pay with " + card}}}
+
+	msg, err := a.Reply(ctx, conv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(prompts) != 2 {
+		t.Fatalf("got %d model calls, want 2", len(prompts))
+	}
+	for i, prompt := range prompts {
+		if strings.Contains(prompt, card) || !strings.Contains(prompt, "[CARD_1]") {
+			t.Errorf("expected prompt %d to have the card replaced, got %s", i, prompt)
+		}
+	}
+
+	if echo.got != card {
+		t.Errorf("expected the tool to get the card, got %q", echo.got)
+	}
+	if msg.Content != "Charged "+card {
+		t.Errorf("expected the card restored in the reply, got %q", msg.Content)
+	}
+}
@@ -0,0 +1,159 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/tool"
+	"github.com/acai-travel/tech-challenge/internal/pii"
+	"github.com/openai/openai-go/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RunActions runs the calls the user approved in conv.Pending, once all its
+// actions are decided, and tells the model the user refused the rejected
+// ones. The results are stored in the paused turns, and Pending.Ran is set so
+// the calls don't run twice: the reply can be resumed again if it fails.
+func (a *Assistant) RunActions(ctx context.Context, conv *model.Conversation) error {
+	pending := conv.Pending
+	if pending == nil || len(pending.Turns) == 0 {
+		return errors.New("conversation has no paused reply")
+	}
+
+	if !pending.Decided() {
+		return errors.New("conversation has undecided actions")
+	}
+
+	if pending.Ran {
+		return nil
+	}
+
+	turn := pending.Turns[len(pending.Turns)-1]
+
+	var approved []*model.ToolCall
+	for _, action := range pending.Actions {
+		i := slices.IndexFunc(turn.Calls, func(c *model.ToolCall) bool { return c.ID == action.CallID })
+		if i < 0 {
+			return fmt.Errorf("action %s has no tool call", action.ID.Hex())
+		}
+
+		call := turn.Calls[i]
+		switch action.Status {
+		case model.ActionApproved:
+			approved = append(approved, call)
+		case model.ActionRejected:
+			call.Result = "Tool not executed: the user rejected this action."
+			if reason := strings.TrimSpace(action.Reason); reason != "" {
+				call.Result += " Reason: " + reason
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "Running approved actions for conversation", "conversation_id", conv.ID, "count", len(approved))
+
+	// Citations of the approved calls are kept for the resumed reply
+	citations := tool.NewCitations(pending.Citations...)
+	toolCtx := tool.WithCitations(tool.WithConversation(ctx, conv), citations)
+	a.runCalls(toolCtx, approved, a.toolBudget)
+
+	pending.Citations = citations.Items()
+	pending.Ran = true
+	return nil
+}
+
+// Resume continues the reply paused in conv.Pending, once the user approved
+// or rejected all its actions, running them first unless RunActions did.
+// Pending is cleared, and the reply can pause again, see Reply.
+func (a *Assistant) Resume(ctx context.Context, conv *model.Conversation) (*model.Message, error) {
+	if err := a.RunActions(ctx, conv); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Resuming reply for conversation", "conversation_id", conv.ID)
+
+	pending := conv.Pending
+	conv.Pending = nil
+	return a.reply(ctx, conv, tool.NewCitations(pending.Citations...), pending.Turns)
+}
+
+// splitCalls returns the calls to run right away and the pending actions of
+// the calls requiring confirmation. Disabled tools are refused when run, so
+// they never wait for the user.
+func (a *Assistant) splitCalls(conv *model.Conversation, calls []*model.ToolCall) ([]*model.ToolCall, []*model.PendingAction) {
+	var now []*model.ToolCall
+	var actions []*model.PendingAction
+
+	for _, call := range calls {
+		if !a.tools.RequiresConfirmation(call.Name) || slices.Contains(conv.DisabledTools, call.Name) {
+			now = append(now, call)
+			continue
+		}
+
+		actions = append(actions, &model.PendingAction{
+			ID:        primitive.NewObjectID(),
+			CallID:    call.ID,
+			Tool:      call.Name,
+			Arguments: call.Arguments,
+			Status:    model.ActionPending,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	return now, actions
+}
+
+// runCalls runs the calls with runTools and stores their results.
+func (a *Assistant) runCalls(ctx context.Context, calls []*model.ToolCall, budget time.Duration) time.Duration {
+	if len(calls) == 0 {
+		return 0
+	}
+
+	params := make([]openai.ChatCompletionMessageToolCallUnion, len(calls))
+	for i, call := range calls {
+		params[i].ID = call.ID
+		params[i].Function.Name = call.Name
+		params[i].Function.Arguments = call.Arguments
+	}
+
+	results, spent := a.runTools(ctx, params, budget)
+	for i, call := range calls {
+		call.Result = results[i]
+	}
+
+	return spent
+}
+
+// turnMessages returns the model messages of a tool calling turn: the
+// assistant message with the calls, then the result of each call. Personal
+// data is redacted by the vault.
+func turnMessages(turn *model.ToolTurn, vault *pii.Vault) []openai.ChatCompletionMessageParamUnion {
+	asst := openai.ChatCompletionAssistantMessageParam{}
+	if turn.Content != "" {
+		asst.Content.OfString = openai.String(vault.Redact(turn.Content))
+	}
+
+	for _, call := range turn.Calls {
+		asst.ToolCalls = append(asst.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+			OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+				ID: call.ID,
+				Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+					Name:      call.Name,
+					Arguments: vault.Redact(call.Arguments),
+				},
+			},
+		})
+	}
+
+	msgs := []openai.ChatCompletionMessageParamUnion{{OfAssistant: &asst}}
+	for _, call := range turn.Calls {
+		msgs = append(msgs, openai.ToolMessage(vault.Redact(call.Result), call.ID))
+	}
+
+	return msgs
+}
@@ -0,0 +1,60 @@
+package assistant
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+)
+
+func TestSplitCalls(t *testing.T) {
+	a, _ := newTestAssistant()
+	a.registerTool(registry.RequireConfirmation(registry.Typed[sleepArgs](&namedSleepTool{name: "nap"})))
+
+	calls := []*model.ToolCall{
+		{ID: "call_1", Name: "sleep", Arguments: `{"duration": "1ms"}`},
+		{ID: "call_2", Name: "nap", Arguments: `{"duration": "1h"}`},
+	}
+
+	t.Run("holds calls requiring confirmation", func(t *testing.T) {
+		now, actions := a.splitCalls(&model.Conversation{}, calls)
+
+		if len(now) != 1 || now[0].ID != "call_1" {
+			t.Errorf("unexpected calls to run %+v", now)
+		}
+		if len(actions) != 1 || actions[0].CallID != "call_2" || actions[0].Tool != "nap" || actions[0].Status != model.ActionPending {
+			t.Errorf("unexpected actions %+v", actions)
+		}
+	})
+
+	t.Run("runs disabled tools to refuse them", func(t *testing.T) {
+		now, actions := a.splitCalls(&model.Conversation{DisabledTools: []string{"nap"}}, calls)
+
+		if len(now) != 2 || len(actions) != 0 {
+			t.Errorf("got %d calls and %d actions, want 2 and 0", len(now), len(actions))
+		}
+	})
+}
+
+func TestTurnMessages(t *testing.T) {
+	msgs := turnMessages(&model.ToolTurn{Calls: []*model.ToolCall{
+		{ID: "call_1", Name: "sleep", Arguments: `{"duration": "1ms"}`, Result: "slept 1ms"},
+		{ID: "call_2", Name: "nap", Arguments: `{"duration": "1h"}`, Result: "Tool not executed: the user rejected this action."},
+	}}, nil)
+
+	if len(msgs) != 3 || len(msgs[0].OfAssistant.ToolCalls) != 2 {
+		t.Fatalf("expected the assistant turn and 2 results, got %d messages", len(msgs))
+	}
+
+	if msgs[2].OfTool.ToolCallID != "call_2" || !strings.Contains(msgs[2].OfTool.Content.OfString.Value, "rejected") {
+		t.Errorf("unexpected result message %+v", msgs[2].OfTool)
+	}
+}
+
+type namedSleepTool struct {
+	sleepTool
+	name string
+}
+
+func (t *namedSleepTool) Name() string { return t.name }
@@ -0,0 +1,127 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"github.com/acai-travel/tech-challenge/internal/mcp"
+	"github.com/openai/openai-go/v2"
+)
+
+// MountMCP registers the allow-listed tools of the MCP server as tools of
+// the assistant, named <server>_<tool>. Their executions are bounded by the
+// timeout of the server, and the tools listed in Confirm require the approval
+// of the user. It returns the names of the mounted tools.
+//
+// It is meant to be called on startup, before the assistant replies.
+func (a *Assistant) MountMCP(ctx context.Context, client *mcp.Client) ([]string, error) {
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: listing tools: %w", client.Name(), err)
+	}
+
+	config := client.Config()
+
+	var mounted []string
+	for _, t := range tools {
+		if !config.Allows(t.Name) {
+			continue
+		}
+
+		tool := &mcpTool{client: client, tool: t, name: mcpToolName(client.Name(), t.Name)}
+
+		var registered Tool = tool
+		if slices.Contains(config.Confirm, t.Name) {
+			registered = registry.RequireConfirmation(tool)
+		}
+
+		if err := a.tools.Register(registered); err != nil {
+			slog.ErrorContext(ctx, "Failed to mount MCP tool", "server", client.Name(), "tool", t.Name, "error", err)
+			continue
+		}
+
+		a.toolTimeouts[tool.name] = client.Timeout()
+		mounted = append(mounted, tool.name)
+	}
+
+	for _, name := range config.Tools {
+		if !slices.ContainsFunc(tools, func(t mcp.Tool) bool { return t.Name == name }) {
+			slog.WarnContext(ctx, "Allow-listed MCP tool not found", "server", client.Name(), "tool", name)
+		}
+	}
+
+	return mounted, nil
+}
+
+var invalidToolName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+func mcpToolName(server, tool string) string {
+	name := server + "_" + invalidToolName.ReplaceAllString(tool, "_")
+	return name[:min(len(name), 64)]
+}
+
+// mcpTool is a tool of an MCP server. Arguments are passed through as-is,
+// after validation against the input schema of the tool.
+type mcpTool struct {
+	client *mcp.Client
+	tool   mcp.Tool
+	name   string
+}
+
+func (t *mcpTool) Name() string {
+	return t.name
+}
+
+func (t *mcpTool) Description() string {
+	if t.tool.Description != "" {
+		return t.tool.Description
+	}
+	return t.tool.Title
+}
+
+// CacheTTL opts out of caching, tools of other servers may change data.
+func (t *mcpTool) CacheTTL() time.Duration {
+	return 0
+}
+
+func (t *mcpTool) Parameters() openai.FunctionParameters {
+	if t.tool.InputSchema == nil {
+		return openai.FunctionParameters{"type": "object", "properties": map[string]any{}}
+	}
+	return t.tool.InputSchema
+}
+
+func (t *mcpTool) Execute(ctx context.Context, arguments string) (string, error) {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+
+	res, err := t.client.CallTool(ctx, t.tool.Name, json.RawMessage(arguments))
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(res.Content))
+	for _, c := range res.Content {
+		if c.Type == "text" {
+			parts = append(parts, c.Text)
+		} else {
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", c.Type))
+		}
+	}
+	text := strings.Join(parts, "\n")
+
+	if res.IsError {
+		return "", errors.New(text)
+	}
+
+	return text, nil
+}
@@ -0,0 +1,156 @@
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tool call outcomes, recorded as the outcome attribute of tool metrics
+const (
+	outcomeOK      = "ok"
+	outcomeError   = "error"
+	outcomeInvalid = "invalid"
+	outcomeTimeout = "timeout"
+	outcomeSkipped = "skipped"
+)
+
+// unknown stands for the model of a failed completion and for the names of
+// tools that aren't registered.
+const unknown = "unknown"
+
+// metrics of the assistant.
+type metrics struct {
+	toolCalls       metric.Int64Counter
+	toolDuration    metric.Float64Histogram
+	llmDuration     metric.Float64Histogram
+	llmTokens       metric.Int64Counter
+	replyIterations metric.Int64Histogram
+}
+
+// Operations the model completes, recorded as the operation attribute of
+// model metrics
+const (
+	operationReply = "reply"
+	operationTitle = "title"
+)
+
+func newMetrics(meter metric.Meter) (*metrics, error) {
+	m := &metrics{}
+
+	var err error
+
+	// Number of tool calls by tool and outcome
+	m.toolCalls, err = meter.Int64Counter(
+		"assistant.tool.calls",
+		metric.WithDescription("Number of tool calls by tool and outcome"),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool calls counter: %w", err)
+	}
+
+	// Tool execution duration in seconds
+	m.toolDuration, err = meter.Float64Histogram(
+		"assistant.tool.duration",
+		metric.WithDescription("Duration of tool executions"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool duration histogram: %w", err)
+	}
+
+	// Model completion duration in seconds, including retries and fallbacks
+	m.llmDuration, err = meter.Float64Histogram(
+		"assistant.llm.duration",
+		metric.WithDescription("Duration of model completions by model, operation and outcome"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model duration histogram: %w", err)
+	}
+
+	// Tokens of model completions, input and output
+	m.llmTokens, err = meter.Int64Counter(
+		"assistant.llm.tokens",
+		metric.WithDescription("Number of tokens of model completions by model, operation and type"),
+		metric.WithUnit("{token}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model tokens counter: %w", err)
+	}
+
+	// Model calls of each reply, more than one when it calls tools
+	m.replyIterations, err = meter.Int64Histogram(
+		"assistant.reply.iterations",
+		metric.WithDescription("Number of iterations of the tool calling loop of replies"),
+		metric.WithUnit("{iteration}"),
+		metric.WithExplicitBucketBoundaries(1, 2, 3, 4, 5, 7, 10, 15),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reply iterations histogram: %w", err)
+	}
+
+	return m, nil
+}
+
+func (m *metrics) recordTool(ctx context.Context, name, outcome string, duration time.Duration) {
+	attrs := metric.WithAttributes(
+		attribute.String("tool.name", name),
+		attribute.String("tool.outcome", outcome),
+	)
+
+	// The span of the call, see runTool
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("tool.outcome", outcome))
+	if outcome == outcomeError || outcome == outcomeTimeout {
+		span.SetStatus(codes.Error, outcome)
+	}
+
+	m.toolCalls.Add(ctx, 1, attrs)
+	if outcome != outcomeSkipped {
+		m.toolDuration.Record(ctx, duration.Seconds(), attrs)
+	}
+}
+
+// recordCompletion records a completion of the operation. The model is the
+// one that answered, unknown when the completion failed.
+func (m *metrics) recordCompletion(ctx context.Context, operation string, resp *openai.ChatCompletion, err error, duration time.Duration) {
+	outcome, model := outcomeOK, unknown
+	if err != nil {
+		outcome = outcomeError
+	} else {
+		model = resp.Model
+	}
+
+	m.llmDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+		attribute.String("llm.model", model),
+		attribute.String("llm.operation", operation),
+		attribute.String("llm.outcome", outcome),
+	))
+
+	if err != nil {
+		return
+	}
+
+	for kind, tokens := range map[string]int64{
+		"input":  resp.Usage.PromptTokens,
+		"output": resp.Usage.CompletionTokens,
+	} {
+		m.llmTokens.Add(ctx, tokens, metric.WithAttributes(
+			attribute.String("llm.model", model),
+			attribute.String("llm.operation", operation),
+			attribute.String("llm.token.type", kind),
+		))
+	}
+}
+
+func (m *metrics) recordIterations(ctx context.Context, iterations int) {
+	m.replyIterations.Record(ctx, int64(iterations))
+}
@@ -0,0 +1,116 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetrics_RecordCompletion(t *testing.T) {
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(prev) })
+
+	m, err := newMetrics(otel.Meter("acai.chat.assistant"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := &openai.ChatCompletion{Model: "gpt-4.1"}
+	resp.Usage.PromptTokens, resp.Usage.CompletionTokens = 120, 30
+
+	m.recordCompletion(ctx, operationReply, resp, nil, time.Second)
+	m.recordCompletion(ctx, operationTitle, nil, errors.New("boom"), time.Second)
+	m.recordIterations(ctx, 3)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	tokens := map[string]int64{}
+	durations := map[string]uint64{}
+	var iterations int64
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, metric := range sm.Metrics {
+			switch data := metric.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					kind, _ := dp.Attributes.Value("llm.token.type")
+					tokens[kind.AsString()] += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					model, _ := dp.Attributes.Value("llm.model")
+					op, _ := dp.Attributes.Value("llm.operation")
+					durations[op.AsString()+"/"+model.AsString()] += dp.Count
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					iterations += dp.Sum
+				}
+			}
+		}
+	}
+
+	if tokens["input"] != 120 || tokens["output"] != 30 {
+		t.Errorf("got tokens %v", tokens)
+	}
+	if durations["reply/gpt-4.1"] != 1 || durations["title/unknown"] != 1 {
+		t.Errorf("got durations %v", durations)
+	}
+	if iterations != 3 {
+		t.Errorf("got %d iterations, want 3", iterations)
+	}
+}
+
+func TestMetrics_UnknownTools(t *testing.T) {
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	m, err := newMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("acai.chat.assistant"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	a, _ := newTestAssistant()
+	a.metrics = m
+
+	calls := sleepCalls("1ms")
+	calls = append(calls, openai.ChatCompletionMessageToolCallUnion{ID: "call_made_up"})
+	calls[1].Function.Name = "made_up_tool"
+	calls[1].Function.Arguments = "{}"
+
+	a.runTools(ctx, calls, time.Minute)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	counts := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, metric := range sm.Metrics {
+			if data, ok := metric.Data.(metricdata.Sum[int64]); ok && metric.Name == "assistant.tool.calls" {
+				for _, dp := range data.DataPoints {
+					name, _ := dp.Attributes.Value("tool.name")
+					counts[name.AsString()] += dp.Value
+				}
+			}
+		}
+	}
+
+	if counts["sleep"] != 1 || counts["unknown"] != 1 || counts["made_up_tool"] != 0 {
+		t.Errorf("got tool calls %v", counts)
+	}
+}
@@ -0,0 +1,129 @@
+package assistant
+
+import (
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/llm"
+	"github.com/acai-travel/tech-challenge/internal/chat/tool"
+	"github.com/acai-travel/tech-challenge/internal/chat/toolcache"
+	"github.com/acai-travel/tech-challenge/internal/pii"
+)
+
+const (
+	defaultToolConcurrency = 4
+	defaultToolTimeout     = 20 * time.Second
+	defaultToolBudget      = 60 * time.Second
+)
+
+// Option configures the assistant.
+type Option func(*Assistant)
+
+// WithToolConcurrency sets how many tool calls of a model turn run at once.
+func WithToolConcurrency(n int) Option {
+	return func(a *Assistant) {
+		if n > 0 {
+			a.toolConcurrency = n
+		}
+	}
+}
+
+// WithToolTimeout sets the deadline of each tool execution.
+func WithToolTimeout(d time.Duration) Option {
+	return func(a *Assistant) {
+		if d > 0 {
+			a.toolTimeout = d
+		}
+	}
+}
+
+// WithToolTimeoutFor overrides the deadline of the executions of one tool,
+// e.g. to give a slow download more time.
+func WithToolTimeoutFor(name string, d time.Duration) Option {
+	return func(a *Assistant) {
+		if d > 0 {
+			a.toolTimeouts[name] = d
+		}
+	}
+}
+
+// WithToolBudget sets the total time tools can run for while generating a
+// single reply. Tool calls made once it is spent are not executed, and the
+// model is told so.
+func WithToolBudget(d time.Duration) Option {
+	return func(a *Assistant) {
+		if d > 0 {
+			a.toolBudget = d
+		}
+	}
+}
+
+// WithToolCache caches the results of tool calls in the cache, instead of
+// the default in-process LRU. A nil cache disables caching.
+func WithToolCache(c *toolcache.Cache) Option {
+	return func(a *Assistant) {
+		a.cache = c
+	}
+}
+
+// WithReplyModels sets the chain of models generating replies: the first
+// one, then the next ones when it is unavailable or rate limited.
+func WithReplyModels(models ...string) Option {
+	return func(a *Assistant) {
+		if len(models) > 0 {
+			a.replyModels = models
+		}
+	}
+}
+
+// WithTitleModels sets the chain of models generating titles, see
+// WithReplyModels.
+func WithTitleModels(models ...string) Option {
+	return func(a *Assistant) {
+		if len(models) > 0 {
+			a.titleModels = models
+		}
+	}
+}
+
+// WithWeatherClient sets the client of the weather tool, which has no API
+// key by default.
+func WithWeatherClient(c *WeatherClient) Option {
+	return func(a *Assistant) {
+		a.weather = c
+	}
+}
+
+// WithHolidayCalendar sets the URL of the iCalendar of the holidays tool,
+// tool.DefaultHolidayCalendar by default.
+func WithHolidayCalendar(url string) Option {
+	return func(a *Assistant) {
+		if url != "" {
+			a.holidayCalendar = url
+		}
+	}
+}
+
+// WithTravelSpeeds sets the speeds of the travel time estimates of the
+// distance tool, tool.DefaultSpeeds by default.
+func WithTravelSpeeds(speeds tool.Speeds) Option {
+	return func(a *Assistant) {
+		a.speeds = speeds
+	}
+}
+
+// WithLLM sets the client calling the models, e.g. with other retry and
+// circuit breaker settings.
+func WithLLM(c *llm.Client) Option {
+	return func(a *Assistant) {
+		a.llm = c
+	}
+}
+
+// WithRedactor replaces the personal data the redactor finds in the content
+// sent to the models by placeholders, restored in their answers and in the
+// arguments of tool calls. Nothing is redacted by default.
+func WithRedactor(r *pii.Redactor) Option {
+	return func(a *Assistant) {
+		a.redactor = r
+	}
+}
@@ -0,0 +1,120 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"github.com/openai/openai-go/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("acai.chat.assistant")
+
+var errBudgetExhausted = errors.New("tool time budget of this reply is exhausted")
+
+// runTools executes the tool calls of a model turn concurrently, at most
+// toolConcurrency at a time, and returns their results in call order. Failed
+// calls return the error as their result, so the model can react to it.
+//
+// Each call is bounded by its tool timeout, and all calls by the remaining
+// tool budget of the reply. It returns the time spent, to be deducted from
+// the budget.
+func (a *Assistant) runTools(ctx context.Context, calls []openai.ChatCompletionMessageToolCallUnion, remaining time.Duration) ([]string, time.Duration) {
+	start := time.Now()
+
+	// The budget only expires with time, not when the request is cancelled,
+	// so both cases can be told apart
+	budget, cancel := context.WithTimeout(context.WithoutCancel(ctx), remaining)
+	defer cancel()
+
+	results := make([]string, len(calls))
+	sem := make(chan struct{}, a.toolConcurrency)
+
+	// Calls start in order, the semaphore is acquired before each goroutine
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = a.runTool(ctx, budget, call.Function.Name, call.Function.Arguments)
+		}()
+	}
+	wg.Wait()
+
+	return results, time.Since(start)
+}
+
+func (a *Assistant) runTool(ctx, budget context.Context, name, args string) string {
+	// The model can call any name, only registered ones are recorded, so
+	// metrics and span names stay bounded
+	label := name
+	if !a.tools.Has(name) {
+		label = unknown
+	}
+
+	ctx, span := tracer.Start(ctx, "execute_tool "+label, trace.WithAttributes(
+		attribute.String("gen_ai.operation.name", "execute_tool"),
+		attribute.String("gen_ai.tool.name", label),
+	))
+	defer span.End()
+
+	slog.InfoContext(ctx, "Tool call received", "name", name, "args", args)
+
+	if budget.Err() != nil {
+		a.metrics.recordTool(ctx, label, outcomeSkipped, 0)
+		slog.WarnContext(ctx, "Tool call skipped", "tool", name, "error", errBudgetExhausted)
+		return fmt.Sprintf("Tool not executed: %v", errBudgetExhausted)
+	}
+
+	timeout := a.toolTimeout
+	if d, ok := a.toolTimeouts[name]; ok {
+		timeout = d
+	}
+
+	// The tool context keeps the values of ctx (conversation, citations) but
+	// is cancelled by either deadline
+	toolCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	stop := context.AfterFunc(budget, cancel)
+	defer stop()
+
+	start := time.Now()
+	result, err := a.executeTool(toolCtx, name, args)
+	duration := time.Since(start)
+
+	switch {
+	case err == nil:
+		a.metrics.recordTool(ctx, label, outcomeOK, duration)
+		return result
+
+	case budget.Err() != nil:
+		a.metrics.recordTool(ctx, label, outcomeTimeout, duration)
+		slog.ErrorContext(ctx, "Tool execution interrupted", "tool", name, "duration", duration, "error", err)
+		return fmt.Sprintf("Tool execution interrupted: %v", errBudgetExhausted)
+
+	case errors.As(err, new(*registry.ValidationError)):
+		a.metrics.recordTool(ctx, label, outcomeInvalid, duration)
+		slog.WarnContext(ctx, "Tool call rejected", "tool", name, "error", err)
+		return fmt.Sprintf("Tool not executed: %v. Fix the arguments and call it again.", err)
+
+	case errors.Is(toolCtx.Err(), context.DeadlineExceeded):
+		a.metrics.recordTool(ctx, label, outcomeTimeout, duration)
+		slog.ErrorContext(ctx, "Tool execution timed out", "tool", name, "duration", duration, "error", err)
+		return fmt.Sprintf("Tool execution timed out after %s", timeout)
+
+	default:
+		a.metrics.recordTool(ctx, label, outcomeError, duration)
+		slog.ErrorContext(ctx, "Tool execution failed", "tool", name, "error", err)
+		return fmt.Sprintf("Tool execution failed: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package assistant
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"github.com/acai-travel/tech-challenge/internal/chat/tool"
+	"github.com/openai/openai-go/v2"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// sleepTool waits for the given duration, or until cancelled.
+type sleepTool struct {
+	running, peak atomic.Int32
+}
+
+type sleepArgs struct {
+	Duration string `json:"duration"`
+}
+
+func (t *sleepTool) Name() string        { return "sleep" }
+func (t *sleepTool) Description() string { return "Sleeps" }
+
+func (t *sleepTool) Call(ctx context.Context, args sleepArgs) (string, error) {
+	n := t.running.Add(1)
+	defer t.running.Add(-1)
+	for p := t.peak.Load(); n > p && !t.peak.CompareAndSwap(p, n); p = t.peak.Load() {
+	}
+
+	d, err := time.ParseDuration(args.Duration)
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case <-time.After(d):
+		return "slept " + args.Duration, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func newTestAssistant(opts ...Option) (*Assistant, *sleepTool) {
+	metrics, _ := newMetrics(noop.Meter{})
+	a := &Assistant{
+		tools:           registry.New(),
+		metrics:         metrics,
+		toolConcurrency: defaultToolConcurrency,
+		toolTimeout:     defaultToolTimeout,
+		toolTimeouts:    map[string]time.Duration{},
+		toolBudget:      defaultToolBudget,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	tool := &sleepTool{}
+	a.registerTool(registry.Typed[sleepArgs](tool))
+
+	return a, tool
+}
+
+func sleepCalls(durations ...string) []openai.ChatCompletionMessageToolCallUnion {
+	calls := make([]openai.ChatCompletionMessageToolCallUnion, len(durations))
+	for i, d := range durations {
+		calls[i].ID = "call_" + d
+		calls[i].Function.Name = "sleep"
+		calls[i].Function.Arguments = `{"duration": "` + d + `"}`
+	}
+	return calls
+}
+
+func TestRunTools(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("runs calls concurrently and keeps call order", func(t *testing.T) {
+		a, tool := newTestAssistant(WithToolConcurrency(2))
+
+		results, spent := a.runTools(ctx, sleepCalls("60ms", "10ms", "30ms", "invalid"), time.Minute)
+
+		want := []string{"slept 60ms", "slept 10ms", "slept 30ms", "Tool execution failed"}
+		for i := range want {
+			if !strings.HasPrefix(results[i], want[i]) {
+				t.Errorf("result %d = %q, want prefix %q", i, results[i], want[i])
+			}
+		}
+
+		if p := tool.peak.Load(); p != 2 {
+			t.Errorf("peak concurrency = %d, want 2", p)
+		}
+
+		if spent >= 100*time.Millisecond {
+			t.Errorf("calls took %s, expected them to overlap", spent)
+		}
+	})
+
+	t.Run("bounds calls by the tool timeout", func(t *testing.T) {
+		a, _ := newTestAssistant(WithToolTimeout(time.Second), WithToolTimeoutFor("sleep", 20*time.Millisecond))
+
+		results, _ := a.runTools(ctx, sleepCalls("1s"), time.Minute)
+
+		if !strings.HasPrefix(results[0], "Tool execution timed out") {
+			t.Errorf("unexpected result %q", results[0])
+		}
+	})
+
+	t.Run("interrupts and skips calls once the budget is spent", func(t *testing.T) {
+		a, _ := newTestAssistant(WithToolConcurrency(1))
+
+		results, spent := a.runTools(ctx, sleepCalls("1s", "10ms"), 30*time.Millisecond)
+
+		if !strings.HasPrefix(results[0], "Tool execution interrupted") {
+			t.Errorf("unexpected result %q for the interrupted call", results[0])
+		}
+
+		if !strings.HasPrefix(results[1], "Tool not executed") {
+			t.Errorf("unexpected result %q for the skipped call", results[1])
+		}
+
+		if spent > 500*time.Millisecond {
+			t.Errorf("calls took %s, expected the budget to stop them", spent)
+		}
+	})
+	t.Run("rejects invalid arguments without running the tool", func(t *testing.T) {
+		a, tool := newTestAssistant()
+
+		calls := sleepCalls("10ms")
+		calls[0].Function.Arguments = `{"seconds": 1}`
+
+		results, _ := a.runTools(ctx, calls, time.Minute)
+
+		if !strings.Contains(results[0], `unknown field "seconds"`) {
+			t.Errorf("unexpected result %q", results[0])
+		}
+		if p := tool.peak.Load(); p != 0 {
+			t.Error("expected the tool not to run")
+		}
+	})
+
+	t.Run("refuses tools disabled in the conversation", func(t *testing.T) {
+		a, _ := newTestAssistant()
+
+		conv := &model.Conversation{DisabledTools: []string{"sleep"}}
+		results, _ := a.runTools(tool.WithConversation(ctx, conv), sleepCalls("10ms"), time.Minute)
+
+		if !strings.Contains(results[0], "disabled in this conversation") {
+			t.Errorf("unexpected result %q", results[0])
+		}
+	})
+}
@@ -3,13 +3,16 @@ package assistant
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"time"
 )
 
+// WeatherAPIURL is the URL of the WeatherAPI.com API.
+const WeatherAPIURL = "http://api.weatherapi.com/v1"
+
 type WeatherClient struct {
 	apiKey     string
 	httpClient *http.Client
@@ -36,9 +39,11 @@ type WeatherResponse struct {
 	} `json:"current"`
 }
 
-func NewWeatherClient() *WeatherClient {
+// NewWeatherClient returns a client of the WeatherAPI.com API, which fails
+// to get the weather without an API key.
+func NewWeatherClient(apiKey string) *WeatherClient {
 	return &WeatherClient{
-		apiKey: os.Getenv("WEATHER_API_KEY"),
+		apiKey: apiKey,
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
@@ -47,7 +52,7 @@ func NewWeatherClient() *WeatherClient {
 
 func (w *WeatherClient) GetCurrentWeather(ctx context.Context, location string) (string, error) {
 	if w.apiKey == "" {
-		return "Weather API key not configured", fmt.Errorf("WEATHER_API_KEY not set")
+		return "Weather API key not configured", errors.New("weather API key not set")
 	}
 
 	var lastErr error
@@ -77,7 +82,8 @@ func (w *WeatherClient) GetCurrentWeather(ctx context.Context, location string)
 
 func (w *WeatherClient) fetchWeather(ctx context.Context, location string) (string, error) {
 	url := fmt.Sprintf(
-		"http://api.weatherapi.com/v1/current.json?key=%s&q=%s&aqi=no",
+		"%s/current.json?key=%s&q=%s&aqi=no",
+		WeatherAPIURL,
 		w.apiKey,
 		location,
 	)
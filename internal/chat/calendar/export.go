@@ -0,0 +1,147 @@
+package calendar
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	ics "github.com/arran4/golang-ical"
+)
+
+const (
+	ContentType = "text/calendar; charset=utf-8"
+
+	productID = "-//Acai Travel//Chat Assistant//EN"
+	uidDomain = "acai.travel"
+
+	localTimeFormat = "20060102T150405"
+	localDateFormat = "20060102"
+
+	// itemDuration is the length of timed itinerary items, which have no end,
+	// so calendars don't show them as instants
+	itemDuration = time.Hour
+)
+
+// Export renders the itinerary (which may be nil) and the events proposed in
+// the conversation as an RFC 5545 iCalendar document. Times are written in
+// their own time zone, with a VTIMEZONE definition for each zone used.
+func Export(conv *model.Conversation, it *model.Itinerary) (string, error) {
+	cal := ics.NewCalendar()
+	cal.SetProductId(productID)
+	cal.SetMethod(ics.MethodPublish)
+	cal.SetXWRCalName(conv.Title)
+
+	zones := &zoneSet{}
+
+	if it != nil {
+		if err := addItinerary(cal, zones, it); err != nil {
+			return "", err
+		}
+	}
+
+	for _, e := range conv.Events {
+		if err := addEvent(cal, zones, conv, e); err != nil {
+			return "", err
+		}
+	}
+
+	// Time zones must be declared before the components using them
+	events := cal.Components
+	cal.Components = nil
+	for _, tz := range zones.timezones() {
+		cal.AddVTimezone(tz)
+	}
+	cal.Components = append(cal.Components, events...)
+
+	// RFC 5545 requires CRLF line breaks regardless of the platform
+	return cal.Serialize(ics.WithNewLineWindows), nil
+}
+
+func addItinerary(cal *ics.Calendar, zones *zoneSet, it *model.Itinerary) error {
+	loc, err := zones.load(it.TimeZone)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range it.Days {
+		date, err := time.ParseInLocation(time.DateOnly, d.Date, loc)
+		if err != nil {
+			return fmt.Errorf("invalid itinerary date %q: %w", d.Date, err)
+		}
+
+		for _, item := range d.Items {
+			ev := cal.AddEvent(item.ID.Hex() + "@" + uidDomain)
+			ev.SetDtStampTime(it.UpdatedAt)
+			ev.SetSummary(item.Place)
+			ev.SetLocation(item.Place)
+			if item.Notes != "" {
+				ev.SetDescription(item.Notes)
+			}
+
+			if item.Time == "" {
+				ev.SetAllDayStartAt(date)
+				ev.SetAllDayEndAt(date.AddDate(0, 0, 1))
+				continue
+			}
+
+			start, err := time.ParseInLocation(time.DateOnly+" 15:04", d.Date+" "+item.Time, loc)
+			if err != nil {
+				return fmt.Errorf("invalid itinerary time %q: %w", item.Time, err)
+			}
+
+			end := start.Add(itemDuration)
+			setLocalTime(ev, ics.ComponentPropertyDtStart, start, it.TimeZone)
+			setLocalTime(ev, ics.ComponentPropertyDtEnd, end, it.TimeZone)
+			zones.observe(it.TimeZone, start)
+			zones.observe(it.TimeZone, end)
+		}
+	}
+
+	return nil
+}
+
+func addEvent(cal *ics.Calendar, zones *zoneSet, conv *model.Conversation, e *model.Event) error {
+	// Event times are absolute instants, so they are never written as floating times
+	zone := e.TimeZone
+	if zone == "" {
+		zone = "UTC"
+	}
+
+	loc, err := zones.load(zone)
+	if err != nil {
+		return err
+	}
+
+	ev := cal.AddEvent(e.ID.Hex() + "@" + uidDomain)
+	ev.SetDtStampTime(conv.UpdatedAt)
+	ev.SetSummary(e.Title)
+	if e.Location != "" {
+		ev.SetLocation(e.Location)
+	}
+	if e.Description != "" {
+		ev.SetDescription(e.Description)
+	}
+
+	setLocalTime(ev, ics.ComponentPropertyDtStart, e.Start.In(loc), zone)
+	zones.observe(zone, e.Start)
+
+	if !e.End.IsZero() {
+		setLocalTime(ev, ics.ComponentPropertyDtEnd, e.End.In(loc), zone)
+		zones.observe(zone, e.End)
+	}
+
+	return nil
+}
+
+// setLocalTime writes t as a local time referencing the given zone, or as a
+// floating time if there is no zone.
+func setLocalTime(ev *ics.VEvent, prop ics.ComponentProperty, t time.Time, zone string) {
+	switch zone {
+	case "":
+		ev.SetProperty(prop, t.Format(localTimeFormat))
+	case "UTC":
+		ev.SetProperty(prop, t.UTC().Format(localTimeFormat)+"Z")
+	default:
+		ev.SetProperty(prop, t.Format(localTimeFormat), ics.WithTZID(zone))
+	}
+}
@@ -0,0 +1,57 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestExport(t *testing.T) {
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+
+	conv := &model.Conversation{
+		ID:        primitive.NewObjectID(),
+		Title:     "Weekend in Lisbon",
+		UpdatedAt: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Events: []*model.Event{{
+			ID:       primitive.NewObjectID(),
+			Title:    "Flight BCN-LIS",
+			Start:    time.Date(2025, 4, 4, 18, 30, 0, 0, madrid),
+			End:      time.Date(2025, 4, 4, 20, 45, 0, 0, madrid),
+			TimeZone: "Europe/Madrid",
+		}},
+	}
+
+	it := model.NewItinerary(conv.ID)
+	it.TimeZone = "Europe/Lisbon"
+	it.AddItem("2025-04-05", &model.ItineraryItem{ID: primitive.NewObjectID(), Time: "10:00", Place: "Belem Tower"})
+	it.AddItem("2025-04-06", &model.ItineraryItem{ID: primitive.NewObjectID(), Place: "Sintra day trip"})
+
+	out, err := Export(conv, it)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Lisbon",
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Madrid",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20250330T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST",
+		"DTSTART;TZID=Europe/Lisbon:20250405T100000",
+		"DTEND;TZID=Europe/Lisbon:20250405T110000",
+		"DTSTART;VALUE=DATE:20250406",
+		"DTSTART;TZID=Europe/Madrid:20250404T183000",
+		"DTEND;TZID=Europe/Madrid:20250404T204500",
+		"SUMMARY:Flight BCN-LIS",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected calendar to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	if strings.Index(out, "BEGIN:VTIMEZONE") > strings.Index(out, "BEGIN:VEVENT") {
+		t.Error("expected time zones to be declared before events")
+	}
+}
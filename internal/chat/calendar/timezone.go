@@ -0,0 +1,159 @@
+package calendar
+
+import (
+	"fmt"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// zoneSet collects the time zones referenced by a calendar, together with the
+// range of instants each one is used for.
+type zoneSet struct {
+	names []string
+	spans map[string][2]time.Time
+}
+
+// load resolves an IANA time zone name. An empty name is a floating time and
+// is parsed as UTC.
+func (z *zoneSet) load(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q: %w", name, err)
+	}
+
+	return loc, nil
+}
+
+// observe records that the zone is used at instant t.
+func (z *zoneSet) observe(name string, t time.Time) {
+	if name == "" || name == "UTC" {
+		return
+	}
+
+	if z.spans == nil {
+		z.spans = map[string][2]time.Time{}
+	}
+
+	span, ok := z.spans[name]
+	if !ok {
+		z.names = append(z.names, name)
+		z.spans[name] = [2]time.Time{t, t}
+		return
+	}
+
+	if t.Before(span[0]) {
+		span[0] = t
+	}
+	if t.After(span[1]) {
+		span[1] = t
+	}
+	z.spans[name] = span
+}
+
+// timezones returns a VTIMEZONE component per observed zone, describing every
+// offset transition from the year before the first use to the year after the
+// last one, so all the instants in between resolve unambiguously.
+func (z *zoneSet) timezones() []*ics.VTimezone {
+	var out []*ics.VTimezone
+
+	for _, name := range z.names {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			continue // already validated by load
+		}
+
+		span := z.spans[name]
+		from := time.Date(span[0].In(loc).Year()-1, time.January, 1, 0, 0, 0, 0, loc)
+		to := time.Date(span[1].In(loc).Year()+1, time.December, 31, 0, 0, 0, 0, loc)
+
+		tz := ics.NewTimezone(name)
+
+		transitions := findTransitions(loc, from, to)
+		if len(transitions) == 0 {
+			// The zone has a fixed offset, a single observance covers everything
+			abbr, offset := from.Zone()
+			addObservance(tz, transition{at: time.Unix(0, 0), from: offset, to: offset, name: abbr})
+		}
+
+		for _, tr := range transitions {
+			addObservance(tz, tr)
+		}
+
+		out = append(out, tz)
+	}
+
+	return out
+}
+
+// transition is a change of UTC offset in a time zone.
+type transition struct {
+	at       time.Time
+	from, to int
+	name     string
+	dst      bool
+}
+
+func addObservance(tz *ics.VTimezone, tr transition) {
+	var c *ics.ComponentBase
+	if tr.dst {
+		d := &ics.Daylight{}
+		tz.Components = append(tz.Components, d)
+		c = &d.ComponentBase
+	} else {
+		s := ics.NewStandard()
+		tz.Components = append(tz.Components, s)
+		c = &s.ComponentBase
+	}
+
+	// DTSTART of an observance is the local time before the transition
+	start := tr.at.In(time.FixedZone("", tr.from))
+	c.SetProperty(ics.ComponentPropertyDtStart, start.Format(localTimeFormat))
+	c.SetProperty(ics.ComponentProperty(ics.PropertyTzoffsetfrom), formatOffset(tr.from))
+	c.SetProperty(ics.ComponentProperty(ics.PropertyTzoffsetto), formatOffset(tr.to))
+	c.SetProperty(ics.ComponentProperty(ics.PropertyTzname), tr.name)
+}
+
+// findTransitions scans the range a day at a time and narrows every offset
+// change down to the second.
+func findTransitions(loc *time.Location, from, to time.Time) []transition {
+	var out []transition
+
+	for t := from; t.Before(to); t = t.Add(24 * time.Hour) {
+		next := t.Add(24 * time.Hour)
+		_, before := t.In(loc).Zone()
+		_, after := next.In(loc).Zone()
+		if before == after {
+			continue
+		}
+
+		lo, hi := t, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, off := mid.In(loc).Zone(); off == before {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		at := hi.Truncate(time.Second).In(loc)
+		name, _ := at.Zone()
+		out = append(out, transition{at: at, from: before, to: after, name: name, dst: at.IsDST()})
+	}
+
+	return out
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
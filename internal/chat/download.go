@@ -0,0 +1,26 @@
+package chat
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/acai-travel/tech-challenge/internal/pb"
+	"github.com/gorilla/mux"
+	"github.com/twitchtv/twirp"
+)
+
+// CalendarHandler serves the iCalendar export of a conversation as a file
+// download. The route must define an {id} variable with the conversation ID.
+func (s *Server) CalendarHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, err := s.ExportCalendar(r.Context(), &pb.ExportCalendarRequest{ConversationId: mux.Vars(r)["id"]})
+		if err != nil {
+			_ = twirp.WriteError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", out.GetContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", out.GetFilename()))
+		_, _ = w.Write([]byte(out.GetContent()))
+	})
+}
@@ -0,0 +1,91 @@
+// Package embedding turns text into vectors, so it can be searched by meaning.
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/acai-travel/tech-challenge/internal/pii"
+	"github.com/openai/openai-go/v2"
+)
+
+// Embedder returns one vector per text, in the same order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Redacted returns an embedder masking the personal data r finds before the
+// texts are sent to e, e.g. the messages indexed for recall. Masked data
+// only weighs as its kind in the vectors, the texts stored are unchanged.
+func Redacted(e Embedder, r *pii.Redactor) Embedder {
+	return &redacted{Embedder: e, redactor: r}
+}
+
+type redacted struct {
+	Embedder
+	redactor *pii.Redactor
+}
+
+func (e *redacted) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	masked := make([]string, len(texts))
+	for i, t := range texts {
+		masked[i] = e.redactor.Mask(t)
+	}
+	return e.Embedder.Embed(ctx, masked)
+}
+
+// batchSize keeps requests well below the limit of inputs per request of the
+// OpenAI embeddings API.
+const batchSize = 256
+
+var _ Embedder = (*OpenAI)(nil)
+
+type OpenAI struct {
+	cli   openai.Client
+	model openai.EmbeddingModel
+}
+
+// NewOpenAI returns an embedder using the OpenAI embeddings API with the
+// model, e.g. text-embedding-3-small. Vectors of different models can't be
+// compared, so changing it requires re-indexing.
+func NewOpenAI(cli openai.Client, model string) *OpenAI {
+	return &OpenAI{cli: cli, model: openai.EmbeddingModel(model)}
+}
+
+func (e *OpenAI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+
+		resp, err := e.cli.Embeddings.New(ctx, openai.EmbeddingNewParams{
+			Model: e.model,
+			Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: batch},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(resp.Data) != len(batch) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(resp.Data))
+		}
+
+		out := make([][]float32, len(batch))
+		for _, d := range resp.Data {
+			if d.Index < 0 || int(d.Index) >= len(batch) {
+				return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+			}
+
+			v := make([]float32, len(d.Embedding))
+			for i, x := range d.Embedding {
+				v[i] = float32(x)
+			}
+			out[d.Index] = v
+		}
+
+		vectors = append(vectors, out...)
+	}
+
+	return vectors, nil
+}
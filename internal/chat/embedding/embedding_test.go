@@ -0,0 +1,37 @@
+package embedding
+
+import (
+	"context"
+	"testing"
+
+	"github.com/acai-travel/tech-challenge/internal/pii"
+	"github.com/google/go-cmp/cmp"
+)
+
+// recorder records the texts it embeds.
+type recorder struct {
+	texts []string
+}
+
+func (r *recorder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	r.texts = append(r.texts, texts...)
+	return make([][]float32, len(texts)), nil
+}
+
+func TestRedacted(t *testing.T) {
+	rec := &recorder{}
+	e := Redacted(rec, pii.New())
+
+	vectors, err := e.Embed(context.Background(), []string{"Book for jane@example.com", "Hotels in Lisbon"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(vectors) != 2 {
+		t.Errorf("expected 2 vectors, got %d", len(vectors))
+	}
+
+	if diff := cmp.Diff([]string{"Book for [EMAIL]", "Hotels in Lisbon"}, rec.texts); diff != "" {
+		t.Errorf("unexpected embedded texts (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,65 @@
+package knowledge
+
+import (
+	"strings"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+)
+
+const (
+	// chunkSize is the target length of a passage in bytes. Passages are long
+	// enough to hold a rule with its context, and short enough for a few of
+	// them to fit in a reply prompt.
+	chunkSize = 1200
+
+	// chunkOverlap repeats the end of a passage at the start of the next one,
+	// so sentences cut at a boundary can still be found.
+	chunkOverlap = 200
+)
+
+// Chunk is a passage of a document, embedded and retrieved as a unit.
+type Chunk struct {
+	Index   int
+	Page    int
+	Heading string
+	Text    string
+}
+
+// Split cuts the sections into overlapping passages of about size bytes.
+// Passages never span sections, so each has a single page and heading.
+func Split(sections []*model.DocumentSection, size, overlap int) []*Chunk {
+	var chunks []*Chunk
+
+	for _, s := range sections {
+		words := strings.Fields(s.Text)
+
+		for start := 0; start < len(words); {
+			end, length := start, 0
+			for end < len(words) && (end == start || length+1+len(words[end]) <= size) {
+				length += 1 + len(words[end])
+				end++
+			}
+
+			chunks = append(chunks, &Chunk{
+				Index:   len(chunks),
+				Page:    s.Page,
+				Heading: s.Heading,
+				Text:    strings.Join(words[start:end], " "),
+			})
+
+			if end == len(words) {
+				break
+			}
+
+			// Step back over the last words for the overlap, but always move forward
+			next, back := end, 0
+			for next > start+1 && back+1+len(words[next-1]) <= overlap {
+				back += 1 + len(words[next-1])
+				next--
+			}
+			start = next
+		}
+	}
+
+	return chunks
+}
@@ -0,0 +1,77 @@
+package knowledge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+)
+
+func TestExtract_Markdown(t *testing.T) {
+	doc := "Intro line.\n\n# Cabin baggage\nOne bag of 10kg.\n\n```sh\n# not a heading\n```\n\n## Checked baggage\nTwo bags of 23kg.\n"
+
+	sections, err := Extract(model.DocumentMarkdown, []byte(doc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var headings []string
+	for _, s := range sections {
+		headings = append(headings, s.Heading)
+	}
+
+	if got, want := strings.Join(headings, "|"), "|Cabin baggage|Checked baggage"; got != want {
+		t.Fatalf("headings = %q, want %q", got, want)
+	}
+
+	if !strings.Contains(sections[1].Text, "# not a heading") {
+		t.Errorf("code block comment should stay in the section text, got %q", sections[1].Text)
+	}
+}
+
+func TestExtract_Empty(t *testing.T) {
+	if _, err := Extract(model.DocumentText, []byte(" \n\t")); err == nil {
+		t.Fatal("expected an error for a document without text")
+	}
+
+	if _, err := Extract(model.DocumentPDF, []byte("not a pdf")); err == nil {
+		t.Fatal("expected an error for an invalid PDF")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	words := make([]string, 100)
+	for i := range words {
+		words[i] = "word"
+	}
+
+	sections := []*model.DocumentSection{
+		{Page: 1, Text: strings.Join(words, " ")},
+		{Page: 2, Text: "Short page."},
+	}
+
+	chunks := Split(sections, 100, 20)
+
+	for i, c := range chunks {
+		if c.Index != i {
+			t.Errorf("chunk %d has index %d", i, c.Index)
+		}
+		if len(c.Text) > 100 {
+			t.Errorf("chunk %d is %d bytes long, want at most 100", i, len(c.Text))
+		}
+	}
+
+	last := chunks[len(chunks)-1]
+	if last.Page != 2 || last.Text != "Short page." {
+		t.Errorf("last chunk = %+v, want the second page on its own", last)
+	}
+
+	// 100 words of 5 bytes, in chunks of 20 words stepping back 4 words
+	var total int
+	for _, c := range chunks[:len(chunks)-1] {
+		total += len(strings.Fields(c.Text))
+	}
+	if want := 100 + 4*(len(chunks)-2); total != want {
+		t.Errorf("page 1 chunks hold %d words, want %d with overlaps", total, want)
+	}
+}
@@ -0,0 +1,126 @@
+package knowledge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/ledongthuc/pdf"
+)
+
+// Extract returns the text of the file, split in sections: one per page for
+// PDF documents, one per heading for Markdown documents and a single one for
+// plain text.
+func Extract(format model.DocumentFormat, data []byte) ([]*model.DocumentSection, error) {
+	var sections []*model.DocumentSection
+	var err error
+
+	switch format {
+	case model.DocumentText:
+		if !utf8.Valid(data) {
+			return nil, errors.New("text documents must be UTF-8 encoded")
+		}
+		sections = []*model.DocumentSection{{Text: string(data)}}
+
+	case model.DocumentMarkdown:
+		if !utf8.Valid(data) {
+			return nil, errors.New("markdown documents must be UTF-8 encoded")
+		}
+		sections = extractMarkdown(string(data))
+
+	case model.DocumentPDF:
+		if sections, err = extractPDF(data); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	// Drop empty sections, e.g. scanned PDF pages without a text layer
+	out := sections[:0]
+	for _, s := range sections {
+		s.Text = strings.TrimSpace(s.Text)
+		if s.Text != "" {
+			out = append(out, s)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("document has no text")
+	}
+
+	return out, nil
+}
+
+// extractMarkdown starts a new section at every heading, so passages can be
+// cited with the heading they are under.
+func extractMarkdown(text string) []*model.DocumentSection {
+	current := &model.DocumentSection{}
+	sections := []*model.DocumentSection{current}
+	inCode := false
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// Lines starting with # in code blocks are comments, not headings
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+
+		if !inCode && strings.HasPrefix(trimmed, "#") {
+			heading := strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			if heading != "" {
+				current = &model.DocumentSection{Heading: heading}
+				sections = append(sections, current)
+				continue
+			}
+		}
+
+		current.Text += line + "\n"
+	}
+
+	return sections
+}
+
+func extractPDF(data []byte) (sections []*model.DocumentSection, err error) {
+	// The PDF parser panics on some malformed files
+	defer func() {
+		if v := recover(); v != nil {
+			sections, err = nil, fmt.Errorf("invalid PDF: %v", v)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PDF: %w", err)
+	}
+
+	fonts := map[string]*pdf.Font{}
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+
+		// Fonts are shared between pages, cache them so they're parsed once
+		for _, name := range p.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				f := p.Font(name)
+				fonts[name] = &f
+			}
+		}
+
+		text, err := p.GetPlainText(fonts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d: %w", i, err)
+		}
+
+		sections = append(sections, &model.DocumentSection{Page: i, Text: text})
+	}
+
+	return sections, nil
+}
@@ -0,0 +1,199 @@
+// Package knowledge is the document knowledge base the assistant answers
+// from, e.g. internal travel and expense policies. Documents are split into
+// passages, which are embedded and retrieved by meaning.
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/embedding"
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/vector"
+	"github.com/twitchtv/twirp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Namespace is the vector store namespace holding the passage embeddings.
+const Namespace = "documents"
+
+// MinScore is the similarity below which passages are considered unrelated
+// to the query.
+const MinScore = 0.3
+
+// Passage is a part of a document relevant to a query.
+type Passage struct {
+	DocumentID    primitive.ObjectID
+	DocumentTitle string
+	Page          int
+	Heading       string
+	Text          string
+	Score         float64
+}
+
+type Base struct {
+	repo     *model.Repository
+	embedder embedding.Embedder
+	store    vector.Store
+}
+
+func New(repo *model.Repository, embedder embedding.Embedder, store vector.Store) *Base {
+	return &Base{repo: repo, embedder: embedder, store: store}
+}
+
+// Add extracts the text of the file, indexes its passages and stores the
+// document. Files without extractable text are rejected as invalid.
+func (b *Base) Add(ctx context.Context, title, filename string, format model.DocumentFormat, data []byte) (*model.Document, error) {
+	sections, err := Extract(format, data)
+	if err != nil {
+		return nil, twirp.InvalidArgumentError("content", err.Error())
+	}
+
+	doc := &model.Document{
+		ID:        primitive.NewObjectID(),
+		Title:     title,
+		Filename:  filename,
+		Format:    format,
+		Size:      int64(len(data)),
+		Sections:  sections,
+		CreatedAt: time.Now(),
+	}
+
+	if err := b.index(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	if err := b.repo.CreateDocument(ctx, doc); err != nil {
+		_ = b.store.Delete(ctx, Namespace, vector.Filter{"document_id": doc.ID.Hex()})
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// Delete removes the document and its passages.
+func (b *Base) Delete(ctx context.Context, id string) error {
+	if err := b.repo.DeleteDocument(ctx, id); err != nil {
+		return err
+	}
+
+	return b.store.Delete(ctx, Namespace, vector.Filter{"document_id": id})
+}
+
+// Reindex embeds the passages of all stored documents again, e.g. to fill an
+// in-memory vector store on startup. A failing document is logged and
+// skipped, the others are still indexed. It returns how many documents were
+// indexed, with the errors of the skipped ones joined.
+func (b *Base) Reindex(ctx context.Context) (int, error) {
+	docs, err := b.repo.ListDocuments(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	count := 0
+
+	for _, d := range docs {
+		if err := b.reindex(ctx, d.ID.Hex()); err != nil {
+			slog.ErrorContext(ctx, "Failed to index document", "document_id", d.ID.Hex(), "title", d.Title, "error", err)
+			errs = append(errs, fmt.Errorf("document %s: %w", d.ID.Hex(), err))
+			continue
+		}
+		count++
+	}
+
+	return count, errors.Join(errs...)
+}
+
+func (b *Base) reindex(ctx context.Context, id string) error {
+	// Listed documents don't include their text
+	doc, err := b.repo.DescribeDocument(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return b.index(ctx, doc)
+}
+
+func (b *Base) index(ctx context.Context, doc *model.Document) error {
+	chunks := Split(doc.Sections, chunkSize, chunkOverlap)
+
+	// The title and heading give context to passages that don't repeat them
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = strings.Join(nonEmpty(doc.Title, c.Heading, c.Text), "\n\n")
+	}
+
+	vectors, err := b.embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+
+	records := make([]vector.Record, len(chunks))
+	for i, c := range chunks {
+		records[i] = vector.Record{
+			ID:     doc.ID.Hex() + "/" + strconv.Itoa(c.Index),
+			Vector: vectors[i],
+			Text:   c.Text,
+			Metadata: map[string]string{
+				"document_id": doc.ID.Hex(),
+				"title":       doc.Title,
+				"page":        strconv.Itoa(c.Page),
+				"heading":     c.Heading,
+			},
+		}
+	}
+
+	doc.Chunks = len(chunks)
+	return b.store.Upsert(ctx, Namespace, records...)
+}
+
+// Search returns up to k passages closest in meaning to the query, best first.
+func (b *Base) Search(ctx context.Context, query string, k int) ([]*Passage, error) {
+	vectors, err := b.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := b.store.Query(ctx, Namespace, vectors[0], k, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	passages := make([]*Passage, 0, len(matches))
+	for _, m := range matches {
+		if m.Score < MinScore {
+			break
+		}
+
+		p := &Passage{
+			DocumentTitle: m.Metadata["title"],
+			Heading:       m.Metadata["heading"],
+			Text:          m.Text,
+			Score:         m.Score,
+		}
+
+		// Records are only written by index, ignore what can't be parsed
+		p.DocumentID, _ = primitive.ObjectIDFromHex(m.Metadata["document_id"])
+		p.Page, _ = strconv.Atoi(m.Metadata["page"])
+
+		passages = append(passages, p)
+	}
+
+	return passages, nil
+}
+
+func nonEmpty(values ...string) []string {
+	out := values[:0]
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker stops calling a model after threshold consecutive calls failed as
+// unavailable or rate limited. Once the cooldown passes, a single trial call
+// goes through: the breaker closes if it succeeds and opens again otherwise.
+type breaker struct {
+	key       string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool // a trial call is in flight
+}
+
+// allow reports whether the model can be called.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state, b.trial = stateHalfOpen, true
+		return true
+
+	case stateHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of an allowed call. Permanent
+// errors mean the model answered, so they count as successes, while
+// cancelled calls don't count at all.
+func (b *breaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	if err == nil || classify(err) == failPermanent {
+		if b.state != stateClosed {
+			slog.InfoContext(ctx, "Circuit breaker closed", "breaker", b.key)
+		}
+		b.state, b.failures = stateClosed, 0
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		if b.state != stateOpen {
+			slog.WarnContext(ctx, "Circuit breaker opened", "breaker", b.key, "failures", b.failures, "cooldown", b.cooldown)
+		}
+		b.state, b.openedAt = stateOpen, b.now()
+	}
+}
@@ -0,0 +1,227 @@
+// Package llm makes chat completion calls resilient: transient failures are
+// retried with jittered backoff, each provider and model has a circuit
+// breaker, and a chain of fallback models answers when a model can't.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+var (
+	// ErrUnavailable is returned when no model of the chain could be reached,
+	// because of server errors, network errors or open circuit breakers.
+	ErrUnavailable = errors.New("language model unavailable")
+
+	// ErrRateLimited is returned when the provider keeps rejecting requests
+	// for exceeding its rate limits or quota.
+	ErrRateLimited = errors.New("language model rate limit exceeded")
+
+	// ErrCircuitOpen is returned for a model whose circuit breaker is open.
+	ErrCircuitOpen = fmt.Errorf("%w: circuit breaker open", ErrUnavailable)
+)
+
+const (
+	defaultAttempts  = 3
+	defaultBaseDelay = 500 * time.Millisecond
+	defaultMaxDelay  = 8 * time.Second
+	defaultThreshold = 5
+	defaultCooldown  = 30 * time.Second
+
+	// maxRetryAfter is the longest Retry-After honored, longer waits fail
+	// the call or move on to the next model
+	maxRetryAfter = 30 * time.Second
+)
+
+// CompleteFunc creates a chat completion with a single request.
+type CompleteFunc func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error)
+
+type Client struct {
+	complete CompleteFunc
+	provider string
+
+	attempts            int
+	baseDelay, maxDelay time.Duration
+	threshold           int
+	cooldown            time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+
+	sleep func(ctx context.Context, d time.Duration) error
+	now   func() time.Time
+}
+
+type Option func(*Client)
+
+// WithAttempts sets how many times a model is called before giving up on it.
+func WithAttempts(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.attempts = n
+		}
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled for each next
+// one up to max. Delays are jittered.
+func WithBackoff(base, max time.Duration) Option {
+	return func(c *Client) {
+		if base > 0 && max >= base {
+			c.baseDelay, c.maxDelay = base, max
+		}
+	}
+}
+
+// WithBreaker sets how many consecutive failed calls open the circuit
+// breaker of a model, and how long it stays open before a trial call.
+func WithBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		if threshold > 0 && cooldown > 0 {
+			c.threshold, c.cooldown = threshold, cooldown
+		}
+	}
+}
+
+// New returns a client calling complete, the provider names the circuit
+// breakers of its models.
+func New(provider string, complete CompleteFunc, opts ...Option) *Client {
+	c := &Client{
+		complete:  complete,
+		provider:  provider,
+		attempts:  defaultAttempts,
+		baseDelay: defaultBaseDelay,
+		maxDelay:  defaultMaxDelay,
+		threshold: defaultThreshold,
+		cooldown:  defaultCooldown,
+		breakers:  map[string]*breaker{},
+		sleep:     sleep,
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// NewOpenAI returns a client of the OpenAI API. The retries of the SDK are
+// disabled, the client retries itself.
+func NewOpenAI(cli openai.Client, opts ...Option) *Client {
+	return New("openai", func(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+		return cli.Chat.Completions.New(ctx, params, option.WithMaxRetries(0))
+	}, opts...)
+}
+
+// Complete creates a chat completion with the first model of the chain that
+// answers. A model is skipped while its circuit breaker is open, and the next
+// one is tried when it stays unavailable or rate limited after retries.
+// Other errors, e.g. invalid requests, are returned right away.
+//
+// Errors wrap ErrUnavailable or ErrRateLimited when the chain is exhausted.
+func (c *Client) Complete(ctx context.Context, models []string, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	if len(models) == 0 {
+		return nil, errors.New("no model to complete with")
+	}
+
+	var last error
+	for i, model := range models {
+		b := c.breaker(model)
+		if !b.allow() {
+			last = fmt.Errorf("model %s: %w", model, ErrCircuitOpen)
+			slog.WarnContext(ctx, "Skipping model with open circuit breaker", "provider", c.provider, "model", model)
+			continue
+		}
+
+		params.Model = model
+		resp, err := c.retry(ctx, params)
+		b.record(ctx, err)
+
+		if err == nil {
+			if i > 0 {
+				slog.InfoContext(ctx, "Completed with fallback model", "provider", c.provider, "model", model)
+			}
+			return resp, nil
+		}
+
+		last = fmt.Errorf("model %s: %w", model, err)
+		if ctx.Err() != nil || classify(err) == failPermanent {
+			break
+		}
+	}
+
+	switch {
+	case errors.Is(last, ErrUnavailable):
+		return nil, last
+	case classify(last) == failRateLimited:
+		return nil, fmt.Errorf("%w: %w", ErrRateLimited, last)
+	case classify(last) == failUnavailable:
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, last)
+	default:
+		return nil, last
+	}
+}
+
+// retry calls the model until it succeeds, fails permanently or runs out of
+// attempts. Waits that would outlast the deadline of the context fail early.
+func (c *Client) retry(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.traced(ctx, attempt, params)
+		if err == nil {
+			return resp, nil
+		}
+
+		if attempt >= c.attempts || !retryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay, ok := c.backoff(attempt, err)
+		if !ok {
+			return nil, err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && c.now().Add(delay).After(deadline) {
+			return nil, err
+		}
+
+		slog.WarnContext(ctx, "Retrying model call", "provider", c.provider, "model", params.Model,
+			"attempt", attempt, "delay", delay, "error", err)
+
+		if err := c.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) breaker(model string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.provider + "/" + model
+	b, ok := c.breakers[key]
+	if !ok {
+		b = &breaker{key: key, threshold: c.threshold, cooldown: c.cooldown, now: c.now}
+		c.breakers[key] = b
+	}
+	return b
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
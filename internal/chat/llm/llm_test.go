@@ -0,0 +1,231 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// apiError returns an error of the API with the status and headers.
+func apiError(status int, header http.Header) error {
+	return &openai.Error{
+		StatusCode: status,
+		Request:    &http.Request{Method: http.MethodPost, URL: &url.URL{Path: "/chat/completions"}},
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+// fakeModels answers with the errors queued for each model, then succeeds.
+type fakeModels struct {
+	errs  map[string][]error
+	calls []string
+}
+
+func (f *fakeModels) complete(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	f.calls = append(f.calls, params.Model)
+
+	if errs := f.errs[params.Model]; len(errs) > 0 {
+		f.errs[params.Model] = errs[1:]
+		return nil, errs[0]
+	}
+
+	return &openai.ChatCompletion{Model: params.Model}, nil
+}
+
+func newTestClient(f *fakeModels, opts ...Option) (*Client, *[]time.Duration) {
+	c := New("test", f.complete, opts...)
+
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	return c, &waits
+}
+
+func TestClient_Complete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retries transient errors honoring Retry-After", func(t *testing.T) {
+		f := &fakeModels{errs: map[string][]error{
+			"gpt": {apiError(429, http.Header{"Retry-After": {"2"}}), apiError(503, nil)},
+		}}
+		c, waits := newTestClient(f)
+
+		resp, err := c.Complete(ctx, []string{"gpt"}, openai.ChatCompletionNewParams{})
+		if err != nil || resp.Model != "gpt" {
+			t.Fatalf("got %v, %v", resp, err)
+		}
+
+		if len(*waits) != 2 || (*waits)[0] != 2*time.Second {
+			t.Errorf("unexpected waits %v", *waits)
+		}
+		if d := (*waits)[1]; d < defaultBaseDelay || d > 2*defaultBaseDelay {
+			t.Errorf("got backoff %s, want between %s and %s", d, defaultBaseDelay, 2*defaultBaseDelay)
+		}
+	})
+
+	t.Run("doesn't retry invalid requests", func(t *testing.T) {
+		f := &fakeModels{errs: map[string][]error{"gpt": {apiError(400, nil)}}}
+		c, _ := newTestClient(f)
+
+		_, err := c.Complete(ctx, []string{"gpt", "fallback"}, openai.ChatCompletionNewParams{})
+		if err == nil || errors.Is(err, ErrUnavailable) || errors.Is(err, ErrRateLimited) {
+			t.Fatalf("expected the invalid request error, got %v", err)
+		}
+		if len(f.calls) != 1 {
+			t.Errorf("got calls %v, want a single one", f.calls)
+		}
+	})
+
+	t.Run("falls back to the next model", func(t *testing.T) {
+		f := &fakeModels{errs: map[string][]error{"gpt": {apiError(500, nil), apiError(502, nil), apiError(503, nil)}}}
+		c, _ := newTestClient(f)
+
+		resp, err := c.Complete(ctx, []string{"gpt", "fallback"}, openai.ChatCompletionNewParams{})
+		if err != nil || resp.Model != "fallback" {
+			t.Fatalf("got %v, %v", resp, err)
+		}
+	})
+
+	t.Run("reports rate limits once the chain is exhausted", func(t *testing.T) {
+		f := &fakeModels{errs: map[string][]error{"gpt": {apiError(429, nil), apiError(429, nil)}}}
+		c, _ := newTestClient(f, WithAttempts(2))
+
+		_, err := c.Complete(ctx, []string{"gpt"}, openai.ChatCompletionNewParams{})
+		if !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("expected a rate limit error, got %v", err)
+		}
+	})
+
+	t.Run("gives up when Retry-After outlasts the deadline", func(t *testing.T) {
+		f := &fakeModels{errs: map[string][]error{"gpt": {apiError(429, http.Header{"Retry-After": {"10"}})}}}
+		c, waits := newTestClient(f)
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		if _, err := c.Complete(ctx, []string{"gpt"}, openai.ChatCompletionNewParams{}); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("expected a rate limit error, got %v", err)
+		}
+		if len(*waits) != 0 {
+			t.Errorf("expected no wait, got %v", *waits)
+		}
+	})
+}
+
+func TestClient_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	f := &fakeModels{errs: map[string][]error{"gpt": {apiError(503, nil)}}}
+	c, _ := newTestClient(f)
+
+	if _, err := c.Complete(context.Background(), []string{"gpt"}, openai.ChatCompletionNewParams{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want one per attempt", len(spans))
+	}
+
+	if spans[0].Name() != "chat gpt" || spans[0].Status().Code != codes.Error {
+		t.Errorf("expected a failed span of the first attempt, got %s %v", spans[0].Name(), spans[0].Status())
+	}
+
+	attrs := attribute.NewSet(spans[1].Attributes()...)
+	if v, _ := attrs.Value("gen_ai.response.model"); v.AsString() != "gpt" {
+		t.Errorf("got response model %q", v.AsString())
+	}
+	if v, _ := attrs.Value("gen_ai.request.attempt"); v.AsInt64() != 2 {
+		t.Errorf("got attempt %d, want 2", v.AsInt64())
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	f := &fakeModels{errs: map[string][]error{}}
+	c, _ := newTestClient(f, WithAttempts(1), WithBreaker(2, time.Minute))
+	c.now = func() time.Time { return now }
+
+	fail := func(n int) {
+		for range n {
+			f.errs["gpt"] = append(f.errs["gpt"], apiError(500, nil))
+		}
+	}
+
+	fail(2)
+	for range 2 {
+		if _, err := c.Complete(ctx, []string{"gpt"}, openai.ChatCompletionNewParams{}); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("expected an unavailable error, got %v", err)
+		}
+	}
+
+	calls := len(f.calls)
+	if _, err := c.Complete(ctx, []string{"gpt"}, openai.ChatCompletionNewParams{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the circuit to be open, got %v", err)
+	}
+	if len(f.calls) != calls {
+		t.Error("expected no call while the circuit is open")
+	}
+
+	resp, err := c.Complete(ctx, []string{"gpt", "fallback"}, openai.ChatCompletionNewParams{})
+	if err != nil || resp.Model != "fallback" {
+		t.Fatalf("expected the fallback to answer, got %v, %v", resp, err)
+	}
+
+	// After the cooldown, a failed trial opens the circuit again and a
+	// successful one closes it
+	now = now.Add(time.Minute)
+	fail(1)
+	if _, err := c.Complete(ctx, []string{"gpt"}, openai.ChatCompletionNewParams{}); errors.Is(err, ErrCircuitOpen) || err == nil {
+		t.Fatalf("expected the trial call to fail, got %v", err)
+	}
+	if _, err := c.Complete(ctx, []string{"gpt"}, openai.ChatCompletionNewParams{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the circuit to be open again, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := c.Complete(ctx, []string{"gpt"}, openai.ChatCompletionNewParams{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for header, want := range map[string]time.Duration{
+		"Retry-After-Ms:1500": 1500 * time.Millisecond,
+		"Retry-After:3":       3 * time.Second,
+		"Retry-After:" + now.Add(5*time.Second).Format(http.TimeFormat): 5 * time.Second,
+	} {
+		name, value, _ := strings.Cut(header, ":")
+		got, ok := retryAfter(apiError(429, http.Header{http.CanonicalHeaderKey(name): {value}}), now)
+		if !ok || got != want {
+			t.Errorf("retryAfter(%s) = %s, %v, want %s", header, got, ok, want)
+		}
+	}
+
+	if _, ok := retryAfter(apiError(429, http.Header{}), now); ok {
+		t.Error("expected no delay without headers")
+	}
+}
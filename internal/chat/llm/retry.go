@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// failure classifies the errors of a model call.
+type failure int
+
+const (
+	// failPermanent errors fail the same way when retried, e.g. invalid requests
+	failPermanent failure = iota
+
+	// failUnavailable errors are server or network errors
+	failUnavailable
+
+	// failRateLimited errors are rejections for exceeding rate limits
+	failRateLimited
+)
+
+func classify(err error) failure {
+	if errors.Is(err, ErrUnavailable) {
+		return failUnavailable
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return failPermanent
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusTooManyRequests:
+			return failRateLimited
+		case code == http.StatusRequestTimeout, code == http.StatusConflict, code >= 500:
+			return failUnavailable
+		default:
+			return failPermanent
+		}
+	}
+
+	// Errors without a response, e.g. connection resets
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return failUnavailable
+	}
+
+	return failPermanent
+}
+
+// retryable reports whether calling the model again may succeed. Exhausted
+// quotas are rate limit errors that don't recover by waiting.
+func retryable(err error) bool {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.Code == "insufficient_quota" {
+		return false
+	}
+
+	return classify(err) != failPermanent
+}
+
+// backoff returns the delay before the next attempt: the Retry-After of the
+// response when set, otherwise an exponential delay with jitter. It reports
+// false when the server asks to wait longer than maxRetryAfter.
+func (c *Client) backoff(attempt int, err error) (time.Duration, bool) {
+	if d, ok := retryAfter(err, c.now()); ok {
+		return d, d <= maxRetryAfter
+	}
+
+	d := c.baseDelay << (attempt - 1)
+	if d <= 0 || d > c.maxDelay {
+		d = c.maxDelay
+	}
+
+	// Equal jitter: half fixed, half random, so retries of concurrent calls
+	// spread out without retrying right away
+	return d/2 + rand.N(d/2+1), true
+}
+
+// retryAfter reads how long to wait from the retry-after-ms or Retry-After
+// headers of the response, the latter in seconds or as an HTTP date.
+func retryAfter(err error, now time.Time) (time.Duration, bool) {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.Response == nil {
+		return 0, false
+	}
+
+	header := apiErr.Response.Header
+
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if s, err := strconv.ParseFloat(value, 64); err == nil && s >= 0 {
+		return time.Duration(s * float64(time.Second)), true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/openai/openai-go/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("acai.chat.llm")
+
+// traced calls the model within a client span named after it, e.g. "chat gpt-4.1",
+// recording the models and token usage of the completion.
+func (c *Client) traced(ctx context.Context, attempt int, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	ctx, span := tracer.Start(ctx, "chat "+params.Model,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gen_ai.operation.name", "chat"),
+			attribute.String("gen_ai.system", c.provider),
+			attribute.String("gen_ai.request.model", params.Model),
+			attribute.Int("gen_ai.request.attempt", attempt),
+		),
+	)
+	defer span.End()
+
+	resp, err := c.complete(ctx, params)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("gen_ai.response.id", resp.ID),
+		attribute.String("gen_ai.response.model", resp.Model),
+		attribute.Int64("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+		attribute.Int64("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+	)
+
+	return resp, nil
+}
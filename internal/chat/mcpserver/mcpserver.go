@@ -0,0 +1,288 @@
+// Package mcpserver exposes the chat service as an MCP server, so desktop
+// agents can start, continue and read Acai conversations and use the travel
+// tools of the assistant.
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat"
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"github.com/acai-travel/tech-challenge/internal/mcp"
+	"github.com/acai-travel/tech-challenge/internal/pb"
+)
+
+const instructions = "Acai is a travel assistant. Use start_conversation to ask it something, and continue_conversation " +
+	"with the returned conversation ID to follow up. Conversations are stored and can be listed and read back. " +
+	"Replies can wait for the user to approve actions with approve_action or reject_action, never decide for the user."
+
+// New returns an MCP server with the conversation tools, backed by the chat
+// server, and the given tools, e.g. the stateless travel tools of the
+// assistant. Arguments are validated against the schema of the tools.
+func New(srv *chat.Server, tools ...registry.Tool) (*mcp.Server, error) {
+	r := registry.New()
+
+	for _, t := range []registry.Tool{
+		registry.Typed[StartArgs](&startTool{srv: srv}),
+		registry.Typed[ContinueArgs](&continueTool{srv: srv}),
+		registry.Typed[ListArgs](&listTool{srv: srv}),
+		registry.Typed[DescribeArgs](&describeTool{srv: srv}),
+		registry.Typed[ApproveArgs](&approveTool{srv: srv}),
+		registry.Typed[RejectArgs](&rejectTool{srv: srv}),
+	} {
+		if err := r.Register(t); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range tools {
+		if err := r.Register(t); err != nil {
+			return nil, err
+		}
+	}
+
+	s := mcp.NewServer(mcp.Implementation{Name: "acai", Version: "1.0.0"}, instructions)
+	for _, info := range r.Tools() {
+		name := info.Name
+		s.AddTool(
+			mcp.Tool{Name: name, Description: info.Description, InputSchema: info.Parameters},
+			func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				return r.Execute(ctx, name, string(arguments))
+			},
+		)
+	}
+
+	return s, nil
+}
+
+type StartArgs struct {
+	Message string `json:"message" description:"First message of the user"`
+}
+
+type startTool struct {
+	srv *chat.Server
+}
+
+func (t *startTool) Name() string {
+	return "start_conversation"
+}
+
+func (t *startTool) Description() string {
+	return "Starts a new conversation with the Acai travel assistant. Returns the conversation ID, its title and the reply of the assistant."
+}
+
+func (t *startTool) Call(ctx context.Context, args StartArgs) (string, error) {
+	out, err := t.srv.StartConversation(ctx, &pb.StartConversationRequest{Message: args.Message})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Conversation ID: %s\nTitle: %s\n\n%s", out.GetConversationId(), out.GetTitle(),
+		replyText(out.GetReply(), out.GetCitations(), out.GetPendingActions())), nil
+}
+
+type ContinueArgs struct {
+	ConversationID string `json:"conversation_id" description:"ID of the conversation, as returned by start_conversation or list_conversations"`
+	Message        string `json:"message" description:"Next message of the user"`
+}
+
+type continueTool struct {
+	srv *chat.Server
+}
+
+func (t *continueTool) Name() string {
+	return "continue_conversation"
+}
+
+func (t *continueTool) Description() string {
+	return "Sends a follow-up message in an existing Acai conversation and returns the reply of the assistant."
+}
+
+func (t *continueTool) Call(ctx context.Context, args ContinueArgs) (string, error) {
+	out, err := t.srv.ContinueConversation(ctx, &pb.ContinueConversationRequest{
+		ConversationId: args.ConversationID,
+		Message:        args.Message,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return replyText(out.GetReply(), out.GetCitations(), out.GetPendingActions()), nil
+}
+
+type ListArgs struct{}
+
+type listTool struct {
+	srv *chat.Server
+}
+
+func (t *listTool) Name() string {
+	return "list_conversations"
+}
+
+func (t *listTool) Description() string {
+	return "Lists the Acai conversations with their ID, last update and title."
+}
+
+func (t *listTool) Call(ctx context.Context, args ListArgs) (string, error) {
+	out, err := t.srv.ListConversations(ctx, &pb.ListConversationsRequest{})
+	if err != nil {
+		return "", err
+	}
+
+	if len(out.GetConversations()) == 0 {
+		return "There are no conversations.", nil
+	}
+
+	var sb strings.Builder
+	for _, c := range out.GetConversations() {
+		fmt.Fprintf(&sb, "%s  %s  %s\n", c.GetId(), c.GetTimestamp().AsTime().Format(time.RFC3339), c.GetTitle())
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+type DescribeArgs struct {
+	ConversationID string `json:"conversation_id" description:"ID of the conversation"`
+}
+
+type describeTool struct {
+	srv *chat.Server
+}
+
+func (t *describeTool) Name() string {
+	return "describe_conversation"
+}
+
+func (t *describeTool) Description() string {
+	return "Returns the title and all messages of an Acai conversation."
+}
+
+func (t *describeTool) Call(ctx context.Context, args DescribeArgs) (string, error) {
+	out, err := t.srv.DescribeConversation(ctx, &pb.DescribeConversationRequest{ConversationId: args.ConversationID})
+	if err != nil {
+		return "", err
+	}
+
+	conv := out.GetConversation()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Conversation ID: %s\nTitle: %s\n", conv.GetId(), conv.GetTitle())
+	for _, m := range conv.GetMessages() {
+		fmt.Fprintf(&sb, "\n%s, %s:\n%s\n", m.GetRole(), m.GetTimestamp().AsTime().Format(time.RFC3339),
+			withSources(m.GetContent(), m.GetCitations()))
+	}
+
+	if actions := conv.GetPendingActions(); len(actions) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n", withActions(actions))
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+type ApproveArgs struct {
+	ConversationID string `json:"conversation_id" description:"ID of the conversation"`
+	ActionID       string `json:"action_id" description:"ID of the pending action"`
+}
+
+type approveTool struct {
+	srv *chat.Server
+}
+
+func (t *approveTool) Name() string {
+	return "approve_action"
+}
+
+func (t *approveTool) Description() string {
+	return "Approves an action the Acai assistant is waiting for, only when the user asked for it. " +
+		"Returns the reply of the assistant once all the pending actions are decided."
+}
+
+func (t *approveTool) Call(ctx context.Context, args ApproveArgs) (string, error) {
+	out, err := t.srv.ApproveAction(ctx, &pb.ApproveActionRequest{ConversationId: args.ConversationID, ActionId: args.ActionID})
+	if err != nil {
+		return "", err
+	}
+
+	return replyText(out.GetReply(), out.GetCitations(), out.GetPendingActions()), nil
+}
+
+type RejectArgs struct {
+	ConversationID string `json:"conversation_id" description:"ID of the conversation"`
+	ActionID       string `json:"action_id" description:"ID of the pending action"`
+	Reason         string `json:"reason,omitempty" description:"Optional reason of the user, told to the assistant"`
+}
+
+type rejectTool struct {
+	srv *chat.Server
+}
+
+func (t *rejectTool) Name() string {
+	return "reject_action"
+}
+
+func (t *rejectTool) Description() string {
+	return "Rejects an action the Acai assistant is waiting for. " +
+		"Returns the reply of the assistant once all the pending actions are decided."
+}
+
+func (t *rejectTool) Call(ctx context.Context, args RejectArgs) (string, error) {
+	out, err := t.srv.RejectAction(ctx, &pb.RejectActionRequest{
+		ConversationId: args.ConversationID,
+		ActionId:       args.ActionID,
+		Reason:         args.Reason,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return replyText(out.GetReply(), out.GetCitations(), out.GetPendingActions()), nil
+}
+
+// replyText describes the reply, or the actions it waits for while paused.
+func replyText(reply string, citations []*pb.Citation, actions []*pb.PendingAction) string {
+	if len(actions) > 0 {
+		return withActions(actions)
+	}
+	return withSources(reply, citations)
+}
+
+func withActions(actions []*pb.PendingAction) string {
+	var sb strings.Builder
+	sb.WriteString("The assistant waits for the user to approve or reject these actions:")
+
+	for _, a := range actions {
+		fmt.Fprintf(&sb, "\n- action %s: %s %s", a.GetId(), a.GetTool(), a.GetArguments())
+	}
+
+	return sb.String()
+}
+
+// withSources appends the knowledge base passages a reply refers to.
+func withSources(reply string, citations []*pb.Citation) string {
+	if len(citations) == 0 {
+		return reply
+	}
+
+	var sb strings.Builder
+	sb.WriteString(reply)
+	sb.WriteString("\n\nSources:")
+
+	for _, c := range citations {
+		fmt.Fprintf(&sb, "\n[%d] %s", c.GetMarker(), c.GetDocumentTitle())
+		if c.GetHeading() != "" {
+			fmt.Fprintf(&sb, ", %s", c.GetHeading())
+		}
+		if c.GetPage() > 0 {
+			fmt.Fprintf(&sb, ", page %d", c.GetPage())
+		}
+	}
+
+	return sb.String()
+}
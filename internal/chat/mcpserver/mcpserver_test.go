@@ -0,0 +1,70 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/acai-travel/tech-challenge/internal/chat"
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	. "github.com/acai-travel/tech-challenge/internal/chat/testing"
+	"github.com/acai-travel/tech-challenge/internal/mcp"
+)
+
+func TestMCPServer(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := New(chat.NewServer(model.New(ConnectMongo()), &MockAssistant{
+		ReplyFunc: func(ctx context.Context, conv *model.Conversation) (string, error) {
+			return "Lisbon is lovely in May.", nil
+		},
+	}))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	client, err := mcp.Connect(ctx, mcp.ServerConfig{Name: "acai", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	call := func(t *testing.T, name, arguments string) *mcp.CallToolResult {
+		res, err := client.CallTool(ctx, name, json.RawMessage(arguments))
+		if err != nil {
+			t.Fatalf("unexpected error calling %s: %v", name, err)
+		}
+		return res
+	}
+
+	t.Run("describe a conversation", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+
+		res := call(t, "describe_conversation", `{"conversation_id": "`+c.ID.Hex()+`"}`)
+		if res.IsError || !strings.Contains(res.Content[0].Text, "What is the weather like today?") {
+			t.Errorf("unexpected result %+v", res)
+		}
+	}))
+
+	t.Run("continue a conversation", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+
+		res := call(t, "continue_conversation", `{"conversation_id": "`+c.ID.Hex()+`", "message": "And in May?"}`)
+		if res.IsError || res.Content[0].Text != "Lisbon is lovely in May." {
+			t.Errorf("unexpected result %+v", res)
+		}
+	}))
+
+	t.Run("report invalid arguments", WithFixture(func(t *testing.T, f *Fixture) {
+		res := call(t, "continue_conversation", `{"message": "Hi"}`)
+		if !res.IsError || !strings.Contains(res.Content[0].Text, `missing required field "conversation_id"`) {
+			t.Errorf("unexpected result %+v", res)
+		}
+	}))
+}
@@ -0,0 +1,99 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/twitchtv/twirp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// metrics of the conversations.
+type metrics struct {
+	conversationsStarted metric.Int64Counter
+	messages             metric.Int64Counter
+	conversationLength   metric.Int64Histogram
+	titleFailures        metric.Int64Counter
+}
+
+func newMetrics(meter metric.Meter) (*metrics, error) {
+	m := &metrics{}
+
+	var err error
+
+	// Number of conversations started
+	m.conversationsStarted, err = meter.Int64Counter(
+		"chat.conversations.started",
+		metric.WithDescription("Number of conversations started"),
+		metric.WithUnit("{conversation}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversations started counter: %w", err)
+	}
+
+	// Number of messages stored, by role
+	m.messages, err = meter.Int64Counter(
+		"chat.messages",
+		metric.WithDescription("Number of messages of conversations by role"),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create messages counter: %w", err)
+	}
+
+	// Number of messages of a conversation, each time it gets a reply
+	m.conversationLength, err = meter.Int64Histogram(
+		"chat.conversation.messages",
+		metric.WithDescription("Number of messages per conversation, recorded on each reply"),
+		metric.WithUnit("{message}"),
+		metric.WithExplicitBucketBoundaries(2, 4, 6, 10, 20, 50, 100, 200),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversation messages histogram: %w", err)
+	}
+
+	// Number of conversations left untitled, by twirp error code
+	m.titleFailures, err = meter.Int64Counter(
+		"chat.title.failures",
+		metric.WithDescription("Number of failed title generations by error code"),
+		metric.WithUnit("{failure}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create title failures counter: %w", err)
+	}
+
+	return m, nil
+}
+
+func (m *metrics) recordStarted(ctx context.Context) {
+	m.conversationsStarted.Add(ctx, 1)
+}
+
+// recordMessages records new messages of the conversation, and its length
+// when one of them is a reply.
+func (m *metrics) recordMessages(ctx context.Context, conv *model.Conversation, msgs ...*model.Message) {
+	replied := false
+	for _, msg := range msgs {
+		m.messages.Add(ctx, 1, metric.WithAttributes(attribute.String("message.role", string(msg.Role))))
+		replied = replied || msg.Role == model.RoleAssistant
+	}
+
+	if replied {
+		m.conversationLength.Record(ctx, int64(len(conv.Messages)))
+	}
+}
+
+// recordTitleFailure records a title generation failure, classified like
+// reply failures, e.g. resource_exhausted when rate limited.
+func (m *metrics) recordTitleFailure(ctx context.Context, err error) {
+	code := twirp.Internal
+	var twerr twirp.Error
+	if errors.As(replyError(err), &twerr) {
+		code = twerr.Code()
+	}
+
+	m.titleFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("error.code", string(code))))
+}
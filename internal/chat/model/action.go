@@ -0,0 +1,96 @@
+package model
+
+import (
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/pb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type ActionStatus string
+
+const (
+	ActionPending  ActionStatus = "pending"
+	ActionApproved ActionStatus = "approved"
+	ActionRejected ActionStatus = "rejected"
+)
+
+// PendingReply is an assistant reply paused until the user approves or
+// rejects the tool calls that require confirmation. It keeps the tool calling
+// turns of the reply so far, so the reply can resume where it stopped.
+type PendingReply struct {
+	Turns     []*ToolTurn      `bson:"turns" json:"turns"`
+	Actions   []*PendingAction `bson:"actions" json:"actions"`
+	Citations []*Citation      `bson:"citations,omitempty" json:"citations,omitempty"`
+	CreatedAt time.Time        `bson:"created_at" json:"created_at"`
+
+	// Ran reports whether the decided actions ran, their results stored in
+	// Turns, so a reply failing to resume is retried without running them
+	// twice
+	Ran bool `bson:"ran,omitempty" json:"ran,omitempty"`
+}
+
+// ToolTurn is a model turn requesting tool calls, with their results.
+type ToolTurn struct {
+	Content string      `bson:"content,omitempty" json:"content,omitempty"`
+	Calls   []*ToolCall `bson:"calls" json:"calls"`
+}
+
+type ToolCall struct {
+	ID        string `bson:"id" json:"id"`
+	Name      string `bson:"name" json:"name"`
+	Arguments string `bson:"arguments" json:"arguments"`
+	Result    string `bson:"result" json:"result"`
+}
+
+// PendingAction is a tool call waiting for the user to approve or reject it.
+type PendingAction struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	CallID    string             `bson:"call_id" json:"call_id"`
+	Tool      string             `bson:"tool" json:"tool"`
+	Arguments string             `bson:"arguments" json:"arguments"`
+	Status    ActionStatus       `bson:"status" json:"status"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Action returns the action of the given ID, if any.
+func (p *PendingReply) Action(id string) (*PendingAction, bool) {
+	for _, a := range p.Actions {
+		if a.ID.Hex() == id {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// Decided reports whether all actions were approved or rejected.
+func (p *PendingReply) Decided() bool {
+	for _, a := range p.Actions {
+		if a.Status == ActionPending {
+			return false
+		}
+	}
+	return true
+}
+
+// Undecided returns the actions still waiting for the user.
+func (p *PendingReply) Undecided() []*PendingAction {
+	var out []*PendingAction
+	for _, a := range p.Actions {
+		if a.Status == ActionPending {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (a *PendingAction) Proto() *pb.PendingAction {
+	return &pb.PendingAction{
+		Id:        a.ID.Hex(),
+		Tool:      a.Tool,
+		Arguments: a.Arguments,
+		CreatedAt: timestamppb.New(a.CreatedAt),
+	}
+}
@@ -9,11 +9,21 @@ import (
 )
 
 type Conversation struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Title     string             `bson:"subject"`
-	CreatedAt time.Time          `bson:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at"`
-	Messages  []*Message         `bson:"messages"`
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Title     string             `bson:"subject" json:"title"`
+	Owner     string             `bson:"owner,omitempty" json:"owner,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	Messages  []*Message         `bson:"messages" json:"messages"`
+	Events    []*Event           `bson:"events" json:"events,omitempty"`
+
+	// DisabledTools are the names of the tools the assistant can't use in
+	// the conversation
+	DisabledTools []string `bson:"disabled_tools" json:"disabled_tools,omitempty"`
+
+	// Pending is the reply waiting for the user to approve or reject tool
+	// calls, nil otherwise
+	Pending *PendingReply `bson:"pending" json:"pending,omitempty"`
 }
 
 func (c *Conversation) Proto() *pb.Conversation {
@@ -21,11 +31,23 @@ func (c *Conversation) Proto() *pb.Conversation {
 		Id:        c.ID.Hex(),
 		Title:     c.Title,
 		Timestamp: timestamppb.New(c.UpdatedAt),
+
+		DisabledTools: c.DisabledTools,
 	}
 
 	for _, m := range c.Messages {
 		proto.Messages = append(proto.Messages, m.Proto())
 	}
 
+	for _, e := range c.Events {
+		proto.Events = append(proto.Events, e.Proto())
+	}
+
+	if c.Pending != nil {
+		for _, a := range c.Pending.Undecided() {
+			proto.PendingActions = append(proto.PendingActions, a.Proto())
+		}
+	}
+
 	return proto
 }
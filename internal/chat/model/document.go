@@ -0,0 +1,102 @@
+package model
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/pb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type DocumentFormat string
+
+const (
+	DocumentText     DocumentFormat = "text"
+	DocumentMarkdown DocumentFormat = "markdown"
+	DocumentPDF      DocumentFormat = "pdf"
+)
+
+// DocumentFormatFromFilename infers the format from the file extension, or
+// returns an empty format if it isn't supported.
+func DocumentFormatFromFilename(name string) DocumentFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".text":
+		return DocumentText
+	case ".md", ".markdown":
+		return DocumentMarkdown
+	case ".pdf":
+		return DocumentPDF
+	default:
+		return ""
+	}
+}
+
+func (f DocumentFormat) Proto() pb.Document_Format {
+	switch f {
+	case DocumentText:
+		return pb.Document_TEXT
+	case DocumentMarkdown:
+		return pb.Document_MARKDOWN
+	case DocumentPDF:
+		return pb.Document_PDF
+	default:
+		return pb.Document_FORMAT_UNKNOWN
+	}
+}
+
+// Document is a file of the knowledge base. Its text is kept in sections, so
+// it can be split and embedded again, e.g. when changing embedding models.
+type Document struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Title     string             `bson:"title"`
+	Filename  string             `bson:"filename"`
+	Format    DocumentFormat     `bson:"format"`
+	Size      int64              `bson:"size"`
+	Chunks    int                `bson:"chunks"`
+	Sections  []*DocumentSection `bson:"sections,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// DocumentSection is the text of a page of a PDF, or of a part of a Markdown
+// document under a heading.
+type DocumentSection struct {
+	Page    int    `bson:"page,omitempty"`
+	Heading string `bson:"heading,omitempty"`
+	Text    string `bson:"text"`
+}
+
+func (d *Document) Proto() *pb.Document {
+	return &pb.Document{
+		Id:        d.ID.Hex(),
+		Title:     d.Title,
+		Filename:  d.Filename,
+		Format:    d.Format.Proto(),
+		Size:      d.Size,
+		Chunks:    int32(d.Chunks),
+		CreatedAt: timestamppb.New(d.CreatedAt),
+	}
+}
+
+// Citation is a knowledge base passage a message is based on. Marker is the
+// number the message refers to it with, e.g. [1].
+type Citation struct {
+	Marker        int                `bson:"marker" json:"marker"`
+	DocumentID    primitive.ObjectID `bson:"document_id" json:"document_id"`
+	DocumentTitle string             `bson:"document_title" json:"document_title"`
+	Page          int                `bson:"page,omitempty" json:"page,omitempty"`
+	Heading       string             `bson:"heading,omitempty" json:"heading,omitempty"`
+	Excerpt       string             `bson:"excerpt" json:"excerpt"`
+}
+
+func (c *Citation) Proto() *pb.Citation {
+	return &pb.Citation{
+		Marker:        int32(c.Marker),
+		DocumentId:    c.DocumentID.Hex(),
+		DocumentTitle: c.DocumentTitle,
+		Page:          int32(c.Page),
+		Heading:       c.Heading,
+		Excerpt:       c.Excerpt,
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/pb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Event is a calendar event proposed by the assistant during a conversation.
+// Start and End are absolute instants, TimeZone is the IANA zone the event
+// should be displayed in.
+type Event struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Title       string             `bson:"title" json:"title"`
+	Start       time.Time          `bson:"start" json:"start"`
+	End         time.Time          `bson:"end" json:"end"`
+	TimeZone    string             `bson:"time_zone" json:"time_zone,omitempty"`
+	Location    string             `bson:"location" json:"location,omitempty"`
+	Description string             `bson:"description" json:"description,omitempty"`
+}
+
+func (e *Event) Proto() *pb.Event {
+	return &pb.Event{
+		Id:          e.ID.Hex(),
+		Title:       e.Title,
+		Start:       timestamppb.New(e.Start),
+		End:         timestamppb.New(e.End),
+		TimeZone:    e.TimeZone,
+		Location:    e.Location,
+		Description: e.Description,
+	}
+}
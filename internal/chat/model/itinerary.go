@@ -0,0 +1,129 @@
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/pb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Itinerary is a structured trip plan attached to a conversation. Dates and
+// times of the items are local to TimeZone (an IANA zone name), if set.
+type Itinerary struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	ConversationID primitive.ObjectID `bson:"conversation_id"`
+	Title          string             `bson:"title"`
+	TimeZone       string             `bson:"time_zone"`
+	Days           []*ItineraryDay    `bson:"days"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at"`
+}
+
+// ItineraryDay groups the items planned for a single date (YYYY-MM-DD).
+type ItineraryDay struct {
+	Date  string           `bson:"date"`
+	Items []*ItineraryItem `bson:"items"`
+}
+
+// ItineraryItem is a single activity of the plan. Time is the local time in
+// HH:MM format and may be empty for items without a fixed time.
+type ItineraryItem struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Time  string             `bson:"time"`
+	Place string             `bson:"place"`
+	Notes string             `bson:"notes"`
+}
+
+func NewItinerary(conversationID primitive.ObjectID) *Itinerary {
+	return &Itinerary{
+		ID:             primitive.NewObjectID(),
+		ConversationID: conversationID,
+		Title:          "Trip plan",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+}
+
+// AddItem adds the item to the given date, keeping days and items sorted.
+func (it *Itinerary) AddItem(date string, item *ItineraryItem) {
+	day := it.day(date)
+	day.Items = append(day.Items, item)
+
+	sort.SliceStable(day.Items, func(i, j int) bool {
+		return day.Items[i].Time < day.Items[j].Time
+	})
+}
+
+// FindItem returns the item with the given ID and the date it is planned for.
+func (it *Itinerary) FindItem(id primitive.ObjectID) (*ItineraryItem, string, bool) {
+	for _, d := range it.Days {
+		for _, item := range d.Items {
+			if item.ID == id {
+				return item, d.Date, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// RemoveItem removes the item with the given ID, dropping the day if it becomes empty.
+func (it *Itinerary) RemoveItem(id primitive.ObjectID) bool {
+	for i, d := range it.Days {
+		for j, item := range d.Items {
+			if item.ID != id {
+				continue
+			}
+
+			d.Items = append(d.Items[:j], d.Items[j+1:]...)
+			if len(d.Items) == 0 {
+				it.Days = append(it.Days[:i], it.Days[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (it *Itinerary) day(date string) *ItineraryDay {
+	for _, d := range it.Days {
+		if d.Date == date {
+			return d
+		}
+	}
+
+	d := &ItineraryDay{Date: date}
+	it.Days = append(it.Days, d)
+
+	sort.Slice(it.Days, func(i, j int) bool {
+		return it.Days[i].Date < it.Days[j].Date
+	})
+
+	return d
+}
+
+func (it *Itinerary) Proto() *pb.Itinerary {
+	proto := &pb.Itinerary{
+		Id:             it.ID.Hex(),
+		ConversationId: it.ConversationID.Hex(),
+		Title:          it.Title,
+		TimeZone:       it.TimeZone,
+		Timestamp:      timestamppb.New(it.UpdatedAt),
+	}
+
+	for _, d := range it.Days {
+		day := &pb.Itinerary_Day{Date: d.Date}
+		for _, item := range d.Items {
+			day.Items = append(day.Items, &pb.Itinerary_Item{
+				Id:    item.ID.Hex(),
+				Time:  item.Time,
+				Place: item.Place,
+				Notes: item.Notes,
+			})
+		}
+		proto.Days = append(proto.Days, day)
+	}
+
+	return proto
+}
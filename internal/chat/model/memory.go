@@ -0,0 +1,94 @@
+package model
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/acai-travel/tech-challenge/internal/pb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Limits of memory keys and values, whether saved by the assistant or
+// edited by the user.
+const (
+	MaxMemoryKeyLength   = 64
+	MaxMemoryValueLength = 500
+)
+
+// Memory is a fact about a user the assistant remembers across
+// conversations, e.g. diet=vegetarian or home_airport=BCN. Keys are unique
+// per owner, saving a known key replaces its value.
+type Memory struct {
+	ID                   primitive.ObjectID `bson:"_id"`
+	Owner                string             `bson:"owner"`
+	Key                  string             `bson:"key"`
+	Value                string             `bson:"value"`
+	SourceConversationID primitive.ObjectID `bson:"source_conversation_id,omitempty"`
+	SourceMessageID      primitive.ObjectID `bson:"source_message_id,omitempty"`
+	CreatedAt            time.Time          `bson:"created_at"`
+	UpdatedAt            time.Time          `bson:"updated_at"`
+}
+
+func (m *Memory) Proto() *pb.Memory {
+	proto := &pb.Memory{
+		Id:        m.ID.Hex(),
+		Key:       m.Key,
+		Value:     m.Value,
+		CreatedAt: timestamppb.New(m.CreatedAt),
+		UpdatedAt: timestamppb.New(m.UpdatedAt),
+	}
+
+	if !m.SourceConversationID.IsZero() {
+		proto.SourceConversationId = m.SourceConversationID.Hex()
+	}
+
+	if !m.SourceMessageID.IsZero() {
+		proto.SourceMessageId = m.SourceMessageID.Hex()
+	}
+
+	return proto
+}
+
+// MemoryKey normalizes a key to lower snake case, so "Home airport" and
+// "home_airport" are the same memory.
+func MemoryKey(key string) string {
+	words := strings.FieldsFunc(strings.ToLower(key), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "_")
+}
+
+// RankMemories returns up to limit memories, those sharing words with the
+// text first and the most recently updated next. Memories are expected most
+// recently updated first, as returned by ListMemories.
+func RankMemories(memories []*Memory, text string, limit int) []*Memory {
+	terms := searchTerms(text)
+
+	scores := make(map[*Memory]int, len(memories))
+	for _, m := range memories {
+		for _, w := range splitWords(strings.ReplaceAll(m.Key, "_", " ") + " " + m.Value) {
+			s := stem(strings.ToLower(w.text))
+			for _, t := range terms {
+				if s == t || strings.HasPrefix(s, t) {
+					scores[m]++
+					break
+				}
+			}
+		}
+	}
+
+	ranked := slices.Clone(memories)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked
+}
@@ -9,18 +9,25 @@ import (
 )
 
 type Message struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Role      Role               `bson:"role"`
-	Content   string             `bson:"content"`
-	CreatedAt time.Time          `bson:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at"`
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Role      Role               `bson:"role" json:"role"`
+	Content   string             `bson:"content" json:"content"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	Citations []*Citation        `bson:"citations,omitempty" json:"citations,omitempty"`
 }
 
 func (m *Message) Proto() *pb.Conversation_Message {
-	return &pb.Conversation_Message{
+	proto := &pb.Conversation_Message{
 		Id:        m.ID.Hex(),
 		Role:      m.Role.Proto(),
 		Content:   m.Content,
 		Timestamp: timestamppb.New(m.CreatedAt),
 	}
+
+	for _, c := range m.Citations {
+		proto.Citations = append(proto.Citations, c.Proto())
+	}
+
+	return proto
 }
@@ -3,6 +3,9 @@ package model
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/twitchtv/twirp"
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,6 +16,10 @@ import (
 
 const (
 	conversationCollection = "conversations"
+	itineraryCollection    = "itineraries"
+	documentCollection     = "documents"
+	memoryCollection       = "memories"
+	metadataCollection     = "metadata"
 )
 
 type Repository struct {
@@ -51,11 +58,21 @@ func (r *Repository) DescribeConversation(ctx context.Context, id string) (*Conv
 }
 
 func (r *Repository) ListConversations(ctx context.Context) ([]*Conversation, error) {
+	return r.findConversations(ctx, map[string]any{})
+}
+
+// ListOwnerConversations returns the conversations of the owner, most
+// recently created first.
+func (r *Repository) ListOwnerConversations(ctx context.Context, owner string) ([]*Conversation, error) {
+	return r.findConversations(ctx, bson.M{"owner": owner})
+}
+
+func (r *Repository) findConversations(ctx context.Context, filter any) ([]*Conversation, error) {
 	opts := options.Find().
 		SetSort(bson.D{{Key: "created_at", Value: -1}})
 
 	cursor, err := r.conn.Collection(conversationCollection).
-		Find(ctx, map[string]any{}, opts)
+		Find(ctx, filter, opts)
 
 	if err != nil {
 		return nil, err
@@ -97,10 +114,682 @@ func (r *Repository) UpdateConversation(ctx context.Context, c *Conversation) er
 }
 
 func (r *Repository) DeleteConversation(ctx context.Context, id string) error {
-	_, err := r.conn.Collection(conversationCollection).DeleteOne(ctx, map[string]any{"_id": id})
-	if errors.Is(err, mongo.ErrNoDocuments) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return twirp.NotFoundError("invalid conversation ID")
+	}
+
+	res, err := r.conn.Collection(conversationCollection).DeleteOne(ctx, map[string]any{"_id": oid})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
 		return twirp.NotFoundError("conversation not found")
 	}
 
+	return nil
+}
+
+// DecideAction sets the status of a pending action of the conversation, and
+// returns the conversation as updated. The action is only updated while
+// pending, so concurrent requests can't both decide it: a nil conversation is
+// returned when the action isn't pending, or doesn't exist.
+func (r *Repository) DecideAction(ctx context.Context, conversationID, actionID string, status ActionStatus, reason string) (*Conversation, error) {
+	oid, err := primitive.ObjectIDFromHex(conversationID)
+	if err != nil {
+		return nil, twirp.NotFoundError("invalid conversation ID")
+	}
+
+	aid, err := primitive.ObjectIDFromHex(actionID)
+	if err != nil {
+		return nil, twirp.NotFoundError("invalid action ID")
+	}
+
+	filter := bson.M{
+		"_id":             oid,
+		"pending.actions": bson.M{"$elemMatch": bson.M{"_id": aid, "status": ActionPending}},
+	}
+	update := bson.M{"$set": bson.M{
+		"pending.actions.$.status": status,
+		"pending.actions.$.reason": reason,
+		"updated_at":               time.Now(),
+	}}
+
+	var c Conversation
+	err = r.conn.Collection(conversationCollection).
+		FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).
+		Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// StaleFilter selects the conversations not updated since Before. Owners
+// limits them to the conversations of these owners, ExceptOwners leaves the
+// conversations of these owners out.
+type StaleFilter struct {
+	Before       time.Time
+	Owners       []string
+	ExceptOwners []string
+}
+
+func (f StaleFilter) query() bson.M {
+	q := bson.M{"updated_at": bson.M{"$lt": f.Before}}
+	switch {
+	case len(f.Owners) > 0:
+		q["owner"] = bson.M{"$in": f.Owners}
+	case len(f.ExceptOwners) > 0:
+		q["owner"] = bson.M{"$nin": f.ExceptOwners}
+	}
+	return q
+}
+
+// CountStaleConversations returns the number of conversations the filter
+// selects, and of their itineraries.
+func (r *Repository) CountStaleConversations(ctx context.Context, f StaleFilter) (conversations, itineraries int64, err error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: f.query()}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         itineraryCollection,
+			"localField":   "_id",
+			"foreignField": "conversation_id",
+			"as":           "itineraries",
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           nil,
+			"conversations": bson.M{"$sum": 1},
+			"itineraries":   bson.M{"$sum": bson.M{"$size": "$itineraries"}},
+		}}},
+	}
+
+	cursor, err := r.conn.Collection(conversationCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var counts []struct {
+		Conversations int64 `bson:"conversations"`
+		Itineraries   int64 `bson:"itineraries"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return 0, 0, err
+	}
+
+	if len(counts) == 0 {
+		return 0, 0, nil
+	}
+	return counts[0].Conversations, counts[0].Itineraries, nil
+}
+
+// ListStaleConversations returns the IDs of up to limit conversations the
+// filter selects, least recently updated first.
+func (r *Repository) ListStaleConversations(ctx context.Context, f StaleFilter, limit int) ([]primitive.ObjectID, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.D{{Key: "updated_at", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.conn.Collection(conversationCollection).Find(ctx, f.query(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(docs))
+	for i, d := range docs {
+		ids[i] = d.ID
+	}
+	return ids, nil
+}
+
+// DeleteConversations deletes the conversations of ids the filter still
+// selects, so those updated since they were listed are kept, with their
+// itineraries. It returns the IDs of the conversations deleted, and the
+// number of itineraries deleted.
+func (r *Repository) DeleteConversations(ctx context.Context, f StaleFilter, ids []primitive.ObjectID) ([]primitive.ObjectID, int64, error) {
+	if len(ids) == 0 {
+		return nil, 0, nil
+	}
+
+	q := f.query()
+	q["_id"] = bson.M{"$in": ids}
+	res, err := r.conn.Collection(conversationCollection).DeleteMany(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	deleted := ids
+	if res.DeletedCount < int64(len(ids)) {
+		existing, err := r.ExistingConversations(ctx, ids)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		deleted = slices.DeleteFunc(slices.Clone(ids), func(id primitive.ObjectID) bool { return existing[id] })
+	}
+
+	// Itineraries left by a failure here are deleted by the next purge, see
+	// DeleteOrphanItineraries
+	res, err = r.conn.Collection(itineraryCollection).DeleteMany(ctx, bson.M{"conversation_id": bson.M{"$in": deleted}})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return deleted, res.DeletedCount, nil
+}
+
+// ExistingConversations returns which of the conversations of ids exist.
+func (r *Repository) ExistingConversations(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	if len(ids) == 0 {
+		return map[primitive.ObjectID]bool{}, nil
+	}
+
+	cursor, err := r.conn.Collection(conversationCollection).
+		Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	existing := make(map[primitive.ObjectID]bool, len(docs))
+	for _, d := range docs {
+		existing[d.ID] = true
+	}
+	return existing, nil
+}
+
+// DeleteOrphanItineraries deletes the itineraries of conversations that no
+// longer exist, e.g. expired by the TTL index, returning how many. With
+// dryRun set they are only counted.
+func (r *Repository) DeleteOrphanItineraries(ctx context.Context, dryRun bool) (int64, error) {
+	values, err := r.conn.Collection(itineraryCollection).Distinct(ctx, "conversation_id", bson.M{})
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []primitive.ObjectID
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	existing, err := r.ExistingConversations(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	orphans := slices.DeleteFunc(ids, func(id primitive.ObjectID) bool { return existing[id] })
+
+	if dryRun || len(orphans) == 0 {
+		return int64(len(orphans)), nil
+	}
+
+	res, err := r.conn.Collection(itineraryCollection).DeleteMany(ctx, bson.M{"conversation_id": bson.M{"$in": orphans}})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.DeletedCount, nil
+}
+
+// retentionIndex is the name of the TTL index expiring conversations, see
+// EnsureRetentionIndex.
+const retentionIndex = "conversations_retention"
+
+// EnsureRetentionIndex makes MongoDB delete conversations not updated for
+// maxAge, with a TTL index on updated_at, or drops the index when maxAge is
+// 0. Changes of maxAge update the existing index.
+func (r *Repository) EnsureRetentionIndex(ctx context.Context, maxAge time.Duration) error {
+	coll := r.conn.Collection(conversationCollection)
+
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var specs []struct {
+		Name               string `bson:"name"`
+		ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return err
+	}
+
+	seconds := int64(maxAge / time.Second)
+	for _, spec := range specs {
+		if spec.Name != retentionIndex {
+			continue
+		}
+
+		switch {
+		case seconds <= 0:
+			_, err = coll.Indexes().DropOne(ctx, retentionIndex)
+		case spec.ExpireAfterSeconds == nil || *spec.ExpireAfterSeconds != seconds:
+			err = r.conn.RunCommand(ctx, bson.D{
+				{Key: "collMod", Value: conversationCollection},
+				{Key: "index", Value: bson.D{{Key: "name", Value: retentionIndex}, {Key: "expireAfterSeconds", Value: seconds}}},
+			}).Err()
+		}
+		return err
+	}
+
+	if seconds <= 0 {
+		return nil
+	}
+
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "updated_at", Value: 1}},
+		Options: options.Index().SetName(retentionIndex).SetExpireAfterSeconds(int32(seconds)),
+	})
+	return err
+}
+
+func (r *Repository) DescribeItinerary(ctx context.Context, conversationID string) (*Itinerary, error) {
+	var it Itinerary
+
+	oid, err := primitive.ObjectIDFromHex(conversationID)
+	if err != nil {
+		return nil, twirp.NotFoundError("invalid conversation ID")
+	}
+
+	err = r.conn.Collection(itineraryCollection).FindOne(ctx, map[string]any{"conversation_id": oid}).Decode(&it)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, twirp.NotFoundError("itinerary not found")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &it, nil
+}
+
+func (r *Repository) ListItineraries(ctx context.Context) ([]*Itinerary, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	cursor, err := r.conn.Collection(itineraryCollection).
+		Find(ctx, map[string]any{}, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	var items []*Itinerary
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// SaveItinerary creates the itinerary of the conversation or replaces the
+// stored version. Conversations have one itinerary, instances creating it at
+// once end up saving the same one.
+func (r *Repository) SaveItinerary(ctx context.Context, it *Itinerary) error {
+	data, err := bson.Marshal(it)
+	if err != nil {
+		return err
+	}
+
+	var fields bson.M
+	if err := bson.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	delete(fields, "_id")
+	delete(fields, "created_at")
+
+	filter := bson.M{"conversation_id": it.ConversationID}
+	update := bson.M{
+		"$set":         fields,
+		"$setOnInsert": bson.M{"_id": it.ID, "created_at": it.CreatedAt},
+	}
+
+	coll := r.conn.Collection(itineraryCollection)
+	_, err = coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+
+	// The unique index refuses the second of concurrent inserts, which then
+	// updates the first
+	if mongo.IsDuplicateKeyError(err) {
+		_, err = coll.UpdateOne(ctx, filter, update)
+	}
+
+	return err
+}
+
+func (r *Repository) DeleteItinerary(ctx context.Context, conversationID string) error {
+	oid, err := primitive.ObjectIDFromHex(conversationID)
+	if err != nil {
+		return twirp.NotFoundError("invalid conversation ID")
+	}
+
+	_, err = r.conn.Collection(itineraryCollection).DeleteOne(ctx, map[string]any{"conversation_id": oid})
+	return err
+}
+
+func (r *Repository) CreateDocument(ctx context.Context, d *Document) error {
+	_, err := r.conn.Collection(documentCollection).InsertOne(ctx, d)
+	return err
+}
+
+func (r *Repository) DescribeDocument(ctx context.Context, id string) (*Document, error) {
+	var d Document
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, twirp.NotFoundError("invalid document ID")
+	}
+
+	err = r.conn.Collection(documentCollection).FindOne(ctx, map[string]any{"_id": oid}).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, twirp.NotFoundError("document not found")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// ListDocuments returns the documents, most recent first, without their text.
+func (r *Repository) ListDocuments(ctx context.Context) ([]*Document, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"sections": 0}).
+		SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.conn.Collection(documentCollection).
+		Find(ctx, map[string]any{}, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	var items []*Document
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (r *Repository) DeleteDocument(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return twirp.NotFoundError("invalid document ID")
+	}
+
+	res, err := r.conn.Collection(documentCollection).DeleteOne(ctx, map[string]any{"_id": oid})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return twirp.NotFoundError("document not found")
+	}
+
+	return nil
+}
+
+// SaveMemory creates the memory, or updates the value of the memory of the
+// same owner and key. The stored memory is written back to m.
+func (r *Repository) SaveMemory(ctx context.Context, m *Memory) error {
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	update := bson.M{
+		"$set": bson.M{
+			"value":                  m.Value,
+			"source_conversation_id": m.SourceConversationID,
+			"source_message_id":      m.SourceMessageID,
+			"updated_at":             m.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        m.ID,
+			"created_at": m.CreatedAt,
+		},
+	}
+
+	return r.conn.Collection(memoryCollection).
+		FindOneAndUpdate(ctx, bson.M{"owner": m.Owner, "key": m.Key}, update, opts).
+		Decode(m)
+}
+
+// ListMemories returns the memories of the owner, most recently updated first.
+func (r *Repository) ListMemories(ctx context.Context, owner string) ([]*Memory, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	cursor, err := r.conn.Collection(memoryCollection).
+		Find(ctx, bson.M{"owner": owner}, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	var items []*Memory
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// DescribeMemory returns a memory of the owner. Memories of other owners are
+// reported as not found.
+func (r *Repository) DescribeMemory(ctx context.Context, owner, id string) (*Memory, error) {
+	var m Memory
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, twirp.NotFoundError("invalid memory ID")
+	}
+
+	err = r.conn.Collection(memoryCollection).FindOne(ctx, bson.M{"_id": oid, "owner": owner}).Decode(&m)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, twirp.NotFoundError("memory not found")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// UpdateMemory replaces the key and value of a memory. Renaming a memory to
+// a key the owner already uses fails with AlreadyExists.
+func (r *Repository) UpdateMemory(ctx context.Context, m *Memory) error {
+	_, err := r.conn.Collection(memoryCollection).UpdateOne(ctx,
+		bson.M{"_id": m.ID, "owner": m.Owner},
+		bson.M{"$set": bson.M{"key": m.Key, "value": m.Value, "updated_at": m.UpdatedAt}})
+
+	if mongo.IsDuplicateKeyError(err) {
+		return twirp.NewErrorf(twirp.AlreadyExists, "memory %q already exists", m.Key)
+	}
+
 	return err
 }
+
+func (r *Repository) DeleteMemory(ctx context.Context, owner, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return twirp.NotFoundError("invalid memory ID")
+	}
+
+	res, err := r.conn.Collection(memoryCollection).DeleteOne(ctx, bson.M{"_id": oid, "owner": owner})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return twirp.NotFoundError("memory not found")
+	}
+
+	return nil
+}
+
+// SchemaVersion is the version of the indexes and documents the repository
+// relies on, increased with each change EnsureIndexes applies.
+const SchemaVersion = 3
+
+// schemaID is the ID of the document of the metadata collection recording
+// the schema version.
+const schemaID = "schema"
+
+// indexes are the indexes of each collection, by name.
+var indexes = map[string][]mongo.IndexModel{
+	conversationCollection: {
+		{
+			Keys: bson.D{{Key: "subject", Value: "text"}, {Key: "messages.content", Value: "text"}},
+			Options: options.Index().
+				SetName("conversations_text").
+				SetWeights(bson.D{{Key: "subject", Value: 5}, {Key: "messages.content", Value: 1}}),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("conversations_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "updated_at", Value: -1}},
+			Options: options.Index().SetName("conversations_updated_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "updated_at", Value: -1}},
+			Options: options.Index().SetName("conversations_owner_updated_at"),
+		},
+	},
+	itineraryCollection: {
+		{
+			Keys:    bson.D{{Key: "conversation_id", Value: 1}},
+			Options: options.Index().SetName("itineraries_conversation_id").SetUnique(true),
+		},
+	},
+	documentCollection: {
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("documents_created_at"),
+		},
+	},
+	memoryCollection: {
+		{
+			Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetName("memories_owner_key").SetUnique(true),
+		},
+	},
+}
+
+// EnsureIndexes creates the indexes the repository relies on and records
+// SchemaVersion in the metadata collection. It is idempotent, existing
+// indexes are left as is.
+func (r *Repository) EnsureIndexes(ctx context.Context) error {
+	for collection, models := range indexes {
+		if _, err := r.conn.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("failed to create indexes of %s: %w", collection, err)
+		}
+	}
+
+	_, err := r.conn.Collection(metadataCollection).UpdateOne(ctx,
+		bson.M{"_id": schemaID},
+		bson.M{"$set": bson.M{"version": SchemaVersion, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true))
+
+	if err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the schema version recorded by EnsureIndexes, 0 when
+// it never ran on the database.
+func (r *Repository) SchemaVersion(ctx context.Context) (int, error) {
+	var schema struct {
+		Version int `bson:"version"`
+	}
+
+	err := r.conn.Collection(metadataCollection).FindOne(ctx, bson.M{"_id": schemaID}).Decode(&schema)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+
+	return schema.Version, err
+}
+
+// SearchConversations runs a full-text search on the titles and messages of
+// the owner's conversations, best matches first. An empty owner searches the
+// anonymous conversations.
+func (r *Repository) SearchConversations(ctx context.Context, owner, query string, offset, limit int) ([]*SearchResult, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "updated_at", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	filter := bson.M{"$text": bson.M{"$search": query}, "owner": owner}
+	if owner == "" {
+		// Anonymous conversations are stored without an owner
+		filter["owner"] = bson.M{"$exists": false}
+	}
+
+	cursor, err := r.conn.Collection(conversationCollection).
+		Find(ctx, filter, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	var results []*SearchResult
+	for cursor.Next(ctx) {
+		var c Conversation
+
+		if err := cursor.Decode(&c); err != nil {
+			return nil, err
+		}
+
+		results = append(results, Highlight(&c, query))
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
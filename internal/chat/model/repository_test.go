@@ -0,0 +1,82 @@
+package model_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	. "github.com/acai-travel/tech-challenge/internal/chat/testing"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRepository_EnsureIndexes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates indexes idempotently and records the schema version", WithFixture(func(t *testing.T, f *Fixture) {
+		// The fixture already created them once
+		if err := f.Repository.EnsureIndexes(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		version, err := f.Repository.SchemaVersion(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if version != model.SchemaVersion {
+			t.Errorf("got schema version %d, want %d", version, model.SchemaVersion)
+		}
+
+		cursor, err := ConnectMongo().Collection("conversations").Indexes().List(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var indexes []bson.M
+		if err := cursor.All(ctx, &indexes); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		names := map[string]bool{}
+		for _, index := range indexes {
+			names[index["name"].(string)] = true
+		}
+
+		for _, want := range []string{"conversations_text", "conversations_created_at", "conversations_updated_at", "conversations_owner_updated_at"} {
+			if !names[want] {
+				t.Errorf("expected index %s, got %v", want, names)
+			}
+		}
+	}))
+}
+
+func TestRepository_SaveItinerary(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("keeps one itinerary per conversation", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+		f.CreateItinerary(c)
+
+		// Another instance creating the itinerary at the same time
+		other := model.NewItinerary(c.ID)
+		other.Title = "Lisbon again"
+		if err := f.Repository.SaveItinerary(ctx, other); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		count, err := ConnectMongo().Collection("itineraries").CountDocuments(ctx, bson.M{"conversation_id": c.ID})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 1 {
+			t.Fatalf("got %d itineraries, want 1", count)
+		}
+
+		it, err := f.Repository.DescribeItinerary(ctx, c.ID.Hex())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if it.Title != "Lisbon again" {
+			t.Errorf("expected the last saved itinerary, got %q", it.Title)
+		}
+	}))
+}
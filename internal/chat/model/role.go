@@ -9,6 +9,10 @@ const (
 	RoleAssistant Role = "assistant"
 )
 
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAssistant
+}
+
 func (r Role) Proto() pb.Conversation_Role {
 	switch r {
 	case RoleUser:
@@ -0,0 +1,192 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/acai-travel/tech-challenge/internal/pb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	snippetRadius   = 80
+	highlightPrefix = "**"
+	highlightSuffix = "**"
+)
+
+// SearchResult is a conversation matching a search query, with a highlighted
+// snippet for the title and each matching message.
+type SearchResult struct {
+	Conversation *Conversation
+	Matches      []*SearchMatch
+	Score        float64
+}
+
+// SearchMatch is a highlighted excerpt of a match. MessageID is zero when the
+// match is in the conversation title.
+type SearchMatch struct {
+	MessageID primitive.ObjectID
+	Snippet   string
+}
+
+func (r *SearchResult) Proto() *pb.SearchConversationsResponse_Result {
+	proto := &pb.SearchConversationsResponse_Result{
+		Conversation: r.Conversation.Proto(),
+		Score:        r.Score,
+	}
+
+	for _, m := range r.Matches {
+		match := &pb.SearchConversationsResponse_Match{Snippet: m.Snippet}
+		if !m.MessageID.IsZero() {
+			match.MessageId = m.MessageID.Hex()
+		}
+		proto.Matches = append(proto.Matches, match)
+	}
+
+	return proto
+}
+
+// Highlight finds the query terms in the title and messages of the
+// conversation. Title matches weigh more than message matches in the score.
+func Highlight(c *Conversation, query string) *SearchResult {
+	terms := searchTerms(query)
+	result := &SearchResult{Conversation: c}
+
+	if snippet, hits := highlightText(c.Title, terms); hits > 0 {
+		result.Matches = append(result.Matches, &SearchMatch{Snippet: snippet})
+		result.Score += 5 * float64(hits)
+	}
+
+	for _, m := range c.Messages {
+		if snippet, hits := highlightText(m.Content, terms); hits > 0 {
+			result.Matches = append(result.Matches, &SearchMatch{MessageID: m.ID, Snippet: snippet})
+			result.Score += float64(hits)
+		}
+	}
+
+	return result
+}
+
+// stopWords are ignored in queries, like MongoDB does for English text indexes.
+var stopWords = map[string]bool{
+	"a": true, "about": true, "an": true, "and": true, "are": true, "at": true, "be": true, "did": true,
+	"for": true, "from": true, "how": true, "i": true, "in": true, "is": true, "it": true, "me": true,
+	"my": true, "of": true, "on": true, "or": true, "say": true, "that": true, "the": true, "this": true,
+	"to": true, "was": true, "we": true, "what": true, "when": true, "where": true, "with": true, "you": true,
+}
+
+// searchTerms splits the query into lower-cased, stemmed words.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, w := range splitWords(query) {
+		lower := strings.ToLower(w.text)
+		if len(lower) > 1 && !stopWords[lower] {
+			terms = append(terms, stem(lower))
+		}
+	}
+	return terms
+}
+
+type word struct {
+	text       string
+	start, end int
+}
+
+func splitWords(s string) []word {
+	var words []word
+	start := -1
+
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			words = append(words, word{text: s[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, word{text: s[start:], start: start, end: len(s)})
+	}
+
+	return words
+}
+
+// stem strips common English suffixes so "hotels" matches "hotel".
+func stem(w string) string {
+	for _, suffix := range []string{"ing", "ies", "es", "ed", "s"} {
+		if len(w) > len(suffix)+2 && strings.HasSuffix(w, suffix) {
+			return strings.TrimSuffix(w, suffix)
+		}
+	}
+	return w
+}
+
+// highlightText returns a snippet around the first match with every matching
+// word wrapped in highlight markers, and the number of matches.
+func highlightText(text string, terms []string) (string, int) {
+	var hits []word
+	for _, w := range splitWords(text) {
+		s := stem(strings.ToLower(w.text))
+		for _, t := range terms {
+			if s == t || strings.HasPrefix(s, t) {
+				hits = append(hits, w)
+				break
+			}
+		}
+	}
+
+	if len(hits) == 0 {
+		return "", 0
+	}
+
+	from, to := 0, len(text)
+	if hits[0].start > snippetRadius {
+		from = min(wordBoundary(text, hits[0].start-snippetRadius), hits[0].start)
+	}
+	if hits[0].end+snippetRadius < len(text) {
+		to = wordBoundary(text, hits[0].end+snippetRadius)
+	}
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+
+	pos := from
+	for _, h := range hits {
+		if h.start < from || h.end > to {
+			continue
+		}
+		sb.WriteString(text[pos:h.start])
+		sb.WriteString(highlightPrefix + text[h.start:h.end] + highlightSuffix)
+		pos = h.end
+	}
+	sb.WriteString(text[pos:to])
+
+	if to < len(text) {
+		sb.WriteString("…")
+	}
+
+	return strings.Join(strings.Fields(sb.String()), " "), len(hits)
+}
+
+// wordBoundary moves i forward to the next whitespace, so snippets don't cut
+// words, nor the UTF-8 sequences of their letters.
+func wordBoundary(s string, i int) int {
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
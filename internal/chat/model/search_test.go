@@ -0,0 +1,44 @@
+package model_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+)
+
+func TestHighlight(t *testing.T) {
+	t.Run("highlight matches in a snippet", func(t *testing.T) {
+		c := &model.Conversation{
+			Title:    "Trip to Lisbon",
+			Messages: []*model.Message{{Content: "Which hotels are close to the beach?"}},
+		}
+
+		r := model.Highlight(c, "lisbon hotel")
+		if len(r.Matches) != 2 {
+			t.Fatalf("expected 2 matches, got %d", len(r.Matches))
+		}
+		if got, want := r.Matches[0].Snippet, "Trip to **Lisbon**"; got != want {
+			t.Errorf("got title snippet %q, want %q", got, want)
+		}
+		if got, want := r.Matches[1].Snippet, "Which **hotels** are close to the beach?"; got != want {
+			t.Errorf("got message snippet %q, want %q", got, want)
+		}
+	})
+
+	t.Run("cut snippets between runes", func(t *testing.T) {
+		text := "hotel " + strings.Repeat("x", 78) + "à la carte menu, served every evening in the restaurant"
+		c := &model.Conversation{Messages: []*model.Message{{Content: text}}}
+
+		r := model.Highlight(c, "hotel")
+		if len(r.Matches) != 1 {
+			t.Fatalf("expected 1 match, got %d", len(r.Matches))
+		}
+
+		want := "**hotel** " + strings.Repeat("x", 78) + "à…"
+		if got := r.Matches[0].Snippet; got != want || !utf8.ValidString(got) {
+			t.Errorf("got snippet %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+// Package recall indexes conversation messages by meaning, so the assistant
+// and the search RPC can find what was said in past conversations even when
+// the wording differs.
+package recall
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/embedding"
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/vector"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Namespace is the vector store namespace holding the message embeddings.
+const Namespace = "messages"
+
+// MinScore is the similarity below which messages are considered unrelated
+// to the query. It is tuned for the OpenAI text-embedding-3 models.
+const MinScore = 0.25
+
+// maxTextLength truncates long messages, so they fit the embedding model
+// input. The start of a message is usually the most telling part.
+const maxTextLength = 8000
+
+// Hit is a message similar to a query.
+type Hit struct {
+	ConversationID primitive.ObjectID
+	MessageID      primitive.ObjectID
+	Title          string
+	Role           model.Role
+	Content        string
+	CreatedAt      time.Time
+	Score          float64
+}
+
+type Index struct {
+	embedder embedding.Embedder
+	store    vector.Store
+}
+
+func NewIndex(embedder embedding.Embedder, store vector.Store) *Index {
+	return &Index{embedder: embedder, store: store}
+}
+
+// IndexMessages embeds and stores the given messages of the conversation.
+// Messages already indexed are replaced. Conversations of anonymous users
+// aren't indexed, as they can't be told apart, see Search.
+func (x *Index) IndexMessages(ctx context.Context, conv *model.Conversation, msgs ...*model.Message) error {
+	if conv.Owner == "" {
+		return nil
+	}
+
+	var texts []string
+	var indexed []*model.Message
+
+	for _, m := range msgs {
+		text := strings.TrimSpace(m.Content)
+		if text == "" {
+			continue
+		}
+
+		texts = append(texts, truncate(text, maxTextLength))
+		indexed = append(indexed, m)
+	}
+
+	if len(texts) == 0 {
+		return nil
+	}
+
+	vectors, err := x.embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+
+	records := make([]vector.Record, len(indexed))
+	for i, m := range indexed {
+		records[i] = vector.Record{
+			ID:     m.ID.Hex(),
+			Vector: vectors[i],
+			Text:   m.Content,
+			Metadata: map[string]string{
+				"conversation_id": conv.ID.Hex(),
+				"message_id":      m.ID.Hex(),
+				"owner":           conv.Owner,
+				"title":           conv.Title,
+				"role":            string(m.Role),
+				"created_at":      m.CreatedAt.UTC().Format(time.RFC3339),
+			},
+		}
+	}
+
+	return x.store.Upsert(ctx, Namespace, records...)
+}
+
+// IndexConversations indexes all messages of the conversations, e.g. to
+// backfill the index of an in-memory vector store on startup.
+func (x *Index) IndexConversations(ctx context.Context, convs []*model.Conversation) error {
+	for _, c := range convs {
+		if err := x.IndexMessages(ctx, c, c.Messages...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteConversation removes the messages of the conversation from the index.
+func (x *Index) DeleteConversation(ctx context.Context, id primitive.ObjectID) error {
+	return x.store.Delete(ctx, Namespace, vector.Filter{"conversation_id": id.Hex()})
+}
+
+// ConversationIDs returns the IDs of the conversations with indexed
+// messages, e.g. to remove the conversations deleted without DeleteConversation.
+func (x *Index) ConversationIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	values, err := x.store.Values(ctx, Namespace, "conversation_id")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		// Records are only written by IndexMessages, ignore what can't be parsed
+		if id, err := primitive.ObjectIDFromHex(v); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Search returns up to k messages of the owner's conversations closest in
+// meaning to the query, best first. Unrelated messages, scoring below
+// MinScore, are left out. Anonymous users, with an empty owner, have no
+// past conversations to search.
+func (x *Index) Search(ctx context.Context, owner, query string, k int) ([]*Hit, error) {
+	if owner == "" {
+		return nil, nil
+	}
+
+	vectors, err := x.embedder.Embed(ctx, []string{truncate(query, maxTextLength)})
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := x.store.Query(ctx, Namespace, vectors[0], k, vector.Filter{"owner": owner})
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]*Hit, 0, len(matches))
+	for _, m := range matches {
+		if m.Score < MinScore {
+			break
+		}
+
+		hit := &Hit{
+			Title:   m.Metadata["title"],
+			Role:    model.Role(m.Metadata["role"]),
+			Content: m.Text,
+			Score:   m.Score,
+		}
+
+		// Records are only written by IndexMessages, ignore what can't be parsed
+		hit.ConversationID, _ = primitive.ObjectIDFromHex(m.Metadata["conversation_id"])
+		hit.MessageID, _ = primitive.ObjectIDFromHex(m.Metadata["message_id"])
+		hit.CreatedAt, _ = time.Parse(time.RFC3339, m.Metadata["created_at"])
+
+		hits = append(hits, hit)
+	}
+
+	return hits, nil
+}
+
+// Excerpt shortens the text to about n bytes, cutting at a word boundary.
+func Excerpt(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= n {
+		return text
+	}
+
+	cut := truncate(text, n)
+	if i := strings.LastIndexByte(cut, ' '); i > n/2 {
+		cut = cut[:i]
+	}
+
+	return cut + "…"
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,92 @@
+package recall_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/recall"
+	. "github.com/acai-travel/tech-challenge/internal/chat/testing"
+	"github.com/acai-travel/tech-challenge/internal/vector"
+	"github.com/google/go-cmp/cmp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func conversation(owner string, contents ...string) *model.Conversation {
+	c := &model.Conversation{ID: primitive.NewObjectID(), Title: "Trip", Owner: owner}
+	for _, content := range contents {
+		c.Messages = append(c.Messages, &model.Message{ID: primitive.NewObjectID(), Role: model.RoleUser, Content: content, CreatedAt: time.Now()})
+	}
+	return c
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+
+	visa := conversation("jane", "Do I need a visa to travel to Japan?", "What is the weather in Paris?")
+	other := conversation("bob", "Do I need a visa to travel to Japan?")
+	anonymous := conversation("", "Do I need a visa to travel to Japan?")
+
+	index := recall.NewIndex(MockEmbedder{}, vector.NewMemoryStore())
+	if err := index.IndexConversations(ctx, []*model.Conversation{visa, other, anonymous}); err != nil {
+		t.Fatalf("failed to index conversations: %v", err)
+	}
+
+	t.Run("search the owner's messages by meaning", func(t *testing.T) {
+		hits, err := index.Search(ctx, "jane", "japan visa", 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(hits) == 0 || hits[0].MessageID != visa.Messages[0].ID {
+			t.Fatalf("expected message %s first, got %+v", visa.Messages[0].ID.Hex(), hits)
+		}
+		for _, h := range hits {
+			if h.ConversationID != visa.ID {
+				t.Errorf("expected only hits of conversation %s, got %s", visa.ID.Hex(), h.ConversationID.Hex())
+			}
+		}
+		if hits[0].Title != "Trip" || hits[0].Role != model.RoleUser || hits[0].CreatedAt.IsZero() {
+			t.Errorf("expected the hit metadata to be kept, got %+v", hits[0])
+		}
+	})
+
+	t.Run("find nothing for anonymous users", func(t *testing.T) {
+		hits, err := index.Search(ctx, "", "japan visa", 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(hits) != 0 {
+			t.Errorf("expected no hits, got %+v", hits)
+		}
+	})
+
+	t.Run("list the indexed conversations", func(t *testing.T) {
+		ids, err := index.ConversationIDs(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		slices.SortFunc(ids, func(a, b primitive.ObjectID) int { return strings.Compare(a.Hex(), b.Hex()) })
+		if diff := cmp.Diff([]primitive.ObjectID{visa.ID, other.ID}, ids); diff != "" {
+			t.Errorf("unexpected conversations (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("delete the messages of a conversation", func(t *testing.T) {
+		if err := index.DeleteConversation(ctx, other.ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		hits, err := index.Search(ctx, "bob", "japan visa", 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(hits) != 0 {
+			t.Errorf("expected no hits, got %+v", hits)
+		}
+	})
+}
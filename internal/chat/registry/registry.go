@@ -0,0 +1,253 @@
+// Package registry holds the tools the assistant can call. Tools declare
+// their arguments as Go structs, the registry derives the JSON Schema sent to
+// the model from them and validates the arguments of every call before the
+// tool runs.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// Tool is a function the model can call. Arguments are the JSON object
+// generated by the model, already validated against Parameters.
+type Tool interface {
+	Name() string
+	Description() string
+	Parameters() openai.FunctionParameters
+	Execute(ctx context.Context, arguments string) (string, error)
+}
+
+// Confirmable is implemented by tools with side effects, e.g. writing data,
+// the user has to approve before each call runs.
+type Confirmable interface {
+	RequiresConfirmation() bool
+}
+
+// RequireConfirmation marks the tool as needing the approval of the user
+// before each call, see Confirmable.
+func RequireConfirmation(t Tool) Tool {
+	return confirmed{t}
+}
+
+type confirmed struct {
+	Tool
+}
+
+func (confirmed) RequiresConfirmation() bool { return true }
+func (c confirmed) Unwrap() any              { return c.Tool }
+
+// As finds the first tool implementing I, following the chain of decorated
+// tools, e.g. to check whether a typed tool implements Confirmable.
+func As[I any](t any) (I, bool) {
+	for t != nil {
+		if i, ok := t.(I); ok {
+			return i, true
+		}
+
+		u, ok := t.(interface{ Unwrap() any })
+		if !ok {
+			break
+		}
+		t = u.Unwrap()
+	}
+
+	var zero I
+	return zero, false
+}
+
+// Func is a tool with typed arguments, see Typed.
+type Func[A any] interface {
+	Name() string
+	Description() string
+	Call(ctx context.Context, args A) (string, error)
+}
+
+// Typed adapts a tool with typed arguments to the Tool interface. The schema
+// of the arguments is derived from A with SchemaOf.
+func Typed[A any](f Func[A]) Tool {
+	return &typed[A]{fn: f, schema: SchemaOf[A]()}
+}
+
+type typed[A any] struct {
+	fn     Func[A]
+	schema openai.FunctionParameters
+}
+
+func (t *typed[A]) Name() string                          { return t.fn.Name() }
+func (t *typed[A]) Description() string                   { return t.fn.Description() }
+func (t *typed[A]) Parameters() openai.FunctionParameters { return t.schema }
+
+// Unwrap returns the typed tool, so As sees the interfaces it implements.
+func (t *typed[A]) Unwrap() any { return t.fn }
+
+func (t *typed[A]) Execute(ctx context.Context, arguments string) (string, error) {
+	var args A
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+	return t.fn.Call(ctx, args)
+}
+
+// Info describes a registered tool.
+type Info struct {
+	Name        string
+	Description string
+	Parameters  openai.FunctionParameters
+
+	// RequiresConfirmation is set for tools the user approves each call of
+	RequiresConfirmation bool
+}
+
+// namePattern is the tool name format accepted by the OpenAI API.
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type entry struct {
+	tool Tool
+
+	// schema is the JSON decoded form of the tool parameters, the form the
+	// validator works with
+	schema map[string]any
+}
+
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]*entry
+}
+
+func New() *Registry {
+	return &Registry{tools: map[string]*entry{}}
+}
+
+// Register adds the tool. Names must be unique and valid for the model API.
+func (r *Registry) Register(t Tool) error {
+	name := t.Name()
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid tool name %q", name)
+	}
+
+	data, err := json.Marshal(t.Parameters())
+	if err != nil {
+		return fmt.Errorf("tool %s: invalid parameters: %w", name, err)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("tool %s: invalid parameters: %w", name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tools[name]; ok {
+		return fmt.Errorf("tool %s is already registered", name)
+	}
+
+	r.tools[name] = &entry{tool: t, schema: schema}
+	return nil
+}
+
+// Unregister removes the tool, if registered.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.tools, name)
+}
+
+// Has reports whether a tool of that name is registered.
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.tools[name]
+	return ok
+}
+
+// Tools describes the registered tools, sorted by name.
+func (r *Registry) Tools() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]Info, 0, len(r.tools))
+	for _, e := range r.tools {
+		infos = append(infos, Info{
+			Name:                 e.tool.Name(),
+			Description:          e.tool.Description(),
+			Parameters:           e.tool.Parameters(),
+			RequiresConfirmation: requiresConfirmation(e.tool),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// RequiresConfirmation reports whether the user has to approve calls of the
+// tool before they run. Unknown tools don't require it, they fail to execute.
+func (r *Registry) RequiresConfirmation(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.tools[name]
+	return ok && requiresConfirmation(e.tool)
+}
+
+func requiresConfirmation(t Tool) bool {
+	c, ok := As[Confirmable](t)
+	return ok && c.RequiresConfirmation()
+}
+
+// Definitions returns the definitions of the registered tools for the model,
+// sorted by name, leaving out the disabled ones.
+func (r *Registry) Definitions(disabled []string) []openai.ChatCompletionToolUnionParam {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		if !slices.Contains(disabled, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	defs := make([]openai.ChatCompletionToolUnionParam, len(names))
+	for i, name := range names {
+		t := r.tools[name].tool
+		defs[i] = openai.ChatCompletionFunctionTool(openai.FunctionDefinitionParam{
+			Name:        t.Name(),
+			Description: openai.String(t.Description()),
+			Parameters:  t.Parameters(),
+		})
+	}
+
+	return defs
+}
+
+// Execute validates the arguments against the schema of the tool and runs
+// it. Invalid arguments return a *ValidationError without running the tool.
+func (r *Registry) Execute(ctx context.Context, name, arguments string) (string, error) {
+	r.mu.RLock()
+	e, ok := r.tools[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+
+	if problems := validate(e.schema, arguments); len(problems) > 0 {
+		return "", &ValidationError{Tool: name, Problems: problems}
+	}
+
+	return e.tool.Execute(ctx, arguments)
+}
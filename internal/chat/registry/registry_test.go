@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type bookArgs struct {
+	City      string   `json:"city" description:"City of the hotel"`
+	Nights    int      `json:"nights" minimum:"1" maximum:"30"`
+	Room      string   `json:"room,omitempty" enum:"single,double"`
+	Breakfast *bool    `json:"breakfast"`
+	Guests    []string `json:"guests,omitempty"`
+}
+
+type bookTool struct {
+	calls int
+}
+
+func (t *bookTool) Name() string        { return "book_hotel" }
+func (t *bookTool) Description() string { return "Books a hotel" }
+
+func (t *bookTool) Call(ctx context.Context, args bookArgs) (string, error) {
+	t.calls++
+	return args.City, nil
+}
+
+type renamedTool struct {
+	bookTool
+	name string
+}
+
+func (t *renamedTool) Name() string { return t.name }
+
+func TestSchemaOf(t *testing.T) {
+	got := SchemaOf[bookArgs]()
+
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"city":      map[string]any{"type": "string", "description": "City of the hotel"},
+			"nights":    map[string]any{"type": "integer", "minimum": 1.0, "maximum": 30.0},
+			"room":      map[string]any{"type": "string", "enum": []string{"single", "double"}},
+			"breakfast": map[string]any{"type": "boolean"},
+			"guests":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+		"required":             []string{"city", "nights"},
+		"additionalProperties": false,
+	}
+
+	if diff := cmp.Diff(want, map[string]any(got)); diff != "" {
+		t.Errorf("unexpected schema (-want +got):\n%s", diff)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	tool := &bookTool{}
+
+	r := New()
+	if err := r.Register(Typed[bookArgs](tool)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("rejects duplicate names", func(t *testing.T) {
+		if err := r.Register(Typed[bookArgs](&bookTool{})); err == nil {
+			t.Fatal("expected error registering a tool twice")
+		}
+	})
+
+	t.Run("executes valid calls", func(t *testing.T) {
+		out, err := r.Execute(ctx, "book_hotel", `{"city": "Lisbon", "nights": 2, "room": "double", "guests": ["Ana"]}`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out != "Lisbon" {
+			t.Errorf("got %q, want Lisbon", out)
+		}
+	})
+
+	t.Run("reports every problem without running the tool", func(t *testing.T) {
+		calls := tool.calls
+
+		_, err := r.Execute(ctx, "book_hotel", `{"nights": 2.5, "room": "suite", "guests": [1], "pets": true}`)
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+
+		want := []string{
+			`missing required field "city"`,
+			`guests[0]: expected a string, got number 1`,
+			`nights: expected an integer, got number 2.5`,
+			`unknown field "pets"`,
+			`room: must be one of single, double`,
+		}
+		if diff := cmp.Diff(want, verr.Problems); diff != "" {
+			t.Errorf("unexpected problems (-want +got):\n%s", diff)
+		}
+
+		if tool.calls != calls {
+			t.Error("expected the tool not to run")
+		}
+	})
+
+	t.Run("checks bounds and JSON syntax", func(t *testing.T) {
+		for args, want := range map[string]string{
+			`{"city": "Rome", "nights": 0}`:  "nights: must be at least 1",
+			`{"city": "Rome", "nights": 31}`: "nights: must be at most 30",
+			`{"city": "Rome"`:                "arguments are not valid JSON",
+			`["Rome"]`:                       "expected an object, got an array",
+		} {
+			_, err := r.Execute(ctx, "book_hotel", args)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Execute(%s) error = %v, want it to contain %q", args, err, want)
+			}
+		}
+	})
+
+	t.Run("leaves disabled tools out of the definitions", func(t *testing.T) {
+		if defs := r.Definitions(nil); len(defs) != 1 {
+			t.Errorf("got %d definitions, want 1", len(defs))
+		}
+		if defs := r.Definitions([]string{"book_hotel"}); len(defs) != 0 {
+			t.Errorf("got %d definitions, want 0", len(defs))
+		}
+	})
+
+	t.Run("tells tools requiring confirmation", func(t *testing.T) {
+		r := New()
+		_ = r.Register(Typed[bookArgs](&bookTool{}))
+		_ = r.Register(RequireConfirmation(Typed[bookArgs](&renamedTool{name: "cancel_hotel"})))
+
+		if r.RequiresConfirmation("book_hotel") || !r.RequiresConfirmation("cancel_hotel") {
+			t.Error("expected only cancel_hotel to require confirmation")
+		}
+		if infos := r.Tools(); infos[0].Name != "book_hotel" || !infos[1].RequiresConfirmation {
+			t.Errorf("unexpected tools %+v", infos)
+		}
+	})
+
+	t.Run("fails on unknown tools", func(t *testing.T) {
+		if _, err := r.Execute(ctx, "book_flight", `{}`); err == nil {
+			t.Fatal("expected error for an unknown tool")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package registry
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// SchemaOf returns the JSON Schema of the arguments struct A, as sent to the
+// model. Fields are described with struct tags:
+//
+//   - json: the property name. Fields with omitempty or of pointer type are
+//     optional, all others are required.
+//   - description: what the model should pass.
+//   - enum: comma-separated list of allowed values.
+//   - minimum, maximum: bounds of numeric fields.
+//
+// It panics if A is not a struct or has fields of unsupported types, as that
+// is a programming error found when the tool is registered.
+func SchemaOf[A any]() openai.FunctionParameters {
+	t := reflect.TypeFor[A]()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("registry: arguments must be a struct, got %s", t))
+	}
+
+	return schemaOf(t)
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func schemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Struct:
+		return objectSchema(t)
+	}
+
+	panic(fmt.Sprintf("registry: unsupported argument type %s", t))
+}
+
+func objectSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := schemaOf(f.Type)
+		if v := f.Tag.Get("description"); v != "" {
+			prop["description"] = v
+		}
+		if v := f.Tag.Get("enum"); v != "" {
+			prop["enum"] = strings.Split(v, ",")
+		}
+		for _, key := range []string{"minimum", "maximum"} {
+			if v := f.Tag.Get(key); v != "" {
+				n, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					panic(fmt.Sprintf("registry: invalid %s %q of field %s", key, v, f.Name))
+				}
+				prop[key] = n
+			}
+		}
+
+		properties[name] = prop
+
+		optional := f.Type.Kind() == reflect.Pointer || strings.Contains(","+opts+",", ",omitempty,")
+		if !optional {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
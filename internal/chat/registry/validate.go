@@ -0,0 +1,188 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ValidationError lists why the arguments of a tool call don't match the
+// schema of the tool. Its message is meant for the model, so it can correct
+// the call.
+type ValidationError struct {
+	Tool     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s: %s", e.Tool, strings.Join(e.Problems, "; "))
+}
+
+// validate checks the JSON arguments against the schema. It supports the
+// subset of JSON Schema produced by SchemaOf and commonly used by tools:
+// type, properties, required, additionalProperties, items, enum, minimum and
+// maximum. Other keywords are ignored.
+func validate(schema map[string]any, arguments string) []string {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(arguments)))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("arguments are not valid JSON: %v", err)}
+	}
+
+	var problems []string
+	check(schema, value, "", &problems)
+	return problems
+}
+
+func check(schema map[string]any, value any, path string, problems *[]string) {
+	report := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		if path != "" {
+			msg = path + ": " + msg
+		}
+		*problems = append(*problems, msg)
+	}
+
+	if typ, ok := schema["type"].(string); ok && !hasType(value, typ) {
+		report("expected %s, got %s", article(typ), describe(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return equal(e, value) }) {
+		allowed := make([]string, len(enum))
+		for i, e := range enum {
+			allowed[i] = fmt.Sprint(e)
+		}
+		report("must be one of %s", strings.Join(allowed, ", "))
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		n, _ := v.Float64()
+		if lo, ok := schema["minimum"].(float64); ok && n < lo {
+			report("must be at least %v", lo)
+		}
+		if hi, ok := schema["maximum"].(float64); ok && n > hi {
+			report("must be at most %v", hi)
+		}
+
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				check(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, ok := v[name]; !ok {
+					report("missing required field %q", join(path, name))
+				}
+			}
+		}
+
+		// Sorted, so problems are reported in a stable order
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			prop, ok := properties[name].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					report("unknown field %q", join(path, name))
+				}
+				continue
+			}
+			check(prop, v[name], join(path, name), problems)
+		}
+	}
+}
+
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func describe(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case json.Number:
+		return "number " + v.String()
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func article(typ string) string {
+	switch typ {
+	case "object", "array", "integer":
+		return "an " + typ
+	}
+	return "a " + typ
+}
+
+func equal(a, b any) bool {
+	if n, ok := b.(json.Number); ok {
+		f, _ := n.Float64()
+		x, ok := a.(float64)
+		return ok && x == f
+	}
+	return a == b
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
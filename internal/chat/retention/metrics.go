@@ -0,0 +1,71 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// metrics of the purges.
+type metrics struct {
+	purges   metric.Int64Counter
+	duration metric.Float64Histogram
+	deleted  metric.Int64Counter
+}
+
+func newMetrics(meter metric.Meter) (*metrics, error) {
+	m := &metrics{}
+
+	var err error
+
+	// Number of purges by outcome
+	m.purges, err = meter.Int64Counter(
+		"retention.purges",
+		metric.WithDescription("Number of purges of expired conversations by outcome"),
+		metric.WithUnit("{purge}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create purges counter: %w", err)
+	}
+
+	// Purge duration in seconds
+	m.duration, err = meter.Float64Histogram(
+		"retention.purge.duration",
+		metric.WithDescription("Duration of purges of expired conversations"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create purge duration histogram: %w", err)
+	}
+
+	// Number of documents deleted, or that would be in dry runs, by kind
+	m.deleted, err = meter.Int64Counter(
+		"retention.purged",
+		metric.WithDescription("Number of expired documents purged by kind"),
+		metric.WithUnit("{document}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create purged documents counter: %w", err)
+	}
+
+	return m, nil
+}
+
+func (m *metrics) recordPurge(ctx context.Context, result Result, d time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+
+	dryRun := attribute.Bool("retention.dry_run", result.DryRun)
+	attrs := metric.WithAttributes(dryRun, attribute.String("retention.outcome", outcome))
+
+	m.purges.Add(ctx, 1, attrs)
+	m.duration.Record(ctx, d.Seconds(), attrs)
+
+	m.deleted.Add(ctx, result.Conversations, metric.WithAttributes(dryRun, attribute.String("retention.kind", "conversation")))
+	m.deleted.Add(ctx, result.Itineraries, metric.WithAttributes(dryRun, attribute.String("retention.kind", "itinerary")))
+}
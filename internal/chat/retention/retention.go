@@ -0,0 +1,236 @@
+// Package retention deletes conversations kept longer than the retention
+// policy of the deployment and of its users allows, as they hold personal
+// data of travellers.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/recall"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// batchSize is the number of conversations deleted at once.
+const batchSize = 500
+
+// Policy is how long conversations are kept after their last update.
+type Policy struct {
+	// MaxAge applies to the conversations of all users, 0 keeps them
+	MaxAge time.Duration
+
+	// Users overrides MaxAge for the conversations of some owners, 0 keeps
+	// theirs
+	Users map[string]time.Duration
+}
+
+// Enabled reports whether the policy deletes any conversation.
+func (p Policy) Enabled() bool {
+	if p.MaxAge > 0 {
+		return true
+	}
+
+	for _, age := range p.Users {
+		if age > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// filters returns the conversations expired at now: those of each user with
+// an override, then those of everyone else.
+func (p Policy) filters(now time.Time) []model.StaleFilter {
+	users := make([]string, 0, len(p.Users))
+	for user := range p.Users {
+		users = append(users, user)
+	}
+	slices.Sort(users)
+
+	var filters []model.StaleFilter
+	for _, user := range users {
+		if age := p.Users[user]; age > 0 {
+			filters = append(filters, model.StaleFilter{Before: now.Add(-age), Owners: []string{user}})
+		}
+	}
+
+	if p.MaxAge > 0 {
+		filters = append(filters, model.StaleFilter{Before: now.Add(-p.MaxAge), ExceptOwners: users})
+	}
+
+	return filters
+}
+
+// Result is the outcome of a purge. In dry runs, it counts what would have
+// been deleted.
+type Result struct {
+	Conversations int64
+	Itineraries   int64
+	DryRun        bool
+}
+
+// Purger deletes the conversations expired by the policy.
+type Purger struct {
+	repo    *model.Repository
+	recall  *recall.Index
+	policy  Policy
+	now     func() time.Time
+	metrics *metrics
+}
+
+type Option func(p *Purger)
+
+// WithRecall removes the messages of deleted conversations from the index,
+// so they can't be recalled, including conversations expired by the TTL
+// index.
+func WithRecall(index *recall.Index) Option {
+	return func(p *Purger) {
+		p.recall = index
+	}
+}
+
+func New(repo *model.Repository, policy Policy, opts ...Option) *Purger {
+	metrics, err := newMetrics(otel.Meter("acai.chat.retention"))
+	if err != nil {
+		slog.Error("Failed to create retention metrics", "error", err)
+		metrics, _ = newMetrics(noop.Meter{})
+	}
+
+	p := &Purger{repo: repo, policy: policy, now: time.Now, metrics: metrics}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Purge deletes the expired conversations with their itineraries, and what
+// is left by conversations expired by the TTL index: their itineraries and
+// indexed messages. With dryRun set nothing is deleted, the result counts
+// what would be.
+func (p *Purger) Purge(ctx context.Context, dryRun bool) (Result, error) {
+	start := time.Now()
+	result, err := p.purge(ctx, dryRun)
+	p.metrics.recordPurge(ctx, result, time.Since(start), err)
+	return result, err
+}
+
+func (p *Purger) purge(ctx context.Context, dryRun bool) (Result, error) {
+	result := Result{DryRun: dryRun}
+
+	for _, f := range p.policy.filters(p.now()) {
+		if dryRun {
+			conversations, itineraries, err := p.repo.CountStaleConversations(ctx, f)
+			if err != nil {
+				return result, err
+			}
+			result.Conversations += conversations
+			result.Itineraries += itineraries
+			continue
+		}
+
+		for {
+			ids, err := p.repo.ListStaleConversations(ctx, f, batchSize)
+			if err != nil {
+				return result, err
+			}
+
+			if err := p.delete(ctx, f, ids, &result); err != nil {
+				return result, err
+			}
+
+			if len(ids) < batchSize {
+				break
+			}
+		}
+	}
+
+	orphans, err := p.repo.DeleteOrphanItineraries(ctx, dryRun)
+	if err != nil {
+		return result, err
+	}
+	result.Itineraries += orphans
+
+	if dryRun {
+		return result, nil
+	}
+	return result, p.reconcile(ctx)
+}
+
+func (p *Purger) delete(ctx context.Context, f model.StaleFilter, ids []primitive.ObjectID, result *Result) error {
+	deleted, itineraries, err := p.repo.DeleteConversations(ctx, f, ids)
+	if err != nil {
+		return err
+	}
+	result.Conversations += int64(len(deleted))
+	result.Itineraries += itineraries
+
+	return p.unindex(ctx, deleted)
+}
+
+// reconcile removes the conversations deleted without the purger, e.g.
+// expired by the TTL index, from the recall index.
+func (p *Purger) reconcile(ctx context.Context) error {
+	if p.recall == nil {
+		return nil
+	}
+
+	ids, err := p.recall.ConversationIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for batch := range slices.Chunk(ids, batchSize) {
+		existing, err := p.repo.ExistingConversations(ctx, batch)
+		if err != nil {
+			return err
+		}
+
+		gone := slices.DeleteFunc(batch, func(id primitive.ObjectID) bool { return existing[id] })
+		if err := p.unindex(ctx, gone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindex removes the messages of the conversations from the recall index.
+func (p *Purger) unindex(ctx context.Context, ids []primitive.ObjectID) error {
+	if p.recall == nil {
+		return nil
+	}
+
+	for _, id := range ids {
+		if err := p.recall.DeleteConversation(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run purges every interval until ctx is done. Failed purges are logged and
+// retried on the next tick.
+func (p *Purger) Run(ctx context.Context, interval time.Duration, dryRun bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := p.Purge(ctx, dryRun)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to purge expired conversations", "error", err)
+		} else if err == nil && (result.Conversations > 0 || result.Itineraries > 0) {
+			slog.InfoContext(ctx, "Purged expired conversations", "conversations", result.Conversations,
+				"itineraries", result.Itineraries, "dry_run", dryRun)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
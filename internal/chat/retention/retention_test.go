@@ -0,0 +1,44 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPolicy(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	t.Run("users override the deployment retention", func(t *testing.T) {
+		policy := Policy{MaxAge: 90 * day, Users: map[string]time.Duration{"bob": 0, "alice": 30 * day}}
+		if !policy.Enabled() {
+			t.Fatal("expected the policy to be enabled")
+		}
+
+		want := []model.StaleFilter{
+			{Before: now.Add(-30 * day), Owners: []string{"alice"}},
+			{Before: now.Add(-90 * day), ExceptOwners: []string{"alice", "bob"}},
+		}
+		if diff := cmp.Diff(want, policy.filters(now)); diff != "" {
+			t.Errorf("filters() mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("only users with a retention expire without a deployment one", func(t *testing.T) {
+		policy := Policy{Users: map[string]time.Duration{"alice": 7 * day}}
+
+		want := []model.StaleFilter{{Before: now.Add(-7 * day), Owners: []string{"alice"}}}
+		if diff := cmp.Diff(want, policy.filters(now)); diff != "" {
+			t.Errorf("filters() mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("keeping everything is disabled", func(t *testing.T) {
+		if policy := (Policy{Users: map[string]time.Duration{"bob": 0}}); policy.Enabled() {
+			t.Error("expected the policy to be disabled")
+		}
+	})
+}
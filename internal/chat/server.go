@@ -1,38 +1,111 @@
 package chat
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/acai-travel/tech-challenge/internal/chat/archive"
+	"github.com/acai-travel/tech-challenge/internal/chat/calendar"
+	"github.com/acai-travel/tech-challenge/internal/chat/knowledge"
+	"github.com/acai-travel/tech-challenge/internal/chat/llm"
 	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/recall"
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"github.com/acai-travel/tech-challenge/internal/chat/retention"
+	"github.com/acai-travel/tech-challenge/internal/httpx"
 	"github.com/acai-travel/tech-challenge/internal/pb"
 	"github.com/twitchtv/twirp"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric/noop"
 )
 
 var _ pb.ChatService = (*Server)(nil)
 
 type Assistant interface {
 	Title(ctx context.Context, conv *model.Conversation) (string, error)
-	Reply(ctx context.Context, conv *model.Conversation) (string, error)
+	// Reply returns a nil message when the reply is paused for the user to
+	// confirm tool calls, stored in conv.Pending. Resume continues it once
+	// all the pending actions are decided.
+	Reply(ctx context.Context, conv *model.Conversation) (*model.Message, error)
+	// RunActions runs the decided actions of conv.Pending, stored before the
+	// reply resumes so a failed reply doesn't run them again.
+	RunActions(ctx context.Context, conv *model.Conversation) error
+	Resume(ctx context.Context, conv *model.Conversation) (*model.Message, error)
+	Tools() []registry.Info
 }
 
 type Server struct {
-	repo   *model.Repository
-	assist Assistant
+	repo      *model.Repository
+	assist    Assistant
+	recall    *recall.Index
+	knowledge *knowledge.Base
+	purger    *retention.Purger
+	admins    []string
+	metrics   *metrics
 }
 
-func NewServer(repo *model.Repository, assist Assistant) *Server {
-	return &Server{repo: repo, assist: assist}
+type Option func(*Server)
+
+// WithRecall indexes new messages for semantic search and enables the
+// SEMANTIC search mode.
+func WithRecall(index *recall.Index) Option {
+	return func(s *Server) {
+		s.recall = index
+	}
+}
+
+// WithKnowledgeBase enables the document RPCs.
+func WithKnowledgeBase(kb *knowledge.Base) Option {
+	return func(s *Server) {
+		s.knowledge = kb
+	}
+}
+
+// WithRetention enables the PurgeConversations RPC.
+func WithRetention(p *retention.Purger) Option {
+	return func(s *Server) {
+		s.purger = p
+	}
+}
+
+// WithAdmins sets the IDs of the users allowed to call the admin RPCs:
+// purging conversations and managing documents.
+func WithAdmins(admins ...string) Option {
+	return func(s *Server) {
+		s.admins = admins
+	}
+}
+
+func NewServer(repo *model.Repository, assist Assistant, opts ...Option) *Server {
+	metrics, err := newMetrics(otel.Meter("acai.chat"))
+	if err != nil {
+		// Conversations are served without metrics rather than not at all
+		slog.Error("Failed to create chat metrics", "error", err)
+		metrics, _ = newMetrics(noop.Meter{})
+	}
+
+	s := &Server{repo: repo, assist: assist, metrics: metrics}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Server) StartConversation(ctx context.Context, req *pb.StartConversationRequest) (*pb.StartConversationResponse, error) {
 	conversation := &model.Conversation{
 		ID:        primitive.NewObjectID(),
 		Title:     "Untitled conversation",
+		Owner:     httpx.UserFrom(ctx),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Messages: []*model.Message{{
@@ -48,6 +121,12 @@ func (s *Server) StartConversation(ctx context.Context, req *pb.StartConversatio
 		return nil, twirp.RequiredArgumentError("message")
 	}
 
+	disabled, err := s.toolNames("disabled_tools", req.GetDisabledTools())
+	if err != nil {
+		return nil, err
+	}
+	conversation.DisabledTools = disabled
+
 	// lets paralellize title and reply generation
 	// channels to receive results
 	titleChan := make(chan struct {
@@ -56,7 +135,7 @@ func (s *Server) StartConversation(ctx context.Context, req *pb.StartConversatio
 	}, 1)
 
 	replyChan := make(chan struct {
-		reply string
+		reply *model.Message
 		err   error
 	}, 1)
 
@@ -79,7 +158,7 @@ func (s *Server) StartConversation(ctx context.Context, req *pb.StartConversatio
 		// generate a reply
 		reply, err := s.assist.Reply(ctx, conversation)
 		replyChan <- struct {
-			reply string
+			reply *model.Message
 			err   error
 		}{reply: reply, err: err}
 	}(ctx, conversation)
@@ -93,30 +172,33 @@ func (s *Server) StartConversation(ctx context.Context, req *pb.StartConversatio
 	// Handle results
 	if titleResult.err != nil {
 		slog.ErrorContext(ctx, "Failed to generate conversation title", "error", titleResult.err)
+		s.metrics.recordTitleFailure(ctx, titleResult.err)
 	} else {
 		conversation.Title = titleResult.title
 	}
 
 	if replyResult.err != nil {
-		return nil, replyResult.err
+		return nil, replyError(replyResult.err)
 	}
 
-	conversation.Messages = append(conversation.Messages, &model.Message{
-		ID:        primitive.NewObjectID(),
-		Role:      model.RoleAssistant,
-		Content:   replyResult.reply,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	})
+	if replyResult.reply != nil {
+		conversation.Messages = append(conversation.Messages, replyResult.reply)
+	}
 
 	if err := s.repo.CreateConversation(ctx, conversation); err != nil {
 		return nil, err
 	}
 
+	s.metrics.recordStarted(ctx)
+	s.metrics.recordMessages(ctx, conversation, conversation.Messages...)
+	s.index(ctx, conversation, conversation.Messages...)
+
 	return &pb.StartConversationResponse{
 		ConversationId: conversation.ID.Hex(),
 		Title:          conversation.Title,
-		Reply:          replyResult.reply,
+		Reply:          content(replyResult.reply),
+		Citations:      citationsProto(replyResult.reply),
+		PendingActions: pendingActionsProto(conversation),
 	}, nil
 }
 
@@ -134,6 +216,10 @@ func (s *Server) ContinueConversation(ctx context.Context, req *pb.ContinueConve
 		return nil, err
 	}
 
+	if conversation.Pending != nil {
+		return nil, twirp.NewError(twirp.FailedPrecondition, "conversation has pending actions, approve or reject them first")
+	}
+
 	conversation.UpdatedAt = time.Now()
 	conversation.Messages = append(conversation.Messages, &model.Message{
 		ID:        primitive.NewObjectID(),
@@ -145,22 +231,215 @@ func (s *Server) ContinueConversation(ctx context.Context, req *pb.ContinueConve
 
 	reply, err := s.assist.Reply(ctx, conversation)
 	if err != nil {
-		return nil, twirp.InternalErrorWith(err)
+		return nil, replyError(err)
 	}
 
-	conversation.Messages = append(conversation.Messages, &model.Message{
-		ID:        primitive.NewObjectID(),
-		Role:      model.RoleAssistant,
-		Content:   reply,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	})
+	if reply != nil {
+		conversation.Messages = append(conversation.Messages, reply)
+	}
 
 	if err := s.repo.UpdateConversation(ctx, conversation); err != nil {
 		return nil, twirp.InternalErrorWith(err)
 	}
 
-	return &pb.ContinueConversationResponse{Reply: reply}, nil
+	// The message of the user, and the reply unless it is paused
+	added := conversation.Messages[len(conversation.Messages)-1:]
+	if reply != nil {
+		added = conversation.Messages[len(conversation.Messages)-2:]
+	}
+
+	s.metrics.recordMessages(ctx, conversation, added...)
+	s.index(ctx, conversation, added...)
+
+	return &pb.ContinueConversationResponse{
+		Reply:          content(reply),
+		Citations:      citationsProto(reply),
+		PendingActions: pendingActionsProto(conversation),
+	}, nil
+}
+
+func (s *Server) ApproveAction(ctx context.Context, req *pb.ApproveActionRequest) (*pb.ApproveActionResponse, error) {
+	conversation, reply, err := s.decideAction(ctx, req.GetConversationId(), req.GetActionId(), model.ActionApproved, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ApproveActionResponse{
+		Reply:          content(reply),
+		Citations:      citationsProto(reply),
+		PendingActions: pendingActionsProto(conversation),
+	}, nil
+}
+
+func (s *Server) RejectAction(ctx context.Context, req *pb.RejectActionRequest) (*pb.RejectActionResponse, error) {
+	conversation, reply, err := s.decideAction(ctx, req.GetConversationId(), req.GetActionId(), model.ActionRejected, req.GetReason())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RejectActionResponse{
+		Reply:          content(reply),
+		Citations:      citationsProto(reply),
+		PendingActions: pendingActionsProto(conversation),
+	}, nil
+}
+
+// decideAction records the decision of the user on a pending action. Once
+// all the actions of the paused reply are decided, the request deciding the
+// last one runs them, stores their results, and resumes the reply: the new
+// message is returned, nil while actions are still pending. Deciding an
+// action again retries a reply that failed to resume after the actions ran.
+func (s *Server) decideAction(ctx context.Context, conversationID, actionID string, status model.ActionStatus, reason string) (*model.Conversation, *model.Message, error) {
+	if conversationID == "" {
+		return nil, nil, twirp.RequiredArgumentError("conversation_id")
+	}
+
+	if actionID == "" {
+		return nil, nil, twirp.RequiredArgumentError("action_id")
+	}
+
+	conversation, err := s.repo.DecideAction(ctx, conversationID, actionID, status, strings.TrimSpace(reason))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if conversation == nil {
+		conversation, err = s.undecidableAction(ctx, conversationID, actionID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if !conversation.Pending.Decided() {
+		return conversation, nil, nil
+	}
+
+	if !conversation.Pending.Ran {
+		if err := s.assist.RunActions(ctx, conversation); err != nil {
+			return nil, nil, twirp.InternalErrorWith(err)
+		}
+
+		if err := s.repo.UpdateConversation(ctx, conversation); err != nil {
+			return nil, nil, twirp.InternalErrorWith(err)
+		}
+	}
+
+	reply, err := s.assist.Resume(ctx, conversation)
+	if err != nil {
+		return nil, nil, replyError(err)
+	}
+
+	conversation.UpdatedAt = time.Now()
+	if reply != nil {
+		conversation.Messages = append(conversation.Messages, reply)
+	}
+
+	if err := s.repo.UpdateConversation(ctx, conversation); err != nil {
+		return nil, nil, twirp.InternalErrorWith(err)
+	}
+
+	if reply != nil {
+		s.metrics.recordMessages(ctx, conversation, reply)
+		s.index(ctx, conversation, reply)
+	}
+
+	return conversation, reply, nil
+}
+
+// undecidableAction returns the error of an action that isn't pending: it
+// doesn't exist or was already decided. The conversation is returned instead
+// when its actions ran but the reply failed to resume, to retry it.
+func (s *Server) undecidableAction(ctx context.Context, conversationID, actionID string) (*model.Conversation, error) {
+	conversation, err := s.repo.DescribeConversation(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation.Pending == nil {
+		return nil, twirp.NotFoundError("action not found")
+	}
+
+	action, ok := conversation.Pending.Action(actionID)
+	if !ok {
+		return nil, twirp.NotFoundError("action not found")
+	}
+
+	if !conversation.Pending.Ran {
+		return nil, twirp.NewErrorf(twirp.FailedPrecondition, "action was already %s", action.Status)
+	}
+
+	return conversation, nil
+}
+
+// replyError maps errors generating a reply to twirp errors, so clients can
+// tell failures worth retrying later from internal ones.
+func replyError(err error) error {
+	var twerr twirp.Error
+	switch {
+	case errors.As(err, &twerr):
+		return twerr
+	case errors.Is(err, context.DeadlineExceeded):
+		return twirp.NewError(twirp.DeadlineExceeded, "the assistant took too long to reply")
+	case errors.Is(err, llm.ErrRateLimited):
+		return twirp.WrapError(twirp.NewError(twirp.ResourceExhausted, "the assistant is receiving too many requests, try again later"), err)
+	case errors.Is(err, llm.ErrUnavailable):
+		return twirp.WrapError(twirp.NewError(twirp.Unavailable, "the assistant is unavailable, try again later"), err)
+	default:
+		return twirp.InternalErrorWith(err)
+	}
+}
+
+// content returns the content of the reply, empty when it is paused.
+func content(m *model.Message) string {
+	if m == nil {
+		return ""
+	}
+	return m.Content
+}
+
+func citationsProto(m *model.Message) []*pb.Citation {
+	if m == nil {
+		return nil
+	}
+
+	var out []*pb.Citation
+	for _, c := range m.Citations {
+		out = append(out, c.Proto())
+	}
+	return out
+}
+
+// pendingActionsProto returns the actions the paused reply of the
+// conversation waits for, if any.
+func pendingActionsProto(c *model.Conversation) []*pb.PendingAction {
+	if c.Pending == nil {
+		return nil
+	}
+
+	var out []*pb.PendingAction
+	for _, a := range c.Pending.Undecided() {
+		out = append(out, a.Proto())
+	}
+	return out
+}
+
+// indexTimeout bounds the background embedding of new messages.
+const indexTimeout = 30 * time.Second
+
+// index embeds new messages in the background, so replies aren't delayed.
+// A message failing to index is only missing from semantic search results.
+func (s *Server) index(ctx context.Context, conv *model.Conversation, msgs ...*model.Message) {
+	if s.recall == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), indexTimeout)
+	go func() {
+		defer cancel()
+		if err := s.recall.IndexMessages(ctx, conv, msgs...); err != nil {
+			slog.ErrorContext(ctx, "Failed to index messages", "conversation_id", conv.ID, "error", err)
+		}
+	}()
 }
 
 func (s *Server) ListConversations(ctx context.Context, req *pb.ListConversationsRequest) (*pb.ListConversationsResponse, error) {
@@ -194,3 +473,592 @@ func (s *Server) DescribeConversation(ctx context.Context, req *pb.DescribeConve
 
 	return &pb.DescribeConversationResponse{Conversation: conversation.Proto()}, nil
 }
+
+func (s *Server) GetItinerary(ctx context.Context, req *pb.GetItineraryRequest) (*pb.GetItineraryResponse, error) {
+	if req.GetConversationId() == "" {
+		return nil, twirp.RequiredArgumentError("conversation_id")
+	}
+
+	itinerary, err := s.repo.DescribeItinerary(ctx, req.GetConversationId())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetItineraryResponse{Itinerary: itinerary.Proto()}, nil
+}
+
+func (s *Server) ListItineraries(ctx context.Context, req *pb.ListItinerariesRequest) (*pb.ListItinerariesResponse, error) {
+	itineraries, err := s.repo.ListItineraries(ctx)
+	if err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	resp := &pb.ListItinerariesResponse{}
+	for _, it := range itineraries {
+		resp.Itineraries = append(resp.Itineraries, it.Proto())
+	}
+
+	return resp, nil
+}
+
+func (s *Server) ExportCalendar(ctx context.Context, req *pb.ExportCalendarRequest) (*pb.ExportCalendarResponse, error) {
+	if req.GetConversationId() == "" {
+		return nil, twirp.RequiredArgumentError("conversation_id")
+	}
+
+	conversation, err := s.repo.DescribeConversation(ctx, req.GetConversationId())
+	if err != nil {
+		return nil, err
+	}
+
+	itinerary, err := s.repo.DescribeItinerary(ctx, req.GetConversationId())
+	if te, ok := err.(twirp.Error); ok && te.Code() == twirp.NotFound {
+		itinerary, err = nil, nil
+	}
+
+	if err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	if itinerary == nil && len(conversation.Events) == 0 {
+		return nil, twirp.NewError(twirp.FailedPrecondition, "conversation has no itinerary or events to export")
+	}
+
+	content, err := calendar.Export(conversation, itinerary)
+	if err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	return &pb.ExportCalendarResponse{
+		Filename:    "conversation-" + conversation.ID.Hex() + ".ics",
+		ContentType: calendar.ContentType,
+		Content:     content,
+	}, nil
+}
+
+func (s *Server) ExportConversations(ctx context.Context, req *pb.ExportConversationsRequest) (*pb.ExportConversationsResponse, error) {
+	format, err := archiveFormat(req.GetFormat())
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers only export their own conversations, so a bulk export needs to
+	// know who is calling
+	owner := httpx.UserFrom(ctx)
+
+	var conversations []*model.Conversation
+	if len(req.GetConversationIds()) == 0 {
+		if owner == "" {
+			return nil, twirp.NewError(twirp.Unauthenticated, "exporting all conversations requires the "+httpx.UserHeader+" header")
+		}
+		if conversations, err = s.repo.ListOwnerConversations(ctx, owner); err != nil {
+			return nil, twirp.InternalErrorWith(err)
+		}
+	}
+
+	for _, id := range req.GetConversationIds() {
+		conversation, err := s.repo.DescribeConversation(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if conversation.Owner != owner {
+			// Don't tell the caller the conversation exists
+			return nil, twirp.NotFoundError("conversation not found")
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	var buf bytes.Buffer
+	if err := archive.Encode(&buf, format, conversations); err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	return &pb.ExportConversationsResponse{
+		Filename:    "conversations-" + time.Now().UTC().Format("20060102-150405") + format.Extension(),
+		ContentType: format.ContentType(),
+		Content:     buf.String(),
+	}, nil
+}
+
+func (s *Server) ImportConversations(ctx context.Context, req *pb.ImportConversationsRequest) (*pb.ImportConversationsResponse, error) {
+	format, err := archiveFormat(req.GetFormat())
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(req.GetContent()) == "" {
+		return nil, twirp.RequiredArgumentError("content")
+	}
+
+	conversations, err := archive.Decode(strings.NewReader(req.GetContent()), format)
+	if err != nil {
+		return nil, twirp.InvalidArgumentError("content", err.Error())
+	}
+
+	// Check for conflicts first, so a failed import doesn't leave a partial copy behind
+	for _, c := range conversations {
+		if len(c.DisabledTools) > 0 {
+			if _, err := s.toolNames("content", c.DisabledTools); err != nil {
+				return nil, err
+			}
+		}
+
+		_, err := s.repo.DescribeConversation(ctx, c.ID.Hex())
+		if err == nil {
+			return nil, twirp.NewErrorf(twirp.AlreadyExists, "conversation %s already exists", c.ID.Hex())
+		}
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.NotFound {
+			return nil, twirp.InternalErrorWith(err)
+		}
+	}
+
+	resp := &pb.ImportConversationsResponse{}
+	for _, c := range conversations {
+		// Imported conversations belong to the caller, whoever exported them
+		c.Owner = httpx.UserFrom(ctx)
+
+		if err := s.repo.CreateConversation(ctx, c); err != nil {
+			return nil, twirp.InternalErrorWith(err)
+		}
+
+		s.index(ctx, c, c.Messages...)
+		resp.ConversationIds = append(resp.ConversationIds, c.ID.Hex())
+	}
+
+	return resp, nil
+}
+
+func archiveFormat(f pb.ExportFormat) (archive.Format, error) {
+	switch f {
+	case pb.ExportFormat_JSON:
+		return archive.FormatJSON, nil
+	case pb.ExportFormat_MARKDOWN:
+		return archive.FormatMarkdown, nil
+	case pb.ExportFormat_JSONL:
+		return archive.FormatJSONL, nil
+	default:
+		return "", twirp.InvalidArgumentError("format", "must be one of JSON, MARKDOWN or JSONL")
+	}
+}
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+func (s *Server) SearchConversations(ctx context.Context, req *pb.SearchConversationsRequest) (*pb.SearchConversationsResponse, error) {
+	if strings.TrimSpace(req.GetQuery()) == "" {
+		return nil, twirp.RequiredArgumentError("query")
+	}
+
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = defaultSearchPageSize
+	}
+	pageSize = min(pageSize, maxSearchPageSize)
+
+	// Page tokens are opaque to clients, but are simply the offset of the next page
+	offset := 0
+	if req.GetPageToken() != "" {
+		var err error
+		if offset, err = strconv.Atoi(req.GetPageToken()); err != nil || offset < 0 {
+			return nil, twirp.InvalidArgumentError("page_token", "is invalid")
+		}
+	}
+
+	// Fetch one extra result to know if there is a next page
+	var results []*model.SearchResult
+	var err error
+
+	switch req.GetMode() {
+	case pb.SearchMode_KEYWORD:
+		results, err = s.repo.SearchConversations(ctx, httpx.UserFrom(ctx), req.GetQuery(), offset, pageSize+1)
+	case pb.SearchMode_SEMANTIC:
+		if s.recall == nil {
+			return nil, twirp.NewError(twirp.Unimplemented, "semantic search is not enabled")
+		}
+		results, err = s.semanticSearch(ctx, req.GetQuery(), offset, pageSize+1)
+	default:
+		return nil, twirp.InvalidArgumentError("mode", "must be KEYWORD or SEMANTIC")
+	}
+
+	if err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	resp := &pb.SearchConversationsResponse{}
+	if len(results) > pageSize {
+		results = results[:pageSize]
+		resp.NextPageToken = strconv.Itoa(offset + pageSize)
+	}
+
+	for _, r := range results {
+		r.Conversation.Messages = nil // Matches reference messages by ID, avoid sending large data
+		resp.Results = append(resp.Results, r.Proto())
+	}
+
+	return resp, nil
+}
+
+const (
+	// semanticHitsPerResult is how many message hits are fetched per expected
+	// conversation, as several messages of a conversation often match
+	semanticHitsPerResult = 5
+	maxSemanticHits       = 1000
+
+	semanticSnippetLength = 200
+)
+
+// semanticSearch groups the messages closest in meaning to the query by
+// conversation, ranking conversations by their best message.
+func (s *Server) semanticSearch(ctx context.Context, query string, offset, limit int) ([]*model.SearchResult, error) {
+	hits, err := s.recall.Search(ctx, httpx.UserFrom(ctx), query, min((offset+limit)*semanticHitsPerResult, maxSemanticHits))
+	if err != nil {
+		return nil, err
+	}
+
+	var ranked []primitive.ObjectID
+	groups := map[primitive.ObjectID][]*recall.Hit{}
+	for _, h := range hits {
+		if _, ok := groups[h.ConversationID]; !ok {
+			ranked = append(ranked, h.ConversationID)
+		}
+		groups[h.ConversationID] = append(groups[h.ConversationID], h)
+	}
+
+	if offset >= len(ranked) {
+		return nil, nil
+	}
+	ranked = ranked[offset:min(offset+limit, len(ranked))]
+
+	results := make([]*model.SearchResult, 0, len(ranked))
+	for _, id := range ranked {
+		conversation, err := s.repo.DescribeConversation(ctx, id.Hex())
+		if te, ok := err.(twirp.Error); ok && te.Code() == twirp.NotFound {
+			// Deleted since it was indexed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := &model.SearchResult{Conversation: conversation, Score: groups[id][0].Score}
+		for _, h := range groups[id] {
+			result.Matches = append(result.Matches, &model.SearchMatch{
+				MessageID: h.MessageID,
+				Snippet:   recall.Excerpt(h.Content, semanticSnippetLength),
+			})
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// maxDocumentSize bounds uploads, policies and guides are much smaller.
+const maxDocumentSize = 10 << 20
+
+func (s *Server) UploadDocument(ctx context.Context, req *pb.UploadDocumentRequest) (*pb.UploadDocumentResponse, error) {
+	if s.knowledge == nil {
+		return nil, twirp.NewError(twirp.Unimplemented, "knowledge base is not enabled")
+	}
+
+	if !s.isAdmin(ctx) {
+		return nil, twirp.NewError(twirp.PermissionDenied, "only admins can upload documents")
+	}
+
+	if strings.TrimSpace(req.GetFilename()) == "" {
+		return nil, twirp.RequiredArgumentError("filename")
+	}
+
+	if len(req.GetContent()) == 0 {
+		return nil, twirp.RequiredArgumentError("content")
+	}
+
+	if len(req.GetContent()) > maxDocumentSize {
+		return nil, twirp.InvalidArgumentError("content", fmt.Sprintf("must be at most %d MiB", maxDocumentSize>>20))
+	}
+
+	format, err := documentFormat(req.GetFormat(), req.GetFilename())
+	if err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(req.GetTitle())
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(req.GetFilename()), filepath.Ext(req.GetFilename()))
+	}
+
+	doc, err := s.knowledge.Add(ctx, title, req.GetFilename(), format, req.GetContent())
+	var te twirp.Error
+	if errors.As(err, &te) {
+		return nil, te
+	}
+	if err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	return &pb.UploadDocumentResponse{Document: doc.Proto()}, nil
+}
+
+func (s *Server) ListDocuments(ctx context.Context, req *pb.ListDocumentsRequest) (*pb.ListDocumentsResponse, error) {
+	documents, err := s.repo.ListDocuments(ctx)
+	if err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	resp := &pb.ListDocumentsResponse{}
+	for _, d := range documents {
+		resp.Documents = append(resp.Documents, d.Proto())
+	}
+
+	return resp, nil
+}
+
+func (s *Server) DeleteDocument(ctx context.Context, req *pb.DeleteDocumentRequest) (*pb.DeleteDocumentResponse, error) {
+	if s.knowledge == nil {
+		return nil, twirp.NewError(twirp.Unimplemented, "knowledge base is not enabled")
+	}
+
+	if !s.isAdmin(ctx) {
+		return nil, twirp.NewError(twirp.PermissionDenied, "only admins can delete documents")
+	}
+
+	if req.GetDocumentId() == "" {
+		return nil, twirp.RequiredArgumentError("document_id")
+	}
+
+	if err := s.knowledge.Delete(ctx, req.GetDocumentId()); err != nil {
+		return nil, err
+	}
+
+	return &pb.DeleteDocumentResponse{}, nil
+}
+
+func documentFormat(f pb.Document_Format, filename string) (model.DocumentFormat, error) {
+	switch f {
+	case pb.Document_TEXT:
+		return model.DocumentText, nil
+	case pb.Document_MARKDOWN:
+		return model.DocumentMarkdown, nil
+	case pb.Document_PDF:
+		return model.DocumentPDF, nil
+	}
+
+	if format := model.DocumentFormatFromFilename(filename); format != "" {
+		return format, nil
+	}
+
+	return "", twirp.InvalidArgumentError("format", "must be TEXT, MARKDOWN or PDF, or inferable from a .txt, .md or .pdf filename")
+}
+
+// memoryOwner returns the calling user, the owner of the memories. Anonymous
+// users have no memories, as they can't be told apart.
+func memoryOwner(ctx context.Context) (string, error) {
+	owner := httpx.UserFrom(ctx)
+	if owner == "" {
+		return "", twirp.NewError(twirp.Unauthenticated, "memories require the "+httpx.UserHeader+" header")
+	}
+	return owner, nil
+}
+
+func (s *Server) ListMemories(ctx context.Context, req *pb.ListMemoriesRequest) (*pb.ListMemoriesResponse, error) {
+	owner, err := memoryOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	memories, err := s.repo.ListMemories(ctx, owner)
+	if err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	resp := &pb.ListMemoriesResponse{}
+	for _, m := range memories {
+		resp.Memories = append(resp.Memories, m.Proto())
+	}
+
+	return resp, nil
+}
+
+func (s *Server) UpdateMemory(ctx context.Context, req *pb.UpdateMemoryRequest) (*pb.UpdateMemoryResponse, error) {
+	if req.GetMemoryId() == "" {
+		return nil, twirp.RequiredArgumentError("memory_id")
+	}
+
+	if strings.TrimSpace(req.GetKey()) == "" && strings.TrimSpace(req.GetValue()) == "" {
+		return nil, twirp.InvalidArgumentError("value", "key or value is required")
+	}
+
+	owner, err := memoryOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := s.repo.DescribeMemory(ctx, owner, req.GetMemoryId())
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(req.GetKey()) != "" {
+		if memory.Key = model.MemoryKey(req.GetKey()); memory.Key == "" {
+			return nil, twirp.InvalidArgumentError("key", "must contain letters or digits")
+		}
+		if len(memory.Key) > model.MaxMemoryKeyLength {
+			return nil, twirp.InvalidArgumentError("key", fmt.Sprintf("must be at most %d characters", model.MaxMemoryKeyLength))
+		}
+	}
+
+	if v := strings.TrimSpace(req.GetValue()); v != "" {
+		if len(v) > model.MaxMemoryValueLength {
+			return nil, twirp.InvalidArgumentError("value", fmt.Sprintf("must be at most %d characters", model.MaxMemoryValueLength))
+		}
+		memory.Value = v
+	}
+
+	memory.UpdatedAt = time.Now()
+	if err := s.repo.UpdateMemory(ctx, memory); err != nil {
+		var te twirp.Error
+		if errors.As(err, &te) {
+			return nil, te
+		}
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	return &pb.UpdateMemoryResponse{Memory: memory.Proto()}, nil
+}
+
+func (s *Server) DeleteMemory(ctx context.Context, req *pb.DeleteMemoryRequest) (*pb.DeleteMemoryResponse, error) {
+	if req.GetMemoryId() == "" {
+		return nil, twirp.RequiredArgumentError("memory_id")
+	}
+
+	owner, err := memoryOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.DeleteMemory(ctx, owner, req.GetMemoryId()); err != nil {
+		return nil, err
+	}
+
+	return &pb.DeleteMemoryResponse{}, nil
+}
+
+func (s *Server) ListTools(ctx context.Context, req *pb.ListToolsRequest) (*pb.ListToolsResponse, error) {
+	var disabled []string
+	if req.GetConversationId() != "" {
+		conversation, err := s.repo.DescribeConversation(ctx, req.GetConversationId())
+		if err != nil {
+			return nil, err
+		}
+		disabled = conversation.DisabledTools
+	}
+
+	return &pb.ListToolsResponse{Tools: s.toolsProto(disabled)}, nil
+}
+
+func (s *Server) ConfigureTools(ctx context.Context, req *pb.ConfigureToolsRequest) (*pb.ConfigureToolsResponse, error) {
+	if req.GetConversationId() == "" {
+		return nil, twirp.RequiredArgumentError("conversation_id")
+	}
+
+	enable, err := s.toolNames("enable", req.GetEnable())
+	if err != nil {
+		return nil, err
+	}
+
+	disable, err := s.toolNames("disable", req.GetDisable())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range enable {
+		if slices.Contains(disable, name) {
+			return nil, twirp.InvalidArgumentError("disable", fmt.Sprintf("tool %s can't be both enabled and disabled", name))
+		}
+	}
+
+	conversation, err := s.repo.DescribeConversation(ctx, req.GetConversationId())
+	if err != nil {
+		return nil, err
+	}
+
+	disabled := slices.DeleteFunc(conversation.DisabledTools, func(name string) bool {
+		return slices.Contains(enable, name)
+	})
+	disabled = append(disabled, disable...)
+	slices.Sort(disabled)
+	conversation.DisabledTools = slices.Compact(disabled)
+
+	if err := s.repo.UpdateConversation(ctx, conversation); err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	return &pb.ConfigureToolsResponse{Tools: s.toolsProto(conversation.DisabledTools)}, nil
+}
+
+// toolNames checks that the names in the field are tools of the assistant,
+// and returns them sorted and without duplicates.
+func (s *Server) toolNames(field string, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	tools := s.assist.Tools()
+	out := make([]string, 0, len(names))
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !slices.ContainsFunc(tools, func(t registry.Info) bool { return t.Name == name }) {
+			return nil, twirp.InvalidArgumentError(field, fmt.Sprintf("unknown tool %q, see ListTools", name))
+		}
+		out = append(out, name)
+	}
+
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+func (s *Server) toolsProto(disabled []string) []*pb.Tool {
+	var out []*pb.Tool
+	for _, t := range s.assist.Tools() {
+		out = append(out, &pb.Tool{
+			Name:        t.Name,
+			Description: t.Description,
+			Enabled:     !slices.Contains(disabled, t.Name),
+
+			RequiresConfirmation: t.RequiresConfirmation,
+		})
+	}
+	return out
+}
+
+func (s *Server) PurgeConversations(ctx context.Context, req *pb.PurgeConversationsRequest) (*pb.PurgeConversationsResponse, error) {
+	if s.purger == nil {
+		return nil, twirp.NewError(twirp.Unimplemented, "retention is not enabled")
+	}
+
+	if !s.isAdmin(ctx) {
+		return nil, twirp.NewError(twirp.PermissionDenied, "only admins can purge conversations")
+	}
+
+	result, err := s.purger.Purge(ctx, req.GetDryRun())
+	if err != nil {
+		return nil, twirp.InternalErrorWith(err)
+	}
+
+	return &pb.PurgeConversationsResponse{
+		Conversations: result.Conversations,
+		Itineraries:   result.Itineraries,
+		DryRun:        result.DryRun,
+	}, nil
+}
+
+// isAdmin reports whether the caller is one of the admins. Anonymous callers
+// never are.
+func (s *Server) isAdmin(ctx context.Context) bool {
+	user := httpx.UserFrom(ctx)
+	return user != "" && slices.Contains(s.admins, user)
+}
@@ -3,13 +3,28 @@ package chat
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/acai-travel/tech-challenge/internal/chat/knowledge"
+	"github.com/acai-travel/tech-challenge/internal/chat/llm"
 	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/recall"
+	"github.com/acai-travel/tech-challenge/internal/chat/retention"
 	. "github.com/acai-travel/tech-challenge/internal/chat/testing"
+	"github.com/acai-travel/tech-challenge/internal/httpx"
 	"github.com/acai-travel/tech-challenge/internal/pb"
+	"github.com/acai-travel/tech-challenge/internal/vector"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
 	"github.com/twitchtv/twirp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"google.golang.org/protobuf/testing/protocmp"
 )
 
@@ -178,3 +193,922 @@ func TestServer_StartConversation(t *testing.T) {
 		}
 	}))
 }
+
+func TestServer_GetItinerary(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(model.New(ConnectMongo()), nil)
+
+	t.Run("get itinerary of a conversation", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+		it := f.CreateItinerary(c)
+
+		out, err := srv.GetItinerary(ctx, &pb.GetItineraryRequest{ConversationId: c.ID.Hex()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, want := out.GetItinerary(), it.Proto()
+		if !cmp.Equal(got, want, protocmp.Transform()) {
+			t.Errorf("GetItinerary() mismatch (-got +want):\n%s", cmp.Diff(got, want, protocmp.Transform()))
+		}
+	}))
+
+	t.Run("conversation without itinerary should return 404", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+
+		_, err := srv.GetItinerary(ctx, &pb.GetItineraryRequest{ConversationId: c.ID.Hex()})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.NotFound {
+			t.Fatalf("expected twirp.NotFound error, got %v", err)
+		}
+	}))
+
+	t.Run("list itineraries includes the itinerary", WithFixture(func(t *testing.T, f *Fixture) {
+		it := f.CreateItinerary(f.CreateConversation())
+
+		out, err := srv.ListItineraries(ctx, &pb.ListItinerariesRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, got := range out.GetItineraries() {
+			if got.GetId() == it.ID.Hex() {
+				return
+			}
+		}
+		t.Errorf("itinerary %s not found in ListItineraries()", it.ID.Hex())
+	}))
+}
+
+func TestServer_ExportImportConversations(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(model.New(ConnectMongo()), nil)
+
+	t.Run("export as JSON and reject importing existing conversations", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+
+		out, err := srv.ExportConversations(ctx, &pb.ExportConversationsRequest{
+			ConversationIds: []string{c.ID.Hex()},
+			Format:          pb.ExportFormat_JSON,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !strings.Contains(out.GetContent(), c.ID.Hex()) {
+			t.Errorf("expected export to contain conversation ID %s, got:\n%s", c.ID.Hex(), out.GetContent())
+		}
+
+		_, err = srv.ImportConversations(ctx, &pb.ImportConversationsRequest{
+			Format:  pb.ExportFormat_JSON,
+			Content: out.GetContent(),
+		})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.AlreadyExists {
+			t.Fatalf("expected twirp.AlreadyExists error, got %v", err)
+		}
+	}))
+
+	t.Run("import conversations as the caller's", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner = uuid.New().String()
+		})
+
+		out, err := srv.ExportConversations(httpx.WithUser(ctx, c.Owner), &pb.ExportConversationsRequest{
+			ConversationIds: []string{c.ID.Hex()},
+			Format:          pb.ExportFormat_JSON,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := f.Repository.DeleteConversation(ctx, c.ID.Hex()); err != nil {
+			t.Fatalf("failed to delete conversation: %v", err)
+		}
+
+		owner := uuid.New().String()
+		_, err = srv.ImportConversations(httpx.WithUser(ctx, owner), &pb.ImportConversationsRequest{
+			Format:  pb.ExportFormat_JSON,
+			Content: out.GetContent(),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		saved, err := f.Repository.DescribeConversation(ctx, c.ID.Hex())
+		if err != nil {
+			t.Fatalf("failed to fetch imported conversation: %v", err)
+		}
+		if saved.Owner != owner {
+			t.Errorf("got owner %q, want %q", saved.Owner, owner)
+		}
+	}))
+
+	t.Run("export only the caller's conversations", WithFixture(func(t *testing.T, f *Fixture) {
+		owner := uuid.New().String()
+		mine := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner = owner
+		})
+		other := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner = uuid.New().String()
+		})
+
+		out, err := srv.ExportConversations(httpx.WithUser(ctx, owner), &pb.ExportConversationsRequest{
+			Format: pb.ExportFormat_JSON,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !strings.Contains(out.GetContent(), mine.ID.Hex()) {
+			t.Errorf("expected export to contain conversation ID %s, got:\n%s", mine.ID.Hex(), out.GetContent())
+		}
+		if strings.Contains(out.GetContent(), other.ID.Hex()) {
+			t.Errorf("expected export not to contain conversation ID %s of another owner", other.ID.Hex())
+		}
+
+		_, err = srv.ExportConversations(httpx.WithUser(ctx, owner), &pb.ExportConversationsRequest{
+			ConversationIds: []string{other.ID.Hex()},
+			Format:          pb.ExportFormat_JSON,
+		})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.NotFound {
+			t.Fatalf("expected twirp.NotFound error, got %v", err)
+		}
+
+		_, err = srv.ExportConversations(ctx, &pb.ExportConversationsRequest{Format: pb.ExportFormat_JSON})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.Unauthenticated {
+			t.Fatalf("expected twirp.Unauthenticated error, got %v", err)
+		}
+	}))
+
+	t.Run("import fine-tuning JSONL", WithFixture(func(t *testing.T, f *Fixture) {
+		out, err := srv.ImportConversations(ctx, &pb.ImportConversationsRequest{
+			Format:  pb.ExportFormat_JSONL,
+			Content: `{"messages":[{"role":"system","content":"Be nice"},{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello!"}]}`,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(out.GetConversationIds()) != 1 {
+			t.Fatalf("expected 1 imported conversation, got %d", len(out.GetConversationIds()))
+		}
+
+		saved, err := f.Repository.DescribeConversation(ctx, out.GetConversationIds()[0])
+		if err != nil {
+			t.Fatalf("failed to fetch imported conversation: %v", err)
+		}
+
+		if len(saved.Messages) != 2 || saved.Messages[0].Role != model.RoleUser || saved.Messages[1].Role != model.RoleAssistant {
+			t.Errorf("unexpected imported messages: %+v", saved.Messages)
+		}
+	}))
+
+	t.Run("import rejects invalid roles", WithFixture(func(t *testing.T, f *Fixture) {
+		_, err := srv.ImportConversations(ctx, &pb.ImportConversationsRequest{
+			Format:  pb.ExportFormat_JSONL,
+			Content: `{"messages":[{"role":"robot","content":"Beep"}]}`,
+		})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.InvalidArgument {
+			t.Fatalf("expected twirp.InvalidArgument error, got %v", err)
+		}
+	}))
+}
+
+func TestServer_SearchConversations(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(model.New(ConnectMongo()), nil)
+
+	t.Run("find conversation by message content", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation(func(c *model.Conversation) {
+			c.Messages[0].Content = "Can you recommend a hotel in Zanzibar close to the beach?"
+		})
+
+		out, err := srv.SearchConversations(ctx, &pb.SearchConversationsRequest{Query: "zanzibar hotels", PageSize: 100})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, r := range out.GetResults() {
+			if r.GetConversation().GetId() != c.ID.Hex() {
+				continue
+			}
+
+			if len(r.GetMatches()) != 1 || r.GetMatches()[0].GetMessageId() != c.Messages[0].ID.Hex() {
+				t.Fatalf("expected a single match on the first message, got %v", r.GetMatches())
+			}
+
+			want := "Can you recommend a **hotel** in **Zanzibar** close to the beach?"
+			if got := r.GetMatches()[0].GetSnippet(); got != want {
+				t.Errorf("unexpected snippet %q, want %q", got, want)
+			}
+			return
+		}
+
+		t.Errorf("conversation %s not found in search results", c.ID.Hex())
+	}))
+
+	t.Run("keyword mode only finds the user's conversations", WithFixture(func(t *testing.T, f *Fixture) {
+		owner := uuid.New().String()
+		ctx := httpx.WithUser(ctx, owner)
+
+		mine := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner = owner
+			c.Messages[0].Content = "Which ferry goes from Dar es Salaam to Pemba?"
+		})
+		other := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner = uuid.New().String()
+			c.Messages[0].Content = "Which ferry goes from Dar es Salaam to Pemba?"
+		})
+
+		out, err := srv.SearchConversations(ctx, &pb.SearchConversationsRequest{Query: "pemba ferry", PageSize: 100})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var found bool
+		for _, r := range out.GetResults() {
+			switch r.GetConversation().GetId() {
+			case mine.ID.Hex():
+				found = true
+			case other.ID.Hex():
+				t.Errorf("conversation of another user returned")
+			}
+		}
+		if !found {
+			t.Errorf("conversation %s not found in search results", mine.ID.Hex())
+		}
+	}))
+
+	t.Run("returns error when query is empty", WithFixture(func(t *testing.T, f *Fixture) {
+		_, err := srv.SearchConversations(ctx, &pb.SearchConversationsRequest{Query: " "})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.InvalidArgument {
+			t.Fatalf("expected twirp.InvalidArgument error, got %v", err)
+		}
+	}))
+
+	t.Run("semantic mode requires a recall index", WithFixture(func(t *testing.T, f *Fixture) {
+		_, err := srv.SearchConversations(ctx, &pb.SearchConversationsRequest{Query: "visas", Mode: pb.SearchMode_SEMANTIC})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.Unimplemented {
+			t.Fatalf("expected twirp.Unimplemented error, got %v", err)
+		}
+	}))
+
+	t.Run("semantic mode finds the user's conversations by meaning", WithFixture(func(t *testing.T, f *Fixture) {
+		index := recall.NewIndex(MockEmbedder{}, vector.NewMemoryStore())
+		srv := NewServer(model.New(ConnectMongo()), nil, WithRecall(index))
+
+		owner := uuid.New().String()
+		ctx := httpx.WithUser(ctx, owner)
+
+		visa := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner = owner
+			c.Messages[0].Content = "Do I need a visa to travel to Japan?"
+		})
+		weather := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner = owner
+		})
+		other := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner = uuid.New().String()
+			c.Messages[0].Content = "Do I need a visa to travel to Japan?"
+		})
+
+		if err := index.IndexConversations(ctx, []*model.Conversation{visa, weather, other}); err != nil {
+			t.Fatalf("failed to index conversations: %v", err)
+		}
+
+		out, err := srv.SearchConversations(ctx, &pb.SearchConversationsRequest{Query: "japan visa", Mode: pb.SearchMode_SEMANTIC, PageSize: 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(out.GetResults()) != 1 || out.GetResults()[0].GetConversation().GetId() != visa.ID.Hex() {
+			t.Fatalf("expected conversation %s first, got %v", visa.ID.Hex(), out.GetResults())
+		}
+
+		if got := out.GetResults()[0].GetMatches()[0].GetMessageId(); got != visa.Messages[0].ID.Hex() {
+			t.Errorf("expected match on message %s, got %s", visa.Messages[0].ID.Hex(), got)
+		}
+
+		out, err = srv.SearchConversations(ctx, &pb.SearchConversationsRequest{Query: "japan visa", Mode: pb.SearchMode_SEMANTIC, PageToken: out.GetNextPageToken()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, r := range out.GetResults() {
+			if r.GetConversation().GetId() == other.ID.Hex() {
+				t.Errorf("conversation of another user returned")
+			}
+		}
+	}))
+}
+
+func TestServer_Documents(t *testing.T) {
+	ctx := httpx.WithUser(context.Background(), "admin")
+	repo := model.New(ConnectMongo())
+	kb := knowledge.New(repo, MockEmbedder{}, vector.NewMemoryStore())
+	srv := NewServer(repo, nil, WithKnowledgeBase(kb), WithAdmins("admin"))
+
+	t.Run("upload, search and delete a document", WithFixture(func(t *testing.T, f *Fixture) {
+		out, err := srv.UploadDocument(ctx, &pb.UploadDocumentRequest{
+			Filename: "baggage-policy.md",
+			Content:  []byte("# Cabin baggage\nEmployees may bring one cabin bag of up to 10kg.\n"),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		doc := out.GetDocument()
+		if doc.GetTitle() != "baggage-policy" || doc.GetFormat() != pb.Document_MARKDOWN || doc.GetChunks() != 1 {
+			t.Fatalf("unexpected document: %v", doc)
+		}
+
+		passages, err := kb.Search(ctx, "cabin bag", 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(passages) != 1 || passages[0].Heading != "Cabin baggage" {
+			t.Fatalf("expected the cabin baggage passage, got %v", passages)
+		}
+
+		list, err := srv.ListDocuments(ctx, &pb.ListDocumentsRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !containsDocument(list.GetDocuments(), doc.GetId()) {
+			t.Fatalf("document %s not listed", doc.GetId())
+		}
+
+		if _, err := srv.DeleteDocument(ctx, &pb.DeleteDocumentRequest{DocumentId: doc.GetId()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if passages, _ := kb.Search(ctx, "cabin bag", 5); len(passages) != 0 {
+			t.Errorf("passages of the deleted document are still returned: %v", passages)
+		}
+
+		list, _ = srv.ListDocuments(ctx, &pb.ListDocumentsRequest{})
+		if containsDocument(list.GetDocuments(), doc.GetId()) {
+			t.Errorf("deleted document %s still listed", doc.GetId())
+		}
+	}))
+
+	t.Run("users other than admins are denied", WithFixture(func(t *testing.T, f *Fixture) {
+		for _, ctx := range []context.Context{context.Background(), httpx.WithUser(context.Background(), uuid.New().String())} {
+			_, err := srv.UploadDocument(ctx, &pb.UploadDocumentRequest{Filename: "policy.md", Content: []byte("# Policy")})
+			if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.PermissionDenied {
+				t.Fatalf("expected twirp.PermissionDenied error, got %v", err)
+			}
+
+			_, err = srv.DeleteDocument(ctx, &pb.DeleteDocumentRequest{DocumentId: primitive.NewObjectID().Hex()})
+			if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.PermissionDenied {
+				t.Fatalf("expected twirp.PermissionDenied error, got %v", err)
+			}
+		}
+	}))
+
+	t.Run("reject unsupported formats", WithFixture(func(t *testing.T, f *Fixture) {
+		_, err := srv.UploadDocument(ctx, &pb.UploadDocumentRequest{Filename: "policy.docx", Content: []byte("...")})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.InvalidArgument {
+			t.Fatalf("expected twirp.InvalidArgument error, got %v", err)
+		}
+	}))
+}
+
+func containsDocument(docs []*pb.Document, id string) bool {
+	for _, d := range docs {
+		if d.GetId() == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestServer_Memories(t *testing.T) {
+	srv := NewServer(model.New(ConnectMongo()), nil)
+
+	t.Run("list, update and delete the user's memories", WithFixture(func(t *testing.T, f *Fixture) {
+		owner := uuid.New().String()
+		ctx := httpx.WithUser(context.Background(), owner)
+
+		m := f.CreateMemory(owner)
+		f.CreateMemory(uuid.New().String(), func(m *model.Memory) { m.Key = "home_airport" })
+
+		list, err := srv.ListMemories(ctx, &pb.ListMemoriesRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(list.GetMemories()) != 1 || list.GetMemories()[0].GetId() != m.ID.Hex() {
+			t.Fatalf("expected only memory %s, got %v", m.ID.Hex(), list.GetMemories())
+		}
+
+		out, err := srv.UpdateMemory(ctx, &pb.UpdateMemoryRequest{MemoryId: m.ID.Hex(), Value: "vegan"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.GetMemory().GetKey() != "diet" || out.GetMemory().GetValue() != "vegan" {
+			t.Errorf("unexpected memory after update: %v", out.GetMemory())
+		}
+
+		if _, err := srv.DeleteMemory(ctx, &pb.DeleteMemoryRequest{MemoryId: m.ID.Hex()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		list, _ = srv.ListMemories(ctx, &pb.ListMemoriesRequest{})
+		if len(list.GetMemories()) != 0 {
+			t.Errorf("expected no memories after delete, got %v", list.GetMemories())
+		}
+	}))
+
+	t.Run("anonymous users have no memories", WithFixture(func(t *testing.T, f *Fixture) {
+		m := f.CreateMemory("")
+		ctx := context.Background()
+
+		_, listErr := srv.ListMemories(ctx, &pb.ListMemoriesRequest{})
+		_, updateErr := srv.UpdateMemory(ctx, &pb.UpdateMemoryRequest{MemoryId: m.ID.Hex(), Value: "vegan"})
+		_, deleteErr := srv.DeleteMemory(ctx, &pb.DeleteMemoryRequest{MemoryId: m.ID.Hex()})
+
+		for _, err := range []error{listErr, updateErr, deleteErr} {
+			if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.Unauthenticated {
+				t.Errorf("expected twirp.Unauthenticated error, got %v", err)
+			}
+		}
+	}))
+
+	t.Run("update rejects keys and values over the limits", WithFixture(func(t *testing.T, f *Fixture) {
+		owner := uuid.New().String()
+		ctx := httpx.WithUser(context.Background(), owner)
+		m := f.CreateMemory(owner)
+
+		for _, req := range []*pb.UpdateMemoryRequest{
+			{MemoryId: m.ID.Hex(), Key: strings.Repeat("k", model.MaxMemoryKeyLength+1)},
+			{MemoryId: m.ID.Hex(), Value: strings.Repeat("v", model.MaxMemoryValueLength+1)},
+		} {
+			_, err := srv.UpdateMemory(ctx, req)
+			if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.InvalidArgument {
+				t.Errorf("expected twirp.InvalidArgument error, got %v", err)
+			}
+		}
+	}))
+
+	t.Run("memories of other users are not found", WithFixture(func(t *testing.T, f *Fixture) {
+		m := f.CreateMemory(uuid.New().String())
+		ctx := httpx.WithUser(context.Background(), uuid.New().String())
+
+		_, err := srv.DeleteMemory(ctx, &pb.DeleteMemoryRequest{MemoryId: m.ID.Hex()})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.NotFound {
+			t.Fatalf("expected twirp.NotFound error, got %v", err)
+		}
+	}))
+}
+
+func TestServer_PurgeConversations(t *testing.T) {
+	repo := model.New(ConnectMongo())
+	admin := httpx.WithUser(context.Background(), "admin")
+
+	// Only conversations of the owner expire, so the purges don't delete
+	// the conversations of other tests
+	owner := uuid.New().String()
+	purger := retention.New(repo, retention.Policy{Users: map[string]time.Duration{owner: 30 * 24 * time.Hour}})
+	srv := NewServer(repo, nil, WithRetention(purger), WithAdmins("admin"))
+
+	t.Run("retention not enabled should return unimplemented", WithFixture(func(t *testing.T, f *Fixture) {
+		_, err := NewServer(repo, nil).PurgeConversations(admin, &pb.PurgeConversationsRequest{})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.Unimplemented {
+			t.Fatalf("expected twirp.Unimplemented error, got %v", err)
+		}
+	}))
+
+	t.Run("users other than admins are denied", WithFixture(func(t *testing.T, f *Fixture) {
+		for _, ctx := range []context.Context{context.Background(), httpx.WithUser(context.Background(), owner)} {
+			_, err := srv.PurgeConversations(ctx, &pb.PurgeConversationsRequest{})
+			if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.PermissionDenied {
+				t.Fatalf("expected twirp.PermissionDenied error, got %v", err)
+			}
+		}
+	}))
+
+	t.Run("purges expired conversations with their itineraries", WithFixture(func(t *testing.T, f *Fixture) {
+		stale := f.CreateConversation(func(c *model.Conversation) { c.Owner = owner })
+		f.CreateItinerary(stale)
+		recent := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner, c.UpdatedAt = owner, time.Now()
+		})
+		other := f.CreateConversation(func(c *model.Conversation) { c.Owner = uuid.New().String() })
+
+		out, err := srv.PurgeConversations(admin, &pb.PurgeConversationsRequest{DryRun: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Itineraries left by other tests may be counted as orphans
+		if !out.GetDryRun() || out.GetConversations() != 1 || out.GetItineraries() < 1 {
+			t.Fatalf("expected a dry run counting 1 conversation and its itinerary, got %v", out)
+		}
+		if _, err := repo.DescribeConversation(context.Background(), stale.ID.Hex()); err != nil {
+			t.Fatalf("expected the dry run to keep the conversation, got %v", err)
+		}
+
+		out, err = srv.PurgeConversations(admin, &pb.PurgeConversationsRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.GetDryRun() || out.GetConversations() != 1 || out.GetItineraries() < 1 {
+			t.Fatalf("expected 1 purged conversation and its itinerary, got %v", out)
+		}
+
+		if _, err := srv.DescribeConversation(admin, &pb.DescribeConversationRequest{ConversationId: stale.ID.Hex()}); err == nil {
+			t.Error("expected the expired conversation to be deleted")
+		}
+		if _, err := srv.GetItinerary(admin, &pb.GetItineraryRequest{ConversationId: stale.ID.Hex()}); err == nil {
+			t.Error("expected the itinerary of the expired conversation to be deleted")
+		}
+
+		for _, c := range []*model.Conversation{recent, other} {
+			if _, err := repo.DescribeConversation(context.Background(), c.ID.Hex()); err != nil {
+				t.Errorf("expected conversation %s to be kept, got %v", c.ID.Hex(), err)
+			}
+		}
+	}))
+
+	t.Run("removes deleted conversations from the recall index", WithFixture(func(t *testing.T, f *Fixture) {
+		ctx := context.Background()
+		index := recall.NewIndex(MockEmbedder{}, vector.NewMemoryStore())
+		purger := retention.New(repo, retention.Policy{Users: map[string]time.Duration{owner: 30 * 24 * time.Hour}}, retention.WithRecall(index))
+
+		// The expired conversation was deleted by the TTL index, never stored here
+		kept := f.CreateConversation(func(c *model.Conversation) {
+			c.Owner, c.UpdatedAt = owner, time.Now()
+		})
+		expired := &model.Conversation{ID: primitive.NewObjectID(), Owner: owner, Messages: kept.Messages}
+		if err := index.IndexConversations(ctx, []*model.Conversation{kept, expired}); err != nil {
+			t.Fatalf("failed to index conversations: %v", err)
+		}
+
+		if _, err := purger.Purge(ctx, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ids, err := index.ConversationIDs(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff([]primitive.ObjectID{kept.ID}, ids); diff != "" {
+			t.Errorf("unexpected indexed conversations (-want +got):\n%s", diff)
+		}
+	}))
+}
+
+func TestServer_Tools(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(model.New(ConnectMongo()), &MockAssistant{})
+
+	enabled := func(tools []*pb.Tool) map[string]bool {
+		out := map[string]bool{}
+		for _, t := range tools {
+			out[t.GetName()] = t.GetEnabled()
+		}
+		return out
+	}
+
+	t.Run("disable and enable tools of a conversation", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+
+		out, err := srv.ConfigureTools(ctx, &pb.ConfigureToolsRequest{ConversationId: c.ID.Hex(), Disable: []string{"get_weather"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := map[string]bool{"get_today_date": true, "get_weather": false}
+		if diff := cmp.Diff(want, enabled(out.GetTools())); diff != "" {
+			t.Errorf("unexpected tools (-want +got):\n%s", diff)
+		}
+
+		list, err := srv.ListTools(ctx, &pb.ListToolsRequest{ConversationId: c.ID.Hex()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(want, enabled(list.GetTools())); diff != "" {
+			t.Errorf("unexpected listed tools (-want +got):\n%s", diff)
+		}
+
+		if _, err := srv.ConfigureTools(ctx, &pb.ConfigureToolsRequest{ConversationId: c.ID.Hex(), Enable: []string{"get_weather"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		conv, err := srv.DescribeConversation(ctx, &pb.DescribeConversationRequest{ConversationId: c.ID.Hex()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(conv.GetConversation().GetDisabledTools()) != 0 {
+			t.Errorf("expected no disabled tools, got %v", conv.GetConversation().GetDisabledTools())
+		}
+	}))
+
+	t.Run("start a conversation with disabled tools", WithFixture(func(t *testing.T, f *Fixture) {
+		var disabled []string
+		srv := NewServer(model.New(ConnectMongo()), &MockAssistant{
+			ReplyFunc: func(ctx context.Context, conv *model.Conversation) (string, error) {
+				disabled = conv.DisabledTools
+				return "Hi", nil
+			},
+		})
+
+		out, err := srv.StartConversation(ctx, &pb.StartConversationRequest{Message: "Hello", DisabledTools: []string{"get_weather"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff([]string{"get_weather"}, disabled); diff != "" {
+			t.Errorf("unexpected disabled tools in reply (-want +got):\n%s", diff)
+		}
+
+		saved, err := f.Repository.DescribeConversation(ctx, out.GetConversationId())
+		if err != nil {
+			t.Fatalf("failed to fetch saved conversation: %v", err)
+		}
+		if diff := cmp.Diff([]string{"get_weather"}, saved.DisabledTools); diff != "" {
+			t.Errorf("unexpected saved disabled tools (-want +got):\n%s", diff)
+		}
+	}))
+
+	t.Run("reject unknown tools", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+
+		_, err := srv.ConfigureTools(ctx, &pb.ConfigureToolsRequest{ConversationId: c.ID.Hex(), Disable: []string{"book_flight"}})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.InvalidArgument {
+			t.Fatalf("expected twirp.InvalidArgument error, got %v", err)
+		}
+	}))
+}
+
+func TestServer_Actions(t *testing.T) {
+	ctx := context.Background()
+
+	// The reply pauses for two actions when the user asks to plan the trip
+	srv := NewServer(model.New(ConnectMongo()), &MockAssistant{
+		PauseFunc: func(ctx context.Context, conv *model.Conversation) []*model.PendingAction {
+			if !strings.Contains(conv.Messages[len(conv.Messages)-1].Content, "plan") {
+				return nil
+			}
+
+			var actions []*model.PendingAction
+			for _, place := range []string{"Belem Tower", "Alfama"} {
+				actions = append(actions, &model.PendingAction{
+					ID:        primitive.NewObjectID(),
+					CallID:    "call_" + place,
+					Tool:      "add_itinerary_item",
+					Arguments: `{"date": "2026-05-01", "place": "` + place + `"}`,
+					Status:    model.ActionPending,
+					CreatedAt: time.Now(),
+				})
+			}
+			return actions
+		},
+		ResumeFunc: func(ctx context.Context, conv *model.Conversation) (string, error) {
+			return "Your plan is ready.", nil
+		},
+	})
+
+	t.Run("pause the reply until actions are decided", WithFixture(func(t *testing.T, f *Fixture) {
+		out, err := srv.StartConversation(ctx, &pb.StartConversationRequest{Message: "Please plan my day in Lisbon"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		actions := out.GetPendingActions()
+		if out.GetReply() != "" || len(actions) != 2 || actions[0].GetTool() != "add_itinerary_item" {
+			t.Fatalf("expected a paused reply with 2 actions, got %+v", out)
+		}
+
+		id := out.GetConversationId()
+
+		_, err = srv.ContinueConversation(ctx, &pb.ContinueConversationRequest{ConversationId: id, Message: "Hello?"})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.FailedPrecondition {
+			t.Fatalf("expected twirp.FailedPrecondition error, got %v", err)
+		}
+
+		approved, err := srv.ApproveAction(ctx, &pb.ApproveActionRequest{ConversationId: id, ActionId: actions[0].GetId()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if approved.GetReply() != "" || len(approved.GetPendingActions()) != 1 {
+			t.Fatalf("expected 1 action left, got %+v", approved)
+		}
+
+		_, err = srv.ApproveAction(ctx, &pb.ApproveActionRequest{ConversationId: id, ActionId: actions[0].GetId()})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.FailedPrecondition {
+			t.Fatalf("expected twirp.FailedPrecondition error deciding twice, got %v", err)
+		}
+
+		rejected, err := srv.RejectAction(ctx, &pb.RejectActionRequest{ConversationId: id, ActionId: actions[1].GetId(), Reason: "Been there"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rejected.GetReply() != "Your plan is ready." || len(rejected.GetPendingActions()) != 0 {
+			t.Fatalf("expected the resumed reply, got %+v", rejected)
+		}
+
+		saved, err := f.Repository.DescribeConversation(ctx, id)
+		if err != nil {
+			t.Fatalf("failed to fetch saved conversation: %v", err)
+		}
+		if saved.Pending != nil || len(saved.Messages) != 2 || saved.Messages[1].Content != "Your plan is ready." {
+			t.Errorf("unexpected saved conversation %+v", saved)
+		}
+	}))
+
+	t.Run("keep the pending actions of a continued conversation", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+
+		out, err := srv.ContinueConversation(ctx, &pb.ContinueConversationRequest{ConversationId: c.ID.Hex(), Message: "Let's plan it"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.GetReply() != "" || len(out.GetPendingActions()) != 2 {
+			t.Fatalf("expected a paused reply with 2 actions, got %+v", out)
+		}
+
+		conv, err := srv.DescribeConversation(ctx, &pb.DescribeConversationRequest{ConversationId: c.ID.Hex()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(conv.GetConversation().GetPendingActions()) != 2 {
+			t.Errorf("expected 2 pending actions, got %v", conv.GetConversation().GetPendingActions())
+		}
+	}))
+
+	t.Run("decide an action once under concurrent requests", WithFixture(func(t *testing.T, f *Fixture) {
+		out, err := srv.StartConversation(ctx, &pb.StartConversationRequest{Message: "Please plan my day in Lisbon"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = srv.ApproveAction(ctx, &pb.ApproveActionRequest{ConversationId: out.GetConversationId(), ActionId: out.GetPendingActions()[0].GetId()})
+			}()
+		}
+		wg.Wait()
+
+		decided := 0
+		for _, err := range errs {
+			if err == nil {
+				decided++
+			} else if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.FailedPrecondition {
+				t.Errorf("expected twirp.FailedPrecondition error, got %v", err)
+			}
+		}
+		if decided != 1 {
+			t.Errorf("expected the action decided once, got %d", decided)
+		}
+	}))
+
+	t.Run("retry a failed reply without running the actions again", WithFixture(func(t *testing.T, f *Fixture) {
+		var runs, resumes int
+		srv := NewServer(model.New(ConnectMongo()), &MockAssistant{
+			PauseFunc: func(ctx context.Context, conv *model.Conversation) []*model.PendingAction {
+				return []*model.PendingAction{{ID: primitive.NewObjectID(), CallID: "call_1", Tool: "add_itinerary_item", Status: model.ActionPending}}
+			},
+			RunActionsFunc: func(ctx context.Context, conv *model.Conversation) error {
+				runs++
+				return nil
+			},
+			ResumeFunc: func(ctx context.Context, conv *model.Conversation) (string, error) {
+				if resumes++; resumes == 1 {
+					return "", llm.ErrUnavailable
+				}
+				return "Your plan is ready.", nil
+			},
+		})
+
+		out, err := srv.StartConversation(ctx, &pb.StartConversationRequest{Message: "Please plan my day in Lisbon"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		req := &pb.ApproveActionRequest{ConversationId: out.GetConversationId(), ActionId: out.GetPendingActions()[0].GetId()}
+		if _, err := srv.ApproveAction(ctx, req); err == nil {
+			t.Fatal("expected the first reply to fail")
+		}
+
+		approved, err := srv.ApproveAction(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if approved.GetReply() != "Your plan is ready." {
+			t.Errorf("expected the resumed reply, got %+v", approved)
+		}
+		if runs != 1 {
+			t.Errorf("expected the actions to run once, got %d", runs)
+		}
+	}))
+
+	t.Run("reject unknown actions", WithFixture(func(t *testing.T, f *Fixture) {
+		c := f.CreateConversation()
+
+		_, err := srv.RejectAction(ctx, &pb.RejectActionRequest{ConversationId: c.ID.Hex(), ActionId: primitive.NewObjectID().Hex()})
+		if te, ok := err.(twirp.Error); !ok || te.Code() != twirp.NotFound {
+			t.Fatalf("expected twirp.NotFound error, got %v", err)
+		}
+	}))
+}
+
+func TestServer_ReplyErrors(t *testing.T) {
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		err  error
+		code twirp.ErrorCode
+	}{
+		"rate limited": {fmt.Errorf("%w: 429 Too Many Requests", llm.ErrRateLimited), twirp.ResourceExhausted},
+		"unavailable":  {fmt.Errorf("model gpt-4.1: %w", llm.ErrCircuitOpen), twirp.Unavailable},
+		"deadline":     {context.DeadlineExceeded, twirp.DeadlineExceeded},
+		"internal":     {errors.New("boom"), twirp.Internal},
+	} {
+		t.Run(name, WithFixture(func(t *testing.T, f *Fixture) {
+			srv := NewServer(model.New(ConnectMongo()), &MockAssistant{
+				ReplyFunc: func(ctx context.Context, conv *model.Conversation) (string, error) {
+					return "", tc.err
+				},
+			})
+
+			_, err := srv.StartConversation(ctx, &pb.StartConversationRequest{Message: "Hello"})
+			if te, ok := err.(twirp.Error); !ok || te.Code() != tc.code {
+				t.Fatalf("expected twirp.%s error, got %v", tc.code, err)
+			}
+
+			c := f.CreateConversation()
+			_, err = srv.ContinueConversation(ctx, &pb.ContinueConversationRequest{ConversationId: c.ID.Hex(), Message: "Hello"})
+			if te, ok := err.(twirp.Error); !ok || te.Code() != tc.code {
+				t.Fatalf("expected twirp.%s error, got %v", tc.code, err)
+			}
+		}))
+	}
+}
+
+// collectMetrics returns the sums of counters and the counts of histograms
+// recorded so far, by metric name.
+func collectMetrics(t *testing.T, reader sdkmetric.Reader) map[string]int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	out := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					out[m.Name] += dp.Value
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					out[m.Name] += int64(dp.Count)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestServer_Metrics(t *testing.T) {
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(prev) })
+
+	t.Run("records conversations, messages and title failures", WithFixture(func(t *testing.T, f *Fixture) {
+		srv := NewServer(model.New(ConnectMongo()), &MockAssistant{
+			TitleFunc: func(ctx context.Context, conv *model.Conversation) (string, error) {
+				return "", llm.ErrRateLimited
+			},
+		})
+
+		out, err := srv.StartConversation(ctx, &pb.StartConversationRequest{Message: "Hello"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err = srv.ContinueConversation(ctx, &pb.ContinueConversationRequest{ConversationId: out.GetConversationId(), Message: "And then?"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got := collectMetrics(t, reader)
+		want := map[string]int64{
+			"chat.conversations.started": 1,
+			"chat.messages":              4,
+			"chat.conversation.messages": 2,
+			"chat.title.failures":        1,
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("metrics mismatch (-want +got):\n%s", diff)
+		}
+	}))
+}
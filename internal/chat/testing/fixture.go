@@ -7,6 +7,7 @@ import (
 
 	"github.com/acai-travel/tech-challenge/internal/chat/model"
 	"github.com/google/uuid"
+	"github.com/twitchtv/twirp"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -50,7 +51,8 @@ func (f *Fixture) CreateConversation(mods ...func(*model.Conversation)) *model.C
 	}
 
 	f.defers = append(f.defers, func() {
-		if err := f.Repository.DeleteConversation(ctx, c.ID.Hex()); err != nil {
+		err := f.Repository.DeleteConversation(ctx, c.ID.Hex())
+		if te, ok := err.(twirp.Error); err != nil && !(ok && te.Code() == twirp.NotFound) {
 			f.test.Logf("failed to cleanup conversation %s: %v", c.ID.Hex(), err)
 		}
 	})
@@ -58,6 +60,67 @@ func (f *Fixture) CreateConversation(mods ...func(*model.Conversation)) *model.C
 	return c
 }
 
+func (f *Fixture) CreateItinerary(conv *model.Conversation, mods ...func(*model.Itinerary)) *model.Itinerary {
+	it := model.NewItinerary(conv.ID)
+	it.Title = "Weekend in Lisbon"
+	it.CreatedAt = time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	it.UpdatedAt = time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	it.AddItem("2023-10-14", &model.ItineraryItem{
+		ID:    primitive.NewObjectID(),
+		Time:  "10:00",
+		Place: "Belem Tower",
+		Notes: "Buy tickets online",
+	})
+
+	for _, mod := range mods {
+		mod(it)
+	}
+
+	ctx := context.Background()
+
+	if err := f.Repository.SaveItinerary(ctx, it); err != nil {
+		f.test.Fatalf("failed to create itinerary: %v", err)
+	}
+
+	f.defers = append(f.defers, func() {
+		if err := f.Repository.DeleteItinerary(ctx, conv.ID.Hex()); err != nil {
+			f.test.Logf("failed to cleanup itinerary %s: %v", it.ID.Hex(), err)
+		}
+	})
+
+	return it
+}
+
+func (f *Fixture) CreateMemory(owner string, mods ...func(*model.Memory)) *model.Memory {
+	m := &model.Memory{
+		ID:        primitive.NewObjectID(),
+		Owner:     owner,
+		Key:       "diet",
+		Value:     "vegetarian",
+		CreatedAt: time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for _, mod := range mods {
+		mod(m)
+	}
+
+	ctx := context.Background()
+
+	if err := f.Repository.SaveMemory(ctx, m); err != nil {
+		f.test.Fatalf("failed to create memory: %v", err)
+	}
+
+	f.defers = append(f.defers, func() {
+		err := f.Repository.DeleteMemory(ctx, m.Owner, m.ID.Hex())
+		if te, ok := err.(twirp.Error); err != nil && !(ok && te.Code() == twirp.NotFound) {
+			f.test.Logf("failed to cleanup memory %s: %v", m.ID.Hex(), err)
+		}
+	})
+
+	return m
+}
+
 func (f *Fixture) Teardown() {
 	for _, d := range f.defers {
 		d()
@@ -2,14 +2,34 @@ package testing
 
 import (
 	"context"
+	"time"
 
 	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // MockAssistant is a test double for the Assistant interface
 type MockAssistant struct {
 	TitleFunc func(ctx context.Context, conv *model.Conversation) (string, error)
 	ReplyFunc func(ctx context.Context, conv *model.Conversation) (string, error)
+	ToolsFunc func() []registry.Info
+
+	// PauseFunc pauses the reply for confirmation when it returns actions
+	PauseFunc  func(ctx context.Context, conv *model.Conversation) []*model.PendingAction
+	ResumeFunc func(ctx context.Context, conv *model.Conversation) (string, error)
+	// RunActionsFunc runs the decided actions of conv.Pending
+	RunActionsFunc func(ctx context.Context, conv *model.Conversation) error
+}
+
+func (m *MockAssistant) Tools() []registry.Info {
+	if m.ToolsFunc != nil {
+		return m.ToolsFunc()
+	}
+	return []registry.Info{
+		{Name: "get_today_date", Description: "Mock date tool"},
+		{Name: "get_weather", Description: "Mock weather tool"},
+	}
 }
 
 func (m *MockAssistant) Title(ctx context.Context, conv *model.Conversation) (string, error) {
@@ -19,9 +39,47 @@ func (m *MockAssistant) Title(ctx context.Context, conv *model.Conversation) (st
 	return "Mock Title", nil
 }
 
-func (m *MockAssistant) Reply(ctx context.Context, conv *model.Conversation) (string, error) {
-	if m.ReplyFunc != nil {
-		return m.ReplyFunc(ctx, conv)
+func (m *MockAssistant) Reply(ctx context.Context, conv *model.Conversation) (*model.Message, error) {
+	if m.PauseFunc != nil {
+		if actions := m.PauseFunc(ctx, conv); len(actions) > 0 {
+			conv.Pending = &model.PendingReply{Actions: actions, CreatedAt: time.Now()}
+			return nil, nil
+		}
+	}
+
+	return mockReply(ctx, conv, m.ReplyFunc)
+}
+
+func (m *MockAssistant) RunActions(ctx context.Context, conv *model.Conversation) error {
+	if m.RunActionsFunc != nil {
+		if err := m.RunActionsFunc(ctx, conv); err != nil {
+			return err
+		}
+	}
+
+	conv.Pending.Ran = true
+	return nil
+}
+
+func (m *MockAssistant) Resume(ctx context.Context, conv *model.Conversation) (*model.Message, error) {
+	conv.Pending = nil
+	return mockReply(ctx, conv, m.ResumeFunc)
+}
+
+func mockReply(ctx context.Context, conv *model.Conversation, fn func(ctx context.Context, conv *model.Conversation) (string, error)) (*model.Message, error) {
+	reply := "Mock Reply"
+	if fn != nil {
+		var err error
+		if reply, err = fn(ctx, conv); err != nil {
+			return nil, err
+		}
 	}
-	return "Mock Reply", nil
+
+	return &model.Message{
+		ID:        primitive.NewObjectID(),
+		Role:      model.RoleAssistant,
+		Content:   reply,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
 }
@@ -0,0 +1,36 @@
+package testing
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const mockEmbeddingSize = 256
+
+// MockEmbedder is a test double for the embedding.Embedder interface. It
+// hashes words into a fixed number of dimensions, so texts sharing words are
+// similar, which is enough to test retrieval without calling a model.
+type MockEmbedder struct{}
+
+func (MockEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+
+	for i, text := range texts {
+		v := make([]float32, mockEmbeddingSize)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		for _, w := range words {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(w))
+			v[h.Sum32()%mockEmbeddingSize]++
+		}
+
+		vectors[i] = v
+	}
+
+	return vectors, nil
+}
@@ -6,8 +6,9 @@ import (
 	"os"
 	"sync"
 
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/mongox"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var db *mongo.Database
@@ -25,16 +26,15 @@ func ConnectMongo() *mongo.Database {
 			dbname = "acai_testing"
 		}
 
-		client, err := mongo.Connect(context.Background(), options.Client().
-			ApplyURI(uri).
-			SetServerAPIOptions(options.ServerAPI(options.ServerAPIVersion1)).
-			SetBSONOptions(&options.BSONOptions{NilSliceAsEmpty: true}))
-
+		var err error
+		db, err = mongox.Connect(context.Background(), uri, dbname, mongox.WithAttempts(1))
 		if err != nil {
 			panic(fmt.Errorf("failed to connect to MongoDB: %v", err))
 		}
 
-		db = client.Database(dbname)
+		if err := model.New(db).EnsureIndexes(context.Background()); err != nil {
+			panic(fmt.Errorf("failed to create MongoDB indexes: %v", err))
+		}
 	})
 
 	return db
@@ -0,0 +1,93 @@
+package tool
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+)
+
+// Citations collects the passages retrieved while generating a reply. Each
+// passage gets a marker number the reply refers to it with, e.g. [1].
+type Citations struct {
+	mu    sync.Mutex
+	items []*model.Citation
+	texts map[string]int
+}
+
+// NewCitations returns a collector holding citations already collected, e.g.
+// by a reply paused for confirmation. New passages get the next markers.
+func NewCitations(items ...*model.Citation) *Citations {
+	return &Citations{items: items}
+}
+
+// Items returns all the collected citations, in marker order.
+func (c *Citations) Items() []*model.Citation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.items)
+}
+
+type citationsKey struct{}
+
+// WithCitations returns a context carrying the citations collector of the
+// reply being generated.
+func WithCitations(ctx context.Context, c *Citations) context.Context {
+	return context.WithValue(ctx, citationsKey{}, c)
+}
+
+// CitationsFrom returns the citations collector stored in the context, if any.
+func CitationsFrom(ctx context.Context) (*Citations, bool) {
+	c, ok := ctx.Value(citationsKey{}).(*Citations)
+	return c, ok && c != nil
+}
+
+// Add registers the citation and returns its marker. A passage retrieved
+// twice keeps its first marker.
+func (c *Citations) Add(citation *model.Citation, text string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := citation.DocumentID.Hex() + "\x00" + text
+	if marker, ok := c.texts[key]; ok {
+		return marker
+	}
+
+	if c.texts == nil {
+		c.texts = map[string]int{}
+	}
+
+	citation.Marker = len(c.items) + 1
+	c.items = append(c.items, citation)
+	c.texts[key] = citation.Marker
+
+	return citation.Marker
+}
+
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// Referenced returns the citations whose marker appears in the text, in
+// marker order. Passages retrieved but not used in the reply are left out.
+func (c *Citations) Referenced(text string) []*model.Citation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	used := map[int]bool{}
+	for _, m := range citationMarker.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.Atoi(m[1])
+		used[n] = true
+	}
+
+	var out []*model.Citation
+	for _, item := range c.items {
+		if used[item.Marker] {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,21 @@
+package tool
+
+import (
+	"context"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+)
+
+type conversationKey struct{}
+
+// WithConversation returns a context carrying the conversation the tools are
+// executed for, so tools can scope their data to it.
+func WithConversation(ctx context.Context, conv *model.Conversation) context.Context {
+	return context.WithValue(ctx, conversationKey{}, conv)
+}
+
+// ConversationFrom returns the conversation stored in the context, if any.
+func ConversationFrom(ctx context.Context) (*model.Conversation, bool) {
+	conv, ok := ctx.Value(conversationKey{}).(*model.Conversation)
+	return conv, ok && conv != nil
+}
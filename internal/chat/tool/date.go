@@ -3,8 +3,6 @@ package tool
 import (
 	"context"
 	"time"
-
-	"github.com/openai/openai-go/v2"
 )
 
 // DateTool provides current date and time information
@@ -22,13 +20,13 @@ func (t *DateTool) Description() string {
 	return "Get today's date and time in RFC3339 format"
 }
 
-func (t *DateTool) Parameters() openai.FunctionParameters {
-	return openai.FunctionParameters{
-		"type":       "object",
-		"properties": map[string]any{},
-	}
+// CacheTTL opts out of caching, the time changes with every call.
+func (t *DateTool) CacheTTL() time.Duration {
+	return 0
 }
 
-func (t *DateTool) Execute(ctx context.Context, arguments string) (string, error) {
+type DateArgs struct{}
+
+func (t *DateTool) Call(ctx context.Context, args DateArgs) (string, error) {
 	return time.Now().Format(time.RFC3339), nil
 }
@@ -0,0 +1,167 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const earthRadiusKm = 6371.0
+
+// Speeds holds the average door-to-door speeds, in km/h, used to estimate
+// travel times.
+type Speeds struct {
+	Flight float64
+	Train  float64
+	Car    float64
+}
+
+// DefaultSpeeds are conservative averages for European travel.
+var DefaultSpeeds = Speeds{
+	Flight: 750,
+	Train:  140,
+	Car:    90,
+}
+
+const (
+	// flightOverhead accounts for taxiing, take-off, climb and approach.
+	flightOverhead = 30 * time.Minute
+
+	// groundDetourFactor approximates how much longer roads and railways are
+	// compared to the great-circle distance.
+	groundDetourFactor = 1.25
+)
+
+// DistanceTool computes great-circle distances between places and estimates
+// travel times by plane, train and car
+type DistanceTool struct {
+	gazetteer *Gazetteer
+	speeds    Speeds
+}
+
+// NewDistanceTool returns a tool estimating travel times with the speeds,
+// e.g. DefaultSpeeds.
+func NewDistanceTool(speeds Speeds) *DistanceTool {
+	return &DistanceTool{gazetteer: DefaultGazetteer(), speeds: speeds}
+}
+
+func (t *DistanceTool) Name() string {
+	return "get_distance"
+}
+
+func (t *DistanceTool) Description() string {
+	return "Computes the great-circle distance between two places and estimates travel time by flight, train and car. " +
+		"Places can be city names, airport codes or coordinates in the format 'latitude,longitude'."
+}
+
+// CacheTTL keeps distances for a day, they only depend on the gazetteer.
+func (t *DistanceTool) CacheTTL() time.Duration {
+	return 24 * time.Hour
+}
+
+type DistanceArgs struct {
+	Origin      string `json:"origin" description:"Origin city name, airport code or 'latitude,longitude', e.g. 'Madrid', 'BCN' or '41.38,2.17'"`
+	Destination string `json:"destination" description:"Destination city name, airport code or 'latitude,longitude'"`
+}
+
+func (t *DistanceTool) Call(ctx context.Context, args DistanceArgs) (string, error) {
+	from, err := t.resolve(args.Origin)
+	if err != nil {
+		return "", fmt.Errorf("invalid origin: %w", err)
+	}
+
+	to, err := t.resolve(args.Destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid destination: %w", err)
+	}
+
+	km := Haversine(from, to)
+	ground := km * groundDetourFactor
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Great-circle distance from %s to %s: %.0f km\n", from, to, km)
+	fmt.Fprintf(&sb, "Estimated travel time:\n")
+	fmt.Fprintf(&sb, "- Flight: %s (at %.0f km/h, including %s for take-off and landing)\n",
+		formatDuration(travelTime(km, t.speeds.Flight)+flightOverhead), t.speeds.Flight, formatDuration(flightOverhead))
+	fmt.Fprintf(&sb, "- Train: %s (about %.0f km of track at %.0f km/h)\n",
+		formatDuration(travelTime(ground, t.speeds.Train)), ground, t.speeds.Train)
+	fmt.Fprintf(&sb, "- Car: %s (about %.0f km of road at %.0f km/h)",
+		formatDuration(travelTime(ground, t.speeds.Car)), ground, t.speeds.Car)
+
+	return sb.String(), nil
+}
+
+// resolve accepts either a "lat,lon" pair or a name known to the gazetteer.
+func (t *DistanceTool) resolve(s string) (Place, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Place{}, fmt.Errorf("place is required")
+	}
+
+	if lat, lon, ok := parseCoordinates(s); ok {
+		return Place{Name: fmt.Sprintf("%.4f,%.4f", lat, lon), Latitude: lat, Longitude: lon}, nil
+	}
+
+	p, ok := t.gazetteer.Lookup(s)
+	if !ok {
+		return Place{}, fmt.Errorf("unknown place %q, provide coordinates as 'latitude,longitude' instead", s)
+	}
+
+	return p, nil
+}
+
+func parseCoordinates(s string) (float64, float64, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+
+	return lat, lon, true
+}
+
+// Haversine returns the great-circle distance between two places in kilometers.
+func Haversine(a, b Place) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func travelTime(km, speed float64) time.Duration {
+	return time.Duration(km / speed * float64(time.Hour))
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(5 * time.Minute)
+	h, m := int(d.Hours()), int(d.Minutes())%60
+
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	default:
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+}
@@ -0,0 +1,81 @@
+package tool
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHaversine(t *testing.T) {
+	g := DefaultGazetteer()
+
+	tests := []struct {
+		from, to string
+		wantKm   float64
+	}{
+		{"Madrid", "Barcelona", 505},
+		{"London", "Paris", 344},
+		{"New York", "Los Angeles", 3936},
+		{"BCN", "barcelona, spain", 0},
+	}
+
+	for _, tt := range tests {
+		from, ok := g.Lookup(tt.from)
+		if !ok {
+			t.Fatalf("place %q not found", tt.from)
+		}
+		to, ok := g.Lookup(tt.to)
+		if !ok {
+			t.Fatalf("place %q not found", tt.to)
+		}
+
+		if got := Haversine(from, to); math.Abs(got-tt.wantKm) > 5 {
+			t.Errorf("Haversine(%s, %s) = %.1f km, want ~%.0f km", tt.from, tt.to, got, tt.wantKm)
+		}
+	}
+}
+
+func TestDistanceTool_Call(t *testing.T) {
+	tool := &DistanceTool{gazetteer: DefaultGazetteer(), speeds: Speeds{Flight: 800, Train: 250, Car: 100}}
+
+	t.Run("resolves names and coordinates", func(t *testing.T) {
+		out, err := tool.Call(context.Background(), DistanceArgs{Origin: "Málaga", Destination: "40.4168, -3.7038"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, want := range []string{"Malaga, Spain", "Flight:", "Train:", "Car:"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected output to contain %q, got:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("rejects unknown places", func(t *testing.T) {
+		_, err := tool.Call(context.Background(), DistanceArgs{Origin: "Atlantis", Destination: "Madrid"})
+		if err == nil {
+			t.Fatal("expected error for unknown place, got nil")
+		}
+	})
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[string]string{
+		"40m":    "40m",
+		"1h":     "1h",
+		"2h32m":  "2h 30m",
+		"26h10m": "26h 10m",
+	}
+
+	for in, want := range tests {
+		d, err := time.ParseDuration(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%s) = %q, want %q", in, got, want)
+		}
+	}
+}
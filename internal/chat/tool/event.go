@@ -0,0 +1,97 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const eventTimeLayout = "2006-01-02T15:04"
+
+// ProposeEventTool records calendar events suggested to the user, so they can
+// be exported as an iCalendar file. Events are stored on the conversation and
+// persisted together with the reply.
+type ProposeEventTool struct {
+	mu sync.Mutex
+}
+
+func NewProposeEventTool() *ProposeEventTool {
+	return &ProposeEventTool{}
+}
+
+func (t *ProposeEventTool) Name() string {
+	return "propose_calendar_event"
+}
+
+func (t *ProposeEventTool) Description() string {
+	return "Proposes a calendar event (flight, check-in, meeting, reservation) that the user can later export to their calendar. " +
+		"Use it for events with a precise start time that are not part of the itinerary."
+}
+
+// CacheTTL opts out of caching, events are stored on the conversation.
+func (t *ProposeEventTool) CacheTTL() time.Duration {
+	return 0
+}
+
+type ProposeEventArgs struct {
+	Title       string `json:"title" description:"Short title of the event, e.g. 'Flight VY1234 BCN-LIS'"`
+	Start       string `json:"start" description:"Local start time in YYYY-MM-DDTHH:MM format"`
+	End         string `json:"end,omitempty" description:"Optional local end time in YYYY-MM-DDTHH:MM format. Defaults to one hour after start."`
+	TimeZone    string `json:"time_zone" description:"IANA time zone of the start and end times, e.g. 'Europe/Madrid'"`
+	Location    string `json:"location,omitempty" description:"Optional location of the event"`
+	Description string `json:"description,omitempty" description:"Optional details, e.g. booking references"`
+}
+
+func (t *ProposeEventTool) Call(ctx context.Context, args ProposeEventArgs) (string, error) {
+	conv, ok := ConversationFrom(ctx)
+	if !ok {
+		return "", errors.New("proposing events requires a conversation")
+	}
+
+	if strings.TrimSpace(args.Title) == "" {
+		return "", errors.New("title is required")
+	}
+
+	loc, err := time.LoadLocation(args.TimeZone)
+	if err != nil {
+		return "", fmt.Errorf("invalid time_zone %q, expected an IANA time zone like Europe/Madrid", args.TimeZone)
+	}
+
+	start, err := time.ParseInLocation(eventTimeLayout, args.Start, loc)
+	if err != nil {
+		return "", fmt.Errorf("invalid start %q, expected YYYY-MM-DDTHH:MM", args.Start)
+	}
+
+	end := start.Add(time.Hour)
+	if args.End != "" {
+		if end, err = time.ParseInLocation(eventTimeLayout, args.End, loc); err != nil {
+			return "", fmt.Errorf("invalid end %q, expected YYYY-MM-DDTHH:MM", args.End)
+		}
+		if !end.After(start) {
+			return "", errors.New("end must be after start")
+		}
+	}
+
+	event := &model.Event{
+		ID:          primitive.NewObjectID(),
+		Title:       args.Title,
+		Start:       start,
+		End:         end,
+		TimeZone:    args.TimeZone,
+		Location:    args.Location,
+		Description: args.Description,
+	}
+
+	t.mu.Lock()
+	conv.Events = append(conv.Events, event)
+	t.mu.Unlock()
+
+	return fmt.Sprintf("Event %q proposed from %s to %s (%s). The user can export it to their calendar.",
+		event.Title, start.Format(time.DateTime), end.Format(time.DateTime), args.TimeZone), nil
+}
@@ -0,0 +1,111 @@
+package tool
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed gazetteer.csv
+var gazetteerCSV string
+
+// Place is a named location with its coordinates in decimal degrees.
+type Place struct {
+	Name      string
+	Country   string
+	Latitude  float64
+	Longitude float64
+}
+
+func (p Place) String() string {
+	if p.Country == "" {
+		return p.Name
+	}
+	return p.Name + ", " + p.Country
+}
+
+// Gazetteer resolves place names (and airport codes) to coordinates.
+type Gazetteer struct {
+	places map[string]Place
+}
+
+var (
+	defaultGazetteer     *Gazetteer
+	defaultGazetteerOnce sync.Once
+)
+
+// DefaultGazetteer returns the gazetteer embedded in the binary.
+func DefaultGazetteer() *Gazetteer {
+	defaultGazetteerOnce.Do(func() {
+		g, err := ParseGazetteer(gazetteerCSV)
+		if err != nil {
+			panic(fmt.Errorf("embedded gazetteer is invalid: %w", err))
+		}
+		defaultGazetteer = g
+	})
+	return defaultGazetteer
+}
+
+// ParseGazetteer parses a CSV document with the columns name, country,
+// latitude, longitude and aliases (separated by '|'). The first line is a header.
+func ParseGazetteer(data string) (*Gazetteer, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Gazetteer{places: map[string]Place{}}
+	for i, rec := range records {
+		if i == 0 {
+			continue
+		}
+		if len(rec) < 4 {
+			return nil, fmt.Errorf("line %d: expected at least 4 columns, got %d", i+1, len(rec))
+		}
+
+		lat, err := strconv.ParseFloat(rec[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid latitude: %w", i+1, err)
+		}
+		lon, err := strconv.ParseFloat(rec[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid longitude: %w", i+1, err)
+		}
+
+		p := Place{Name: rec[0], Country: rec[1], Latitude: lat, Longitude: lon}
+		g.places[normalizePlace(p.Name)] = p
+		g.places[normalizePlace(p.String())] = p
+		if len(rec) > 4 && rec[4] != "" {
+			for _, alias := range strings.Split(rec[4], "|") {
+				g.places[normalizePlace(alias)] = p
+			}
+		}
+	}
+
+	return g, nil
+}
+
+// Lookup finds a place by name, "name, country" or alias. The match is case
+// and accent insensitive.
+func (g *Gazetteer) Lookup(name string) (Place, bool) {
+	p, ok := g.places[normalizePlace(name)]
+	return p, ok
+}
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c", "ø", "o", "å", "a", "ł", "l",
+)
+
+func normalizePlace(s string) string {
+	s = accentReplacer.Replace(strings.ToLower(s))
+	s = strings.ReplaceAll(s, ",", ", ")
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -2,13 +2,9 @@ package tool
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 	"time"
-
-	"github.com/openai/openai-go/v2"
 )
 
 // HolidaysTool provides information about local bank and public holidays
@@ -16,12 +12,13 @@ type HolidaysTool struct {
 	calendarURL string
 }
 
-func NewHolidaysTool() *HolidaysTool {
-	url := "https://www.officeholidays.com/ics/spain/catalonia"
-	if v := os.Getenv("HOLIDAY_CALENDAR_LINK"); v != "" {
-		url = v
-	}
-	return &HolidaysTool{calendarURL: url}
+// DefaultHolidayCalendar is the iCalendar of the holidays of Catalonia.
+const DefaultHolidayCalendar = "https://www.officeholidays.com/ics/spain/catalonia"
+
+// NewHolidaysTool returns a tool listing the holidays of the iCalendar at
+// calendarURL, e.g. DefaultHolidayCalendar.
+func NewHolidaysTool(calendarURL string) *HolidaysTool {
+	return &HolidaysTool{calendarURL: calendarURL}
 }
 
 func (t *HolidaysTool) Name() string {
@@ -32,37 +29,18 @@ func (t *HolidaysTool) Description() string {
 	return "Gets local bank and public holidays. Each line is a single holiday in the format 'YYYY-MM-DD: Holiday Name'."
 }
 
-func (t *HolidaysTool) Parameters() openai.FunctionParameters {
-	return openai.FunctionParameters{
-		"type": "object",
-		"properties": map[string]any{
-			"before_date": map[string]string{
-				"type":        "string",
-				"description": "Optional date in RFC3339 format to get holidays before this date. If not provided, all holidays will be returned.",
-			},
-			"after_date": map[string]string{
-				"type":        "string",
-				"description": "Optional date in RFC3339 format to get holidays after this date. If not provided, all holidays will be returned.",
-			},
-			"max_count": map[string]string{
-				"type":        "integer",
-				"description": "Optional maximum number of holidays to return. If not provided, all holidays will be returned.",
-			},
-		},
-	}
+// CacheTTL keeps holidays for a day, they rarely change.
+func (t *HolidaysTool) CacheTTL() time.Duration {
+	return 24 * time.Hour
 }
 
-func (t *HolidaysTool) Execute(ctx context.Context, arguments string) (string, error) {
-	var args struct {
-		BeforeDate string `json:"before_date,omitempty"`
-		AfterDate  string `json:"after_date,omitempty"`
-		MaxCount   int    `json:"max_count,omitempty"`
-	}
-
-	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-		return "", fmt.Errorf("invalid arguments: %w", err)
-	}
+type HolidaysArgs struct {
+	BeforeDate string `json:"before_date,omitempty" description:"Optional date in RFC3339 format to get holidays before this date. If not provided, all holidays will be returned."`
+	AfterDate  string `json:"after_date,omitempty" description:"Optional date in RFC3339 format to get holidays after this date. If not provided, all holidays will be returned."`
+	MaxCount   int    `json:"max_count,omitempty" description:"Optional maximum number of holidays to return. If not provided, all holidays will be returned." minimum:"1"`
+}
 
+func (t *HolidaysTool) Call(ctx context.Context, args HolidaysArgs) (string, error) {
 	// Parse dates if provided
 	var beforeDate, afterDate time.Time
 	var err error
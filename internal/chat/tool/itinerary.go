@@ -0,0 +1,281 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/twitchtv/twirp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ItineraryStore interface {
+	DescribeItinerary(ctx context.Context, conversationID string) (*model.Itinerary, error)
+	SaveItinerary(ctx context.Context, it *model.Itinerary) error
+}
+
+// itineraries serializes read-modify-write cycles on itineraries shared by
+// the itinerary tools.
+type itineraries struct {
+	store ItineraryStore
+	mu    sync.Mutex
+}
+
+// update loads the itinerary of the conversation in the context (creating an
+// empty one if needed), applies fn and saves the result.
+func (s *itineraries) update(ctx context.Context, fn func(it *model.Itinerary) error) (*model.Itinerary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn(it); err != nil {
+		return nil, err
+	}
+
+	it.UpdatedAt = time.Now()
+	if err := s.store.SaveItinerary(ctx, it); err != nil {
+		return nil, fmt.Errorf("failed to save itinerary: %w", err)
+	}
+
+	return it, nil
+}
+
+func (s *itineraries) load(ctx context.Context) (*model.Itinerary, error) {
+	conv, ok := ConversationFrom(ctx)
+	if !ok {
+		return nil, errors.New("itinerary tools require a conversation")
+	}
+
+	it, err := s.store.DescribeItinerary(ctx, conv.ID.Hex())
+	if te, ok := err.(twirp.Error); ok && te.Code() == twirp.NotFound {
+		return model.NewItinerary(conv.ID), nil
+	}
+
+	return it, err
+}
+
+// NewItineraryTools returns the tools to add, update and list the items of
+// the itinerary attached to the current conversation.
+func NewItineraryTools(store ItineraryStore) (*AddItineraryItemTool, *UpdateItineraryItemTool, *ListItineraryTool) {
+	s := &itineraries{store: store}
+	return &AddItineraryItemTool{s}, &UpdateItineraryItemTool{s}, &ListItineraryTool{s}
+}
+
+// AddItineraryItemTool adds an activity to the trip plan
+type AddItineraryItemTool struct {
+	itineraries *itineraries
+}
+
+func (t *AddItineraryItemTool) Name() string {
+	return "add_itinerary_item"
+}
+
+func (t *AddItineraryItemTool) Description() string {
+	return "Adds an item (activity, visit, transfer, reservation) to the user's trip itinerary for this conversation. " +
+		"Use it whenever a plan is agreed with the user. Returns the updated itinerary."
+}
+
+// RequiresConfirmation is set as the item changes the plan of the user.
+func (t *AddItineraryItemTool) RequiresConfirmation() bool {
+	return true
+}
+
+type AddItineraryItemArgs struct {
+	Date      string `json:"date" description:"Date of the item in YYYY-MM-DD format"`
+	Time      string `json:"time,omitempty" description:"Optional local time of the item in HH:MM (24h) format"`
+	Place     string `json:"place" description:"Place or short description of the activity, e.g. 'Sagrada Familia'"`
+	Notes     string `json:"notes,omitempty" description:"Optional notes, e.g. booking references or tips"`
+	TripTitle string `json:"trip_title,omitempty" description:"Optional title for the whole trip, e.g. 'Weekend in Lisbon'"`
+	TimeZone  string `json:"time_zone,omitempty" description:"Optional IANA time zone of the trip dates and times, e.g. 'Europe/Lisbon'"`
+}
+
+func (t *AddItineraryItemTool) Call(ctx context.Context, args AddItineraryItemArgs) (string, error) {
+	if err := validateItineraryItem(args.Date, args.Time, args.Place); err != nil {
+		return "", err
+	}
+
+	if args.TimeZone != "" {
+		if _, err := time.LoadLocation(args.TimeZone); err != nil {
+			return "", fmt.Errorf("invalid time_zone %q, expected an IANA time zone like Europe/Lisbon", args.TimeZone)
+		}
+	}
+
+	it, err := t.itineraries.update(ctx, func(it *model.Itinerary) error {
+		if args.TripTitle != "" {
+			it.Title = args.TripTitle
+		}
+		if args.TimeZone != "" {
+			it.TimeZone = args.TimeZone
+		}
+
+		it.AddItem(args.Date, &model.ItineraryItem{
+			ID:    primitive.NewObjectID(),
+			Time:  args.Time,
+			Place: args.Place,
+			Notes: args.Notes,
+		})
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return formatItinerary(it), nil
+}
+
+// UpdateItineraryItemTool changes an existing item of the trip plan
+type UpdateItineraryItemTool struct {
+	itineraries *itineraries
+}
+
+func (t *UpdateItineraryItemTool) Name() string {
+	return "update_itinerary_item"
+}
+
+func (t *UpdateItineraryItemTool) Description() string {
+	return "Updates an item of the user's trip itinerary. Only the provided fields are changed. " +
+		"Use list_itinerary to find item IDs. Returns the updated itinerary."
+}
+
+func (t *UpdateItineraryItemTool) RequiresConfirmation() bool {
+	return true
+}
+
+// UpdateItineraryItemArgs leaves fields that are not set unchanged.
+type UpdateItineraryItemArgs struct {
+	ItemID string  `json:"item_id" description:"ID of the item to update"`
+	Date   *string `json:"date,omitempty" description:"New date in YYYY-MM-DD format"`
+	Time   *string `json:"time,omitempty" description:"New local time in HH:MM (24h) format"`
+	Place  *string `json:"place,omitempty" description:"New place or activity description"`
+	Notes  *string `json:"notes,omitempty" description:"New notes"`
+}
+
+func (t *UpdateItineraryItemTool) Call(ctx context.Context, args UpdateItineraryItemArgs) (string, error) {
+	id, err := primitive.ObjectIDFromHex(args.ItemID)
+	if err != nil {
+		return "", fmt.Errorf("invalid item_id: %q", args.ItemID)
+	}
+
+	it, err := t.itineraries.update(ctx, func(it *model.Itinerary) error {
+		item, date, ok := it.FindItem(id)
+		if !ok {
+			return fmt.Errorf("itinerary item %s not found", args.ItemID)
+		}
+
+		updated := *item
+		if args.Date != nil {
+			date = *args.Date
+		}
+		if args.Time != nil {
+			updated.Time = *args.Time
+		}
+		if args.Place != nil {
+			updated.Place = *args.Place
+		}
+		if args.Notes != nil {
+			updated.Notes = *args.Notes
+		}
+
+		if err := validateItineraryItem(date, updated.Time, updated.Place); err != nil {
+			return err
+		}
+
+		// Re-adding the item keeps days and items sorted if the date or time changed
+		it.RemoveItem(id)
+		it.AddItem(date, &updated)
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return formatItinerary(it), nil
+}
+
+// ListItineraryTool describes the trip plan of the conversation
+type ListItineraryTool struct {
+	itineraries *itineraries
+}
+
+func (t *ListItineraryTool) Name() string {
+	return "list_itinerary"
+}
+
+func (t *ListItineraryTool) Description() string {
+	return "Lists the items of the user's trip itinerary for this conversation, grouped by day, including item IDs."
+}
+
+// CacheTTL opts out of caching, the itinerary changes during the conversation.
+func (t *ListItineraryTool) CacheTTL() time.Duration {
+	return 0
+}
+
+type ListItineraryArgs struct{}
+
+func (t *ListItineraryTool) Call(ctx context.Context, args ListItineraryArgs) (string, error) {
+	it, err := t.itineraries.load(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return formatItinerary(it), nil
+}
+
+func validateItineraryItem(date, clock, place string) error {
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+
+	// Items are sorted by time as strings, so hours need their leading zero
+	if clock != "" {
+		if _, err := time.Parse("15:04", clock); err != nil || len(clock) != len("15:04") {
+			return fmt.Errorf("invalid time %q, expected HH:MM", clock)
+		}
+	}
+
+	if strings.TrimSpace(place) == "" {
+		return errors.New("place is required")
+	}
+
+	return nil
+}
+
+func formatItinerary(it *model.Itinerary) string {
+	if len(it.Days) == 0 {
+		return "The itinerary is empty."
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Itinerary: %s\n", it.Title)
+	if it.TimeZone != "" {
+		fmt.Fprintf(&sb, "Time zone: %s\n", it.TimeZone)
+	}
+
+	for _, d := range it.Days {
+		fmt.Fprintf(&sb, "\n%s:\n", d.Date)
+		for _, item := range d.Items {
+			clock := item.Time
+			if clock == "" {
+				clock = "any time"
+			}
+
+			fmt.Fprintf(&sb, "- [%s] %s: %s", item.ID.Hex(), clock, item.Place)
+			if item.Notes != "" {
+				fmt.Fprintf(&sb, " (%s)", item.Notes)
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
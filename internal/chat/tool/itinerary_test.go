@@ -0,0 +1,26 @@
+package tool
+
+import "testing"
+
+func TestValidateItineraryItem(t *testing.T) {
+	tests := []struct {
+		name        string
+		date, clock string
+		wantErr     bool
+	}{
+		{"valid", "2025-06-15", "09:30", false},
+		{"without time", "2025-06-15", "", false},
+		{"hour without leading zero", "2025-06-15", "9:30", true},
+		{"invalid time", "2025-06-15", "25:00", true},
+		{"invalid date", "15/06/2025", "10:00", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateItineraryItem(tt.date, tt.clock, "Belem Tower")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateItineraryItem(%q, %q) = %v, want error %v", tt.date, tt.clock, err, tt.wantErr)
+			}
+		})
+	}
+}
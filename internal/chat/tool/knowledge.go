@@ -0,0 +1,97 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/knowledge"
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"github.com/acai-travel/tech-challenge/internal/chat/recall"
+)
+
+const (
+	knowledgePassages     = 5
+	citationExcerptLength = 300
+)
+
+// KnowledgeBase finds document passages by meaning.
+type KnowledgeBase interface {
+	Search(ctx context.Context, query string, k int) ([]*knowledge.Passage, error)
+}
+
+// SearchKnowledgeTool retrieves passages of internal documents, like the
+// baggage or expense policies, so replies are grounded in them and can cite
+// them.
+type SearchKnowledgeTool struct {
+	kb KnowledgeBase
+}
+
+func NewSearchKnowledgeTool(kb KnowledgeBase) *SearchKnowledgeTool {
+	return &SearchKnowledgeTool{kb: kb}
+}
+
+func (t *SearchKnowledgeTool) Name() string {
+	return "search_knowledge_base"
+}
+
+func (t *SearchKnowledgeTool) Description() string {
+	return "Searches the company knowledge base (travel policies, baggage rules, expense policy, internal guides) for passages related to a question. " +
+		"Use it before answering questions about company rules. Cite the passages you use with their marker, e.g. [1]."
+}
+
+// CacheTTL opts out of caching, passages are collected as citations of the reply.
+func (t *SearchKnowledgeTool) CacheTTL() time.Duration {
+	return 0
+}
+
+type SearchKnowledgeArgs struct {
+	Query string `json:"query" description:"The question or topic to look for, e.g. 'cabin baggage allowance'"`
+}
+
+func (t *SearchKnowledgeTool) Call(ctx context.Context, args SearchKnowledgeArgs) (string, error) {
+	if strings.TrimSpace(args.Query) == "" {
+		return "", errors.New("query is required")
+	}
+
+	passages, err := t.kb.Search(ctx, args.Query, knowledgePassages)
+	if err != nil {
+		return "", fmt.Errorf("failed to search the knowledge base: %w", err)
+	}
+
+	if len(passages) == 0 {
+		return "No related passages were found in the knowledge base.", nil
+	}
+
+	citations, _ := CitationsFrom(ctx)
+
+	var sb strings.Builder
+	sb.WriteString("Passages from the knowledge base, most relevant first. Cite the ones you use with their marker.\n")
+
+	for i, p := range passages {
+		marker := i + 1
+		if citations != nil {
+			marker = citations.Add(&model.Citation{
+				DocumentID:    p.DocumentID,
+				DocumentTitle: p.DocumentTitle,
+				Page:          p.Page,
+				Heading:       p.Heading,
+				Excerpt:       recall.Excerpt(p.Text, citationExcerptLength),
+			}, p.Text)
+		}
+
+		source := p.DocumentTitle
+		if p.Heading != "" {
+			source += ", " + p.Heading
+		}
+		if p.Page > 0 {
+			source += fmt.Sprintf(", page %d", p.Page)
+		}
+
+		fmt.Fprintf(&sb, "\n[%d] %s:\n%s\n", marker, source, p.Text)
+	}
+
+	return sb.String(), nil
+}
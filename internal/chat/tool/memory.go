@@ -0,0 +1,148 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const recalledMemories = 20
+
+type MemoryStore interface {
+	SaveMemory(ctx context.Context, m *model.Memory) error
+	ListMemories(ctx context.Context, owner string) ([]*model.Memory, error)
+}
+
+// NewMemoryTools returns the tools to save and recall long-term facts about
+// the user of the current conversation.
+func NewMemoryTools(store MemoryStore) (*SaveMemoryTool, *RecallMemoriesTool) {
+	return &SaveMemoryTool{store: store}, &RecallMemoriesTool{store: store}
+}
+
+// SaveMemoryTool remembers a fact about the user for future conversations
+type SaveMemoryTool struct {
+	store MemoryStore
+}
+
+func (t *SaveMemoryTool) Name() string {
+	return "save_memory"
+}
+
+func (t *SaveMemoryTool) Description() string {
+	return "Remembers a lasting fact or preference about the user for future conversations, e.g. diet, home airport, seat preference, loyalty programs. " +
+		"Saving a key again replaces its value. Only save what the user said about themselves, never guesses."
+}
+
+// RequiresConfirmation is set as memories are kept across conversations.
+func (t *SaveMemoryTool) RequiresConfirmation() bool {
+	return true
+}
+
+type SaveMemoryArgs struct {
+	Key   string `json:"key" description:"Short snake_case name of the fact, e.g. 'diet' or 'home_airport'"`
+	Value string `json:"value" description:"The fact, e.g. 'vegetarian' or 'BCN (Barcelona)'"`
+}
+
+func (t *SaveMemoryTool) Call(ctx context.Context, args SaveMemoryArgs) (string, error) {
+	conv, ok := ConversationFrom(ctx)
+	if !ok {
+		return "", errors.New("saving memories requires a conversation")
+	}
+
+	// Anonymous users can't be told apart, their memories would be shared
+	if conv.Owner == "" {
+		return "", errors.New("memories are only kept for identified users")
+	}
+
+	key, value := model.MemoryKey(args.Key), strings.TrimSpace(args.Value)
+	if key == "" || len(key) > model.MaxMemoryKeyLength {
+		return "", fmt.Errorf("key is required and must be at most %d characters", model.MaxMemoryKeyLength)
+	}
+
+	if value == "" || len(value) > model.MaxMemoryValueLength {
+		return "", fmt.Errorf("value is required and must be at most %d characters", model.MaxMemoryValueLength)
+	}
+
+	memory := &model.Memory{
+		ID:                   primitive.NewObjectID(),
+		Owner:                conv.Owner,
+		Key:                  key,
+		Value:                value,
+		SourceConversationID: conv.ID,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+
+	// The fact comes from the message being replied to
+	for i := len(conv.Messages) - 1; i >= 0; i-- {
+		if conv.Messages[i].Role == model.RoleUser {
+			memory.SourceMessageID = conv.Messages[i].ID
+			break
+		}
+	}
+
+	if err := t.store.SaveMemory(ctx, memory); err != nil {
+		return "", fmt.Errorf("failed to save memory: %w", err)
+	}
+
+	return fmt.Sprintf("Remembered %s: %s", memory.Key, memory.Value), nil
+}
+
+// RecallMemoriesTool looks up facts remembered about the user
+type RecallMemoriesTool struct {
+	store MemoryStore
+}
+
+func (t *RecallMemoriesTool) Name() string {
+	return "recall_memories"
+}
+
+func (t *RecallMemoriesTool) Description() string {
+	return "Looks up facts and preferences remembered about the user from previous conversations. " +
+		"The most relevant ones are already in the system prompt, use it when more are needed."
+}
+
+// CacheTTL opts out of caching, memories belong to the user of the conversation.
+func (t *RecallMemoriesTool) CacheTTL() time.Duration {
+	return 0
+}
+
+type RecallMemoriesArgs struct {
+	Query string `json:"query,omitempty" description:"Optional topic to rank the memories by, e.g. 'food' or 'flights'"`
+}
+
+func (t *RecallMemoriesTool) Call(ctx context.Context, args RecallMemoriesArgs) (string, error) {
+	conv, ok := ConversationFrom(ctx)
+	if !ok {
+		return "", errors.New("recalling memories requires a conversation")
+	}
+
+	if conv.Owner == "" {
+		return "Nothing is remembered about anonymous users.", nil
+	}
+
+	memories, err := t.store.ListMemories(ctx, conv.Owner)
+	if err != nil {
+		return "", fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	if len(memories) == 0 {
+		return "Nothing is remembered about the user yet.", nil
+	}
+
+	return "Remembered about the user:\n" + FormatMemories(model.RankMemories(memories, args.Query, recalledMemories)), nil
+}
+
+// FormatMemories lists the memories one per line, as shown to the model.
+func FormatMemories(memories []*model.Memory) string {
+	var sb strings.Builder
+	for _, m := range memories {
+		fmt.Fprintf(&sb, "- %s: %s (%s)\n", m.Key, m.Value, m.UpdatedAt.Format(time.DateOnly))
+	}
+	return sb.String()
+}
@@ -0,0 +1,101 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/recall"
+)
+
+const (
+	defaultRecallLimit = 5
+	maxRecallLimit     = 10
+
+	recallExcerptLength = 400
+)
+
+// Recaller finds messages of past conversations by meaning.
+type Recaller interface {
+	Search(ctx context.Context, owner, query string, k int) ([]*recall.Hit, error)
+}
+
+// RecallTool lets the assistant look up what was said in the user's previous
+// conversations, e.g. "what did we say about visas?".
+type RecallTool struct {
+	recaller Recaller
+}
+
+func NewRecallTool(recaller Recaller) *RecallTool {
+	return &RecallTool{recaller: recaller}
+}
+
+func (t *RecallTool) Name() string {
+	return "recall_past_conversations"
+}
+
+func (t *RecallTool) Description() string {
+	return "Searches the user's previous conversations for messages related to a topic, by meaning rather than exact words. " +
+		"Use it when the user refers to something discussed before, e.g. 'remind me what we said about visas'."
+}
+
+// CacheTTL opts out of caching, previous conversations belong to the user of the conversation.
+func (t *RecallTool) CacheTTL() time.Duration {
+	return 0
+}
+
+// RecallArgs are the arguments of RecallTool. The limit bounds match
+// defaultRecallLimit and maxRecallLimit.
+type RecallArgs struct {
+	Query string `json:"query" description:"What to look for, phrased as a short description of the topic"`
+	Limit int    `json:"limit,omitempty" description:"Maximum number of messages to return, defaults to 5" minimum:"1" maximum:"10"`
+}
+
+func (t *RecallTool) Call(ctx context.Context, args RecallArgs) (string, error) {
+	if strings.TrimSpace(args.Query) == "" {
+		return "", errors.New("query is required")
+	}
+
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultRecallLimit
+	}
+	limit = min(limit, maxRecallLimit)
+
+	// The current conversation is already in the context of the model, so
+	// its messages are skipped
+	conv, _ := ConversationFrom(ctx)
+	owner, skipped := "", 0
+	if conv != nil {
+		owner, skipped = conv.Owner, len(conv.Messages)
+	}
+
+	hits, err := t.recaller.Search(ctx, owner, args.Query, limit+skipped)
+	if err != nil {
+		return "", fmt.Errorf("failed to search past conversations: %w", err)
+	}
+
+	var sb strings.Builder
+	found := 0
+
+	for _, h := range hits {
+		if found == limit {
+			break
+		}
+		if conv != nil && h.ConversationID == conv.ID {
+			continue
+		}
+
+		found++
+		fmt.Fprintf(&sb, "- In %q on %s, the %s said: %s\n",
+			h.Title, h.CreatedAt.Format(time.DateOnly), h.Role, recall.Excerpt(h.Content, recallExcerptLength))
+	}
+
+	if found == 0 {
+		return "Nothing related was found in previous conversations.", nil
+	}
+
+	return "Related messages from previous conversations, most relevant first:\n" + sb.String(), nil
+}
@@ -2,10 +2,7 @@ package tool
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-
-	"github.com/openai/openai-go/v2"
+	"time"
 )
 
 type WeatherClient interface {
@@ -28,26 +25,15 @@ func (w *WeatherTool) Description() string {
 	return "Get weather at the given location"
 }
 
-func (w *WeatherTool) Parameters() openai.FunctionParameters {
-	return openai.FunctionParameters{
-		"type": "object",
-		"properties": map[string]any{
-			"location": map[string]string{
-				"type":        "string",
-				"description": "City name or location",
-			},
-		},
-		"required": []string{"location"},
-	}
+// CacheTTL keeps the current weather for a few minutes.
+func (w *WeatherTool) CacheTTL() time.Duration {
+	return 15 * time.Minute
 }
 
-func (t *WeatherTool) Execute(ctx context.Context, arguments string) (string, error) {
-	var args struct {
-		Location string `json:"location"`
-	}
-	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-		return "", fmt.Errorf("invalid arguments: %w", err)
-	}
+type WeatherArgs struct {
+	Location string `json:"location" description:"City name or location"`
+}
 
+func (t *WeatherTool) Call(ctx context.Context, args WeatherArgs) (string, error) {
 	return t.client.GetCurrentWeather(ctx, args.Location)
 }
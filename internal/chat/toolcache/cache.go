@@ -0,0 +1,227 @@
+// Package toolcache caches the results of tool calls, so the same question
+// asked again, e.g. the weather in Barcelona, doesn't hit the network again.
+// Results are keyed by tool name and normalized arguments, and kept in a
+// Store: an in-process LRU, or MongoDB to share them between instances.
+package toolcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// DefaultTTL is how long results of tools without a TTL of their own are
+// cached.
+const DefaultTTL = 10 * time.Minute
+
+// Cacheable is implemented by tools choosing how long their results are
+// cached. Tools whose results depend on the conversation or that change
+// data must return 0 to opt out.
+type Cacheable interface {
+	CacheTTL() time.Duration
+}
+
+// Store keeps cached results until they expire.
+type Store interface {
+	// Get returns the value of the key, if cached and not expired.
+	Get(ctx context.Context, key string) (string, bool, error)
+
+	// Set caches the value for the given time.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+}
+
+// Cache wraps tools so their results are cached in a store.
+type Cache struct {
+	store   Store
+	ttl     time.Duration
+	ttls    map[string]time.Duration
+	metrics *metrics
+
+	hits, misses atomic.Int64
+}
+
+type Option func(*Cache)
+
+// WithTTL sets how long results of tools without a TTL of their own are
+// cached.
+func WithTTL(d time.Duration) Option {
+	return func(c *Cache) {
+		if d > 0 {
+			c.ttl = d
+		}
+	}
+}
+
+// WithToolTTL overrides the TTL of a tool, 0 disables its caching.
+func WithToolTTL(name string, d time.Duration) Option {
+	return func(c *Cache) {
+		if d >= 0 {
+			c.ttls[name] = d
+		}
+	}
+}
+
+func New(store Store, opts ...Option) *Cache {
+	c := &Cache{store: store, ttl: DefaultTTL, ttls: map[string]time.Duration{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	metrics, err := newMetrics(otel.Meter("acai.chat.toolcache"), c)
+	if err != nil {
+		// Tool results are cached without metrics rather than not at all
+		slog.Error("Failed to create tool cache metrics", "error", err)
+		metrics, _ = newMetrics(noop.Meter{}, c)
+	}
+
+	c.metrics = metrics
+	return c
+}
+
+// TTL returns how long results of the tool are cached, 0 when they are not.
+// Tools requiring confirmation are never cached, their calls change data.
+func (c *Cache) TTL(t registry.Tool) time.Duration {
+	if d, ok := c.ttls[t.Name()]; ok {
+		return d
+	}
+
+	if confirm, ok := registry.As[registry.Confirmable](t); ok && confirm.RequiresConfirmation() {
+		return 0
+	}
+
+	if cacheable, ok := registry.As[Cacheable](t); ok {
+		return max(cacheable.CacheTTL(), 0)
+	}
+
+	return c.ttl
+}
+
+// Wrap returns the tool with its results cached, or the tool itself when its
+// results are not cached.
+func (c *Cache) Wrap(t registry.Tool) registry.Tool {
+	ttl := c.TTL(t)
+	if ttl == 0 {
+		return t
+	}
+	return &cached{Tool: t, cache: c, ttl: ttl}
+}
+
+// HitRatio returns the share of lookups served from the cache since it was
+// created, 0 before any lookup.
+func (c *Cache) HitRatio() float64 {
+	hits, misses := c.hits.Load(), c.misses.Load()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+type cached struct {
+	registry.Tool
+	cache *Cache
+	ttl   time.Duration
+}
+
+func (t *cached) Unwrap() any { return t.Tool }
+
+// Execute returns the cached result of the same call, or runs the tool and
+// caches its result. Failed calls are not cached, and failing to reach the
+// store only costs the cache.
+func (t *cached) Execute(ctx context.Context, arguments string) (string, error) {
+	key, ok := Key(t.Name(), arguments)
+	if !ok {
+		return t.Tool.Execute(ctx, arguments)
+	}
+
+	value, hit, err := t.cache.store.Get(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read tool cache", "tool", t.Name(), "error", err)
+	}
+
+	t.cache.record(ctx, t.Name(), hit)
+	if hit {
+		return value, nil
+	}
+
+	result, err := t.Tool.Execute(ctx, arguments)
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.cache.store.Set(ctx, key, result, t.ttl); err != nil {
+		slog.WarnContext(ctx, "Failed to write tool cache", "tool", t.Name(), "error", err)
+	}
+
+	return result, nil
+}
+
+func (c *Cache) record(ctx context.Context, tool string, hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	c.metrics.recordLookup(ctx, tool, hit)
+}
+
+// Key returns the cache key of a call: the tool name and a hash of the
+// normalized arguments. Calls differing only in key order, whitespace, case
+// of strings or null fields share a key. It reports false for arguments that
+// are not a JSON object.
+func Key(tool, arguments string) (string, bool) {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+
+	dec := json.NewDecoder(strings.NewReader(arguments))
+	dec.UseNumber()
+
+	var args map[string]any
+	if err := dec.Decode(&args); err != nil || dec.More() {
+		return "", false
+	}
+
+	// Maps are marshaled with sorted keys
+	data, err := json.Marshal(normalize(args))
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha256.Sum256(data)
+	return tool + ":" + hex.EncodeToString(sum[:]), true
+}
+
+func normalize(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			if item != nil {
+				out[k] = normalize(item)
+			}
+		}
+		return out
+
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+
+	case string:
+		return strings.ToLower(strings.Join(strings.Fields(v), " "))
+
+	default:
+		return v
+	}
+}
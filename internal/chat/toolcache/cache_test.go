@@ -0,0 +1,164 @@
+package toolcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acai-travel/tech-challenge/internal/chat/registry"
+)
+
+type weatherArgs struct {
+	Location string `json:"location"`
+	Units    string `json:"units,omitempty"`
+}
+
+// weatherTool counts its calls, and fails for unknown locations.
+type weatherTool struct {
+	calls int
+}
+
+func (t *weatherTool) Name() string        { return "get_weather" }
+func (t *weatherTool) Description() string { return "Gets the weather" }
+
+func (t *weatherTool) Call(ctx context.Context, args weatherArgs) (string, error) {
+	t.calls++
+	if args.Location == "Atlantis" {
+		return "", errors.New("unknown location")
+	}
+	return "Sunny in " + args.Location, nil
+}
+
+type cacheableWeatherTool struct {
+	weatherTool
+	ttl time.Duration
+}
+
+func (t *cacheableWeatherTool) CacheTTL() time.Duration { return t.ttl }
+
+func TestKey(t *testing.T) {
+	a, ok := Key("get_weather", `{"location": "Barcelona", "units": null}`)
+	if !ok {
+		t.Fatal("expected a key")
+	}
+
+	for _, args := range []string{`{"location":"barcelona"}`, `{ "location": "  BARCELONA " }`} {
+		if b, _ := Key("get_weather", args); b != a {
+			t.Errorf("Key(%s) = %s, want %s", args, b, a)
+		}
+	}
+
+	if b, _ := Key("get_holidays", `{"location": "Barcelona"}`); b == a {
+		t.Error("expected different tools to have different keys")
+	}
+
+	if b, _ := Key("get_weather", `{"location": "Madrid"}`); b == a {
+		t.Error("expected different arguments to have different keys")
+	}
+
+	if _, ok := Key("get_weather", `["Barcelona"]`); ok {
+		t.Error("expected no key for arguments that are not an object")
+	}
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	l := NewLRU(2)
+	l.now = func() time.Time { return now }
+
+	_ = l.Set(ctx, "a", "1", time.Minute)
+	_ = l.Set(ctx, "b", "2", time.Minute)
+	_, _, _ = l.Get(ctx, "a")
+	_ = l.Set(ctx, "c", "3", time.Minute)
+
+	if _, ok, _ := l.Get(ctx, "b"); ok {
+		t.Error("expected the least recently used key to be evicted")
+	}
+	if v, ok, _ := l.Get(ctx, "a"); !ok || v != "1" {
+		t.Errorf("got %q, %v, want 1, true", v, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok, _ := l.Get(ctx, "c"); ok {
+		t.Error("expected expired keys to be missing")
+	}
+	if l.Len() != 1 {
+		t.Errorf("got %d results, want 1 once the expired one is looked up", l.Len())
+	}
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("serves repeated calls from the cache", func(t *testing.T) {
+		c := New(NewLRU(10))
+		tool := &weatherTool{}
+		cached := c.Wrap(registry.Typed[weatherArgs](tool))
+
+		for _, args := range []string{`{"location": "Barcelona"}`, `{"location": "barcelona "}`} {
+			out, err := cached.Execute(ctx, args)
+			if err != nil || out != "Sunny in Barcelona" {
+				t.Fatalf("got %q, %v", out, err)
+			}
+		}
+
+		if tool.calls != 1 {
+			t.Errorf("got %d calls, want 1", tool.calls)
+		}
+		if r := c.HitRatio(); r != 0.5 {
+			t.Errorf("got hit ratio %v, want 0.5", r)
+		}
+	})
+
+	t.Run("doesn't cache failures", func(t *testing.T) {
+		tool := &weatherTool{}
+		cached := New(NewLRU(10)).Wrap(registry.Typed[weatherArgs](tool))
+
+		for range 2 {
+			if _, err := cached.Execute(ctx, `{"location": "Atlantis"}`); err == nil {
+				t.Fatal("expected error")
+			}
+		}
+
+		if tool.calls != 2 {
+			t.Errorf("got %d calls, want 2", tool.calls)
+		}
+	})
+
+	t.Run("lets tools choose their TTL or opt out", func(t *testing.T) {
+		c := New(NewLRU(10), WithTTL(time.Hour))
+
+		tool := registry.Typed[weatherArgs](&cacheableWeatherTool{ttl: 5 * time.Minute})
+		if got := c.TTL(tool); got != 5*time.Minute {
+			t.Errorf("got TTL %s, want 5m", got)
+		}
+
+		tool = registry.Typed[weatherArgs](&cacheableWeatherTool{ttl: 0})
+		if c.Wrap(tool) != tool {
+			t.Error("expected tools opting out not to be wrapped")
+		}
+
+		if got := c.TTL(registry.Typed[weatherArgs](&weatherTool{})); got != time.Hour {
+			t.Errorf("got default TTL %s, want 1h", got)
+		}
+	})
+
+	t.Run("never caches tools requiring confirmation", func(t *testing.T) {
+		tool := registry.RequireConfirmation(registry.Typed[weatherArgs](&weatherTool{}))
+		if New(NewLRU(10)).Wrap(tool) != tool {
+			t.Error("expected tools requiring confirmation not to be wrapped")
+		}
+	})
+
+	t.Run("overrides the TTL of a tool", func(t *testing.T) {
+		c := New(NewLRU(10), WithToolTTL("get_weather", 0))
+		tool := registry.Typed[weatherArgs](&weatherTool{})
+
+		if c.Wrap(tool) != tool {
+			t.Error("expected the tool not to be wrapped")
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package toolcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the number of results an LRU keeps by default.
+const DefaultSize = 1000
+
+// LRU is an in-process store keeping the most recently used results.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List // most recently used first
+
+	now func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+var _ Store = (*LRU)(nil)
+
+// NewLRU returns a store of up to size results, DefaultSize if not positive.
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &LRU{size: size, items: map[string]*list.Element{}, order: list.New(), now: time.Now}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) (string, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return "", false, nil
+	}
+
+	entry := el.Value.(*lruEntry)
+	if !l.now().Before(entry.expires) {
+		l.order.Remove(el)
+		delete(l.items, key)
+		return "", false, nil
+	}
+
+	l.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+func (l *LRU) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := l.now().Add(ttl)
+
+	if el, ok := l.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		l.order.MoveToFront(el)
+		return nil
+	}
+
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+
+	return nil
+}
+
+// Len returns the number of results kept, expired ones included until they
+// are evicted or looked up.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.order.Len()
+}
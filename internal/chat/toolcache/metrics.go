@@ -0,0 +1,58 @@
+package toolcache
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// metrics of the cache.
+type metrics struct {
+	lookups metric.Int64Counter
+}
+
+func newMetrics(meter metric.Meter, c *Cache) (*metrics, error) {
+	m := &metrics{}
+
+	var err error
+
+	// Number of cache lookups by tool and result, hit or miss
+	m.lookups, err = meter.Int64Counter(
+		"assistant.tool.cache.lookups",
+		metric.WithDescription("Number of tool cache lookups by tool and result"),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool cache lookups counter: %w", err)
+	}
+
+	// Share of lookups served from the cache
+	_, err = meter.Float64ObservableGauge(
+		"assistant.tool.cache.hit_ratio",
+		metric.WithDescription("Share of tool cache lookups served from the cache"),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			o.Observe(c.HitRatio())
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tool cache hit ratio gauge: %w", err)
+	}
+
+	return m, nil
+}
+
+func (m *metrics) recordLookup(ctx context.Context, tool string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	m.lookups.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("tool.name", tool),
+		attribute.String("cache.result", result),
+	))
+}
@@ -0,0 +1,64 @@
+package toolcache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collection = "tool_cache"
+
+// MongoStore shares cached results between the instances of the server.
+// Expired results are removed by a TTL index, see EnsureIndexes.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+type mongoEntry struct {
+	Key       string    `bson:"_id"`
+	Value     string    `bson:"value"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+var _ Store = (*MongoStore)(nil)
+
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{coll: db.Collection(collection)}
+}
+
+// EnsureIndexes creates the TTL index removing expired results. It is
+// idempotent.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("tool_cache_expires_at").SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (s *MongoStore) Get(ctx context.Context, key string) (string, bool, error) {
+	var entry mongoEntry
+
+	// The TTL monitor runs about every minute, expired results can linger
+	err := s.coll.FindOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$gt": time.Now()}}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return entry.Value, true, nil
+}
+
+func (s *MongoStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	entry := mongoEntry{Key: key, Value: value, ExpiresAt: time.Now().Add(ttl)}
+
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": key}, entry, options.Replace().SetUpsert(true))
+	return err
+}
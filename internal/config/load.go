@@ -0,0 +1,233 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets when the configuration is printed.
+const redacted = "REDACTED"
+
+// setting is a field of the configuration, found by walking its sections.
+type setting struct {
+	path   string // e.g. mongo.database
+	env    string
+	usage  string
+	secret string // true, or url to only redact the password of the URL
+	value  reflect.Value
+}
+
+// flagName returns the name of the flag of the setting, e.g. openai.reply-models.
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.path, "_", "-")
+}
+
+// settings returns the settings of the configuration, in declaration order.
+func (c *Config) settings() []setting {
+	var out []setting
+
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			path := prefix + field.Tag.Get("yaml")
+
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(v.Field(i), path+".")
+				continue
+			}
+
+			out = append(out, setting{
+				path:   path,
+				env:    field.Tag.Get("env"),
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret"),
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+
+	return out
+}
+
+// Load returns the configuration of the defaults overridden by the file set
+// with the -config flag or ACAI_CONFIG, environment variables, then the
+// flags of args. The flags of the settings are added to fs, which is parsed,
+// so flags of the caller must be defined first. When fs is nil, e.g. for
+// commands with their own flags, only the file and environment are read.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	path := os.Getenv(FileEnv)
+	flags := map[string]string{}
+
+	if fs != nil {
+		fs.StringVar(&path, "config", path, "path of the YAML configuration file (env "+FileEnv+")")
+		for _, s := range settings {
+			usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+			fn := func(v string) error {
+				flags[s.path] = v
+				return nil
+			}
+
+			// Boolean flags can be set without a value, e.g. -retention.dry-run
+			if s.value.Kind() == reflect.Bool {
+				fs.BoolFunc(s.flagName(), usage, fn)
+			} else {
+				fs.Func(s.flagName(), usage, fn)
+			}
+		}
+
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := set(s.value, v); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := flags[s.path]; ok {
+			if err := set(s.value, v); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.flagName(), err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// loadFile overrides the settings of the YAML file. Unknown settings are
+// rejected, so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// set parses the value of a variable or flag into the field.
+func set(field reflect.Value, v string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(v)
+
+	case []string:
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+
+	case time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+
+	case bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+
+	case int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+
+	case float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+
+	return nil
+}
+
+// Redacted returns a copy of the configuration with secrets replaced, e.g.
+// API keys and the password of the MongoDB connection string.
+func (c *Config) Redacted() *Config {
+	out := *c
+
+	for _, s := range out.settings() {
+		v := s.value.String()
+		if s.secret == "" || v == "" {
+			continue
+		}
+
+		if s.secret == "url" {
+			if u, err := url.Parse(v); err == nil {
+				if _, ok := u.User.Password(); ok {
+					u.User = url.UserPassword(u.User.Username(), redacted)
+				}
+				s.value.SetString(u.String())
+				continue
+			}
+		}
+
+		s.value.SetString(redacted)
+	}
+
+	return &out
+}
+
+// Print writes the configuration, with secrets redacted, as YAML that can be
+// used as a configuration file.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
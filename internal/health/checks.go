@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Configured checks a setting is set, e.g. the API key of a provider.
+func Configured(setting, value string) CheckFunc {
+	return func(ctx context.Context) error {
+		if value == "" {
+			return fmt.Errorf("%s is not configured", setting)
+		}
+		return nil
+	}
+}
+
+// Reachable checks the host of the URL answers HTTP requests. Any response
+// counts, even errors, as the URL may require authentication.
+func Reachable(client *http.Client, url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+		}
+		return nil
+	}
+}
+
+// Cached runs the check at most once per ttl, returning the last error in
+// between, so probes don't hammer external services.
+func Cached(fn CheckFunc, ttl time.Duration) CheckFunc {
+	var mu sync.Mutex
+	var last time.Time
+	var lastErr error
+
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !last.IsZero() && time.Since(last) < ttl {
+			return lastErr
+		}
+
+		lastErr = fn(ctx)
+
+		// Interrupted checks say nothing of the dependency, they run again
+		if errors.Is(lastErr, context.Canceled) {
+			return lastErr
+		}
+
+		last = time.Now()
+		return lastErr
+	}
+}
@@ -0,0 +1,187 @@
+// Package health serves the liveness and readiness endpoints of the server.
+// Liveness only tells the process is up, readiness runs the checks of its
+// dependencies, so traffic is only routed to instances able to serve it.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds each check, slower dependencies are failing.
+const DefaultTimeout = 2 * time.Second
+
+// Statuses of checks and of the readiness of the server.
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusDegraded     = "degraded"
+	StatusUnavailable  = "unavailable"
+	StatusShuttingDown = "shutting_down"
+)
+
+// CheckFunc returns an error when the dependency it checks can't be used.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	fn       CheckFunc
+	critical bool
+}
+
+// Health runs the readiness checks of the server.
+type Health struct {
+	timeout  time.Duration
+	checks   []check
+	draining atomic.Bool
+}
+
+type Option func(h *Health)
+
+// WithTimeout sets the time each check has to complete, DefaultTimeout by
+// default.
+func WithTimeout(d time.Duration) Option {
+	return func(h *Health) {
+		if d > 0 {
+			h.timeout = d
+		}
+	}
+}
+
+func New(opts ...Option) *Health {
+	h := &Health{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Critical adds a check of a dependency the server can't serve without, e.g.
+// its database. The server isn't ready while it fails.
+func (h *Health) Critical(name string, fn CheckFunc) {
+	h.checks = append(h.checks, check{name: name, fn: fn, critical: true})
+}
+
+// Optional adds a check of a dependency only some features need, e.g. the API
+// of a tool. The server is still ready while it fails, but degraded.
+func (h *Health) Optional(name string, fn CheckFunc) {
+	h.checks = append(h.checks, check{name: name, fn: fn})
+}
+
+// Drain makes the server not ready, for load balancers to stop routing
+// requests to it while in-flight ones complete during graceful shutdown.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// CheckResult is the outcome of a check.
+type CheckResult struct {
+	Status   string  `json:"status"`
+	Critical bool    `json:"critical"`
+	Latency  float64 `json:"latency_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Report is the readiness of the server, with the result of each check.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Ready reports whether the server can serve requests: it isn't shutting
+// down and no critical check fails. The checks run concurrently.
+func (h *Health) Ready(ctx context.Context) (Report, bool) {
+	if h.draining.Load() {
+		return Report{Status: StatusShuttingDown}, false
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(h.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := h.run(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range report.Checks {
+		if result.Status == StatusOK {
+			continue
+		}
+
+		if result.Critical {
+			report.Status, ready = StatusUnavailable, false
+		} else if ready {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report, ready
+}
+
+func (h *Health) run(ctx context.Context, c check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.fn(ctx)
+	result := CheckResult{
+		Status:   StatusOK,
+		Critical: c.critical,
+		Latency:  float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", h.timeout)
+		}
+		result.Status, result.Error = StatusFailing, err.Error()
+	}
+
+	return result
+}
+
+// Liveness answers as long as the process serves HTTP requests, so it is
+// only restarted when stuck. Dependencies aren't checked, their failures
+// aren't fixed by a restart.
+func (h *Health) Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+// Readiness answers 200 when the server is ready, 503 otherwise, with the
+// report of the checks.
+func (h *Health) Readiness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, ready := h.Ready(r.Context())
+
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
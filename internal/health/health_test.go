@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func ready(t *testing.T, h *Health) (int, Report) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.Readiness().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	return w.Code, report
+}
+
+func TestHealth_Readiness(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return errors.New("boom") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.Run("ready when all checks pass", func(t *testing.T) {
+		h := New()
+		h.Critical("mongo", ok)
+		h.Optional("weather", ok)
+
+		code, report := ready(t, h)
+		if code != http.StatusOK || report.Status != StatusOK || len(report.Checks) != 2 {
+			t.Fatalf("got %d %+v", code, report)
+		}
+	})
+
+	t.Run("degraded when an optional check fails", func(t *testing.T) {
+		h := New()
+		h.Critical("mongo", ok)
+		h.Optional("weather", fail)
+
+		code, report := ready(t, h)
+		if code != http.StatusOK || report.Status != StatusDegraded {
+			t.Fatalf("got %d %+v", code, report)
+		}
+		if c := report.Checks["weather"]; c.Status != StatusFailing || c.Error != "boom" {
+			t.Errorf("got weather check %+v", c)
+		}
+	})
+
+	t.Run("unavailable when a critical check fails or times out", func(t *testing.T) {
+		h := New(WithTimeout(10 * time.Millisecond))
+		h.Critical("mongo", slow)
+		h.Optional("weather", fail)
+
+		code, report := ready(t, h)
+		if code != http.StatusServiceUnavailable || report.Status != StatusUnavailable {
+			t.Fatalf("got %d %+v", code, report)
+		}
+		if c := report.Checks["mongo"]; c.Status != StatusFailing || c.Error != "timed out after 10ms" {
+			t.Errorf("got mongo check %+v", c)
+		}
+	})
+
+	t.Run("not ready once draining", func(t *testing.T) {
+		h := New()
+		h.Critical("mongo", ok)
+		h.Drain()
+
+		code, report := ready(t, h)
+		if code != http.StatusServiceUnavailable || report.Status != StatusShuttingDown {
+			t.Fatalf("got %d %+v", code, report)
+		}
+	})
+}
+
+func TestChecks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("configured", func(t *testing.T) {
+		if err := Configured("openai.api_key", "")(ctx); err == nil {
+			t.Error("expected an error for a missing setting")
+		}
+		if err := Configured("openai.api_key", "sk-123")(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("reachable", func(t *testing.T) {
+		status := http.StatusUnauthorized
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		defer srv.Close()
+
+		if err := Reachable(srv.Client(), srv.URL)(ctx); err != nil {
+			t.Errorf("expected client errors to count as reachable, got %v", err)
+		}
+
+		status = http.StatusBadGateway
+		if err := Reachable(srv.Client(), srv.URL)(ctx); err == nil {
+			t.Error("expected server errors to fail")
+		}
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		calls := 0
+		check := Cached(func(ctx context.Context) error {
+			calls++
+			return nil
+		}, time.Hour)
+
+		for range 3 {
+			if err := check(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("got %d calls, want 1", calls)
+		}
+	})
+}
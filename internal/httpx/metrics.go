@@ -15,10 +15,26 @@ type MetricsMiddleware struct {
 	requestCounter  metric.Int64Counter
 	requestDuration metric.Float64Histogram
 	activeRequests  metric.Int64UpDownCounter
+
+	// Paths of requests that are not recorded
+	excluded map[string]bool
+}
+
+// MetricsOption configures the metrics middleware.
+type MetricsOption func(m *MetricsMiddleware)
+
+// WithExcludedPaths excludes the requests of the paths from the metrics,
+// e.g. Prometheus scraping the metrics endpoint.
+func WithExcludedPaths(paths ...string) MetricsOption {
+	return func(m *MetricsMiddleware) {
+		for _, p := range paths {
+			m.excluded[p] = true
+		}
+	}
 }
 
 // NewMetricsMiddleware creates middleware with OpenTelemetry metrics
-func NewMetricsMiddleware() (*MetricsMiddleware, error) {
+func NewMetricsMiddleware(opts ...MetricsOption) (*MetricsMiddleware, error) {
 	meter := otel.Meter("acai.chat.http")
 
 	// Total number of HTTP requests
@@ -51,24 +67,37 @@ func NewMetricsMiddleware() (*MetricsMiddleware, error) {
 		return nil, fmt.Errorf("failed to create active requests gauge: %w", err)
 	}
 
-	return &MetricsMiddleware{
+	m := &MetricsMiddleware{
 		requestCounter:  requestCounter,
 		requestDuration: requestDuration,
 		activeRequests:  activeRequests,
-	}, nil
+		excluded:        map[string]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m, nil
 }
 
 // Handler returns an HTTP middleware that records metrics
 func (m *MetricsMiddleware) Handler() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.excluded[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			start := time.Now()
 			ctx := r.Context()
 
 			// Track active requests
+			route, method := Route(r), Method(r)
 			attributes := []attribute.KeyValue{
-				attribute.String("http.method", r.Method),
-				attribute.String("http.route", r.URL.Path),
+				attribute.String("http.method", method),
+				attribute.String("http.route", route),
 			}
 			m.activeRequests.Add(ctx, 1, metric.WithAttributes(attributes...))
 			defer m.activeRequests.Add(ctx, -1, metric.WithAttributes(attributes...))
@@ -84,8 +113,8 @@ func (m *MetricsMiddleware) Handler() func(http.Handler) http.Handler {
 
 			// Record metrics with dimensions
 			metricAttrs := []attribute.KeyValue{
-				attribute.String("http.method", r.Method),
-				attribute.String("http.route", r.URL.Path),
+				attribute.String("http.method", method),
+				attribute.String("http.route", route),
 				attribute.Int("http.status_code", srw.statusCode),
 			}
 
@@ -0,0 +1,119 @@
+package httpx
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID correlating a request with its logs, e.g.
+// set by a load balancer, and is returned in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs set by callers, longer ones are
+// replaced.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the ID of the request, or an empty string outside of
+// requests, e.g. in background jobs.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID stores the ID of the X-Request-ID header in the request context,
+// or a new one when the header is missing or invalid, and returns it in the
+// X-Request-ID header of the response.
+func RequestID() func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			handler.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// validRequestID reports whether the ID is short and only has visible ASCII
+// characters, so it can't forge log lines or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// LogHandler adds the ID of the request in the context of log records, e.g.
+// from slog.InfoContext, so the logs of a request can be told apart. The ID
+// is a top-level attribute, even in loggers with groups.
+func LogHandler(h slog.Handler) slog.Handler {
+	return &logHandler{Handler: h, base: h}
+}
+
+type logHandler struct {
+	slog.Handler
+
+	// base is the handler before the first group, groups are opened again
+	// on it with their attributes after adding the request ID
+	base   slog.Handler
+	groups []func(slog.Handler) slog.Handler
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	id := RequestIDFrom(ctx)
+	if id == "" {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	if len(h.groups) == 0 {
+		r.AddAttrs(slog.String("request_id", id))
+		return h.Handler.Handle(ctx, r)
+	}
+
+	handler := h.base.WithAttrs([]slog.Attr{slog.String("request_id", id)})
+	for _, apply := range h.groups {
+		handler = apply(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(h.groups) == 0 {
+		handler := h.Handler.WithAttrs(attrs)
+		return &logHandler{Handler: handler, base: handler}
+	}
+
+	return &logHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+		base:    h.base,
+		groups:  append(slices.Clip(h.groups), func(h slog.Handler) slog.Handler { return h.WithAttrs(attrs) }),
+	}
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return &logHandler{
+		Handler: h.Handler.WithGroup(name),
+		base:    h.base,
+		groups:  append(slices.Clip(h.groups), func(h slog.Handler) slog.Handler { return h.WithGroup(name) }),
+	}
+}
@@ -0,0 +1,73 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(LogHandler(slog.NewTextHandler(&buf, nil)))
+
+	handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "handled")
+	}))
+
+	for name, tc := range map[string]struct {
+		header string
+		keep   bool
+	}{
+		"keeps the ID of the caller": {"req-123", true},
+		"generates missing IDs":      {"", false},
+		"replaces invalid IDs":       {"bad id\nforged=1", false},
+		"replaces long IDs":          {strings.Repeat("a", maxRequestIDLength+1), false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf.Reset()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(RequestIDHeader, tc.header)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || (id == tc.header) != tc.keep {
+				t.Fatalf("got request ID %q for header %q", id, tc.header)
+			}
+
+			if !strings.Contains(buf.String(), "request_id="+id) {
+				t.Errorf("expected the request ID in logs, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestLogHandler_WithGroup(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(LogHandler(slog.NewJSONHandler(&buf, nil))).
+		With("service", "chat").
+		WithGroup("http").
+		With("method", "GET")
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-123"), "handled", "status", 200)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("invalid log record %q: %v", buf.String(), err)
+	}
+
+	if record["request_id"] != "req-123" || record["service"] != "chat" {
+		t.Errorf("expected top-level request_id and service, got %v", record)
+	}
+
+	group, _ := record["http"].(map[string]any)
+	if group["method"] != "GET" || group["status"] != float64(200) || group["request_id"] != nil {
+		t.Errorf("expected the group attributes only in the group, got %v", group)
+	}
+}
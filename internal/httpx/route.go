@@ -0,0 +1,52 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// UnmatchedRoute is the route of requests no route matched, so arbitrary
+// paths don't turn into metric labels.
+const UnmatchedRoute = "unmatched"
+
+// Route returns the path template of the gorilla/mux route that matched the
+// request, e.g. /conversations/{id}/calendar.ics, or UnmatchedRoute.
+//
+// Routes are only known to middleware of the router, see mux.Router.Use,
+// requests no route matches go through the NotFoundHandler of the router.
+func Route(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+
+	return UnmatchedRoute
+}
+
+// OtherMethod is the method of requests with a non-standard method, so
+// arbitrary methods don't turn into metric labels either.
+const OtherMethod = "_OTHER"
+
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Method returns the method of the request, or OtherMethod when it isn't
+// one of the standard HTTP methods.
+func Method(r *http.Request) string {
+	if standardMethods[r.Method] {
+		return r.Method
+	}
+
+	return OtherMethod
+}
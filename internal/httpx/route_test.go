@@ -0,0 +1,21 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethod(t *testing.T) {
+	for method, want := range map[string]string{
+		http.MethodGet:   http.MethodGet,
+		http.MethodPost:  http.MethodPost,
+		"PROPFIND":       OtherMethod,
+		"get":            OtherMethod,
+		"X-RANDOM-12345": OtherMethod,
+	} {
+		if got := Method(httptest.NewRequest(method, "/", nil)); got != want {
+			t.Errorf("Method() of a %s request = %q, want %q", method, got, want)
+		}
+	}
+}
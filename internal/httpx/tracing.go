@@ -0,0 +1,43 @@
+package httpx
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span named after the route of each request, see
+// Route, continuing the trace of the caller when the request carries its
+// context, e.g. a traceparent header. Handlers down the chain log and trace
+// within this span.
+func Tracing() func(handler http.Handler) http.Handler {
+	tracer := otel.Tracer("acai.chat.http")
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			ctx, span := tracer.Start(ctx, Method(r)+" "+Route(r),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", Route(r)),
+					attribute.String("url.path", r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			saw := &statusAwareResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			handler.ServeHTTP(saw, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", saw.status))
+			if saw.status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(saw.status))
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// UserHeader identifies the calling user. Authentication happens upstream
+// (API gateway), the server trusts the header as-is.
+const UserHeader = "X-User-ID"
+
+type userKey struct{}
+
+// WithUser returns a context carrying the ID of the calling user.
+func WithUser(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userKey{}, id)
+}
+
+// UserFrom returns the ID of the calling user, or an empty string for
+// anonymous requests.
+func UserFrom(ctx context.Context) string {
+	id, _ := ctx.Value(userKey{}).(string)
+	return id
+}
+
+// User stores the user of the X-User-ID header in the request context.
+func User() func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := strings.TrimSpace(r.Header.Get(UserHeader)); id != "" {
+				r = r.WithContext(WithUser(r.Context(), id))
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// clientInfo is how the assistant introduces itself to MCP servers.
+var clientInfo = Implementation{Name: "acai-assistant", Version: "1.0.0"}
+
+// transport exchanges JSON-RPC messages with a server.
+type transport interface {
+	// call sends a request and waits for its response
+	call(ctx context.Context, req *message) (*message, error)
+	notify(ctx context.Context, msg *message) error
+	close() error
+}
+
+// Client is a session with an MCP server.
+type Client struct {
+	config    ServerConfig
+	transport transport
+	nextID    atomic.Int64
+
+	server       Implementation
+	instructions string
+}
+
+// Connect starts or reaches the server and initializes a session. The
+// session is closed if initialization fails.
+func Connect(ctx context.Context, config ServerConfig) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var t transport
+	var err error
+
+	if config.Command != "" {
+		t, err = startStdio(config)
+	} else {
+		t = newHTTPTransport(config)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", config.Name, err)
+	}
+
+	c := &Client{config: config, transport: t}
+	if err := c.initialize(ctx); err != nil {
+		_ = t.close()
+		return nil, fmt.Errorf("mcp server %s: %w", config.Name, err)
+	}
+
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	var res initializeResult
+	err := c.call(ctx, "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      clientInfo,
+	}, &res)
+
+	if err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+
+	c.server, c.instructions = res.ServerInfo, res.Instructions
+	return c.transport.notify(ctx, &message{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// Name is the name of the server in the configuration.
+func (c *Client) Name() string {
+	return c.config.Name
+}
+
+// Config returns the configuration the client was connected with.
+func (c *Client) Config() ServerConfig {
+	return c.config
+}
+
+// Server describes the server, as reported during initialization.
+func (c *Client) Server() Implementation {
+	return c.server
+}
+
+// Timeout bounds each request to the server.
+func (c *Client) Timeout() time.Duration {
+	return c.config.timeout()
+}
+
+// ListTools returns all tools of the server, including the ones that are not
+// allow-listed.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+
+	for {
+		var res listToolsResult
+		if err := c.call(ctx, "tools/list", listToolsParams{Cursor: cursor}, &res); err != nil {
+			return nil, err
+		}
+
+		tools = append(tools, res.Tools...)
+		if res.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// CallTool runs a tool of the server with JSON object arguments.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	if !c.config.Allows(name) {
+		return nil, fmt.Errorf("tool %s of mcp server %s is not allowed", name, c.config.Name)
+	}
+
+	var res CallToolResult
+	if err := c.call(ctx, "tools/call", callToolParams{Name: name, Arguments: arguments}, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Close ends the session, stopping the server subprocess if any.
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.timeout())
+	defer cancel()
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	resp, err := c.transport.call(ctx, &message{JSONRPC: "2.0", ID: &id, Method: method, Params: data})
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("invalid %s result: %w", method, err)
+	}
+
+	return nil
+}
+
+// cancelled tells the server a request is no longer awaited.
+func cancelled(id *json.RawMessage, reason string) *message {
+	params, _ := json.Marshal(map[string]any{"requestId": id, "reason": reason})
+	return &message{JSONRPC: "2.0", Method: "notifications/cancelled", Params: params}
+}
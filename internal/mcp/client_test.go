@@ -0,0 +1,322 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The test binary doubles as a small stdio MCP server when started with
+// MCP_TEST_SERVER=1, see serveStdio.
+func TestMain(m *testing.M) {
+	switch os.Getenv("MCP_TEST_SERVER") {
+	case "1":
+		serveStdio()
+		os.Exit(0)
+	case "stalled":
+		// Never reads its stdin, until killed
+		select {}
+	}
+
+	os.Exit(m.Run())
+}
+
+func serveStdio() {
+	var mu sync.Mutex
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+
+		go func() {
+			if resp := handle(&req); resp != nil {
+				data, _ := json.Marshal(resp)
+
+				mu.Lock()
+				defer mu.Unlock()
+				fmt.Println(string(data))
+			}
+		}()
+	}
+}
+
+// handle answers a request of the test server: tools echo, fail and slow,
+// listed over two pages.
+func handle(req *message) *message {
+	if req.ID == nil {
+		return nil
+	}
+
+	result := func(v any) *message {
+		data, _ := json.Marshal(v)
+		return &message{JSONRPC: "2.0", ID: req.ID, Result: data}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return result(initializeResult{ProtocolVersion: ProtocolVersion, ServerInfo: Implementation{Name: "test", Version: "1.0"}})
+
+	case "tools/list":
+		var params listToolsParams
+		_ = json.Unmarshal(req.Params, &params)
+
+		if params.Cursor == "" {
+			echo := Tool{Name: "echo", Description: "Echoes the text", InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"text": map[string]any{"type": "string"}},
+				"required":   []string{"text"},
+			}}
+			return result(listToolsResult{Tools: []Tool{echo}, NextCursor: "2"})
+		}
+
+		return result(listToolsResult{Tools: []Tool{{Name: "fail"}, {Name: "slow"}}})
+
+	case "tools/call":
+		var params struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+
+		switch params.Name {
+		case "echo":
+			return result(CallToolResult{Content: []Content{TextContent(params.Arguments.Text)}})
+		case "fail":
+			return result(CallToolResult{Content: []Content{TextContent("it failed")}, IsError: true})
+		case "slow":
+			time.Sleep(time.Second)
+			return result(CallToolResult{Content: []Content{TextContent("done")}})
+		}
+	}
+
+	return &message{JSONRPC: "2.0", ID: req.ID, Error: &Error{Code: CodeMethodNotFound, Message: "method not found"}}
+}
+
+func TestSubprocessEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+
+	env := subprocessEnv(ServerConfig{Env: map[string]string{"MAPS_API_KEY": "maps"}})
+
+	for _, want := range []string{"PATH=/usr/bin", "MAPS_API_KEY=maps"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("expected %s in the environment, got %v", want, env)
+		}
+	}
+	for _, v := range env {
+		if strings.HasPrefix(v, "OPENAI_API_KEY=") {
+			t.Errorf("expected the secrets of the process not to be passed on, got %v", env)
+		}
+	}
+}
+
+func TestClient_Stdio(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := Connect(ctx, ServerConfig{
+		Name:    "test",
+		Command: os.Args[0],
+		Env:     map[string]string{"MCP_TEST_SERVER": "1"},
+		Timeout: Duration(200 * time.Millisecond),
+		Tools:   []string{"echo", "slow"},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	if client.Server().Name != "test" {
+		t.Errorf("unexpected server info %v", client.Server())
+	}
+
+	t.Run("lists tools of all pages", func(t *testing.T) {
+		tools, err := client.ListTools(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(tools) != 3 {
+			t.Errorf("got %d tools, want 3", len(tools))
+		}
+	})
+
+	t.Run("calls tools", func(t *testing.T) {
+		res, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text": "hello"}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(res.Content) != 1 || res.Content[0].Text != "hello" {
+			t.Errorf("unexpected result %v", res)
+		}
+	})
+
+	t.Run("refuses tools that are not allow-listed", func(t *testing.T) {
+		if _, err := client.CallTool(ctx, "fail", nil); err == nil {
+			t.Fatal("expected error calling a tool that is not allowed")
+		}
+	})
+
+	t.Run("bounds calls by the server timeout", func(t *testing.T) {
+		_, err := client.CallTool(ctx, "slow", nil)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+
+		// The session is still usable
+		if _, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text": "still there"}`)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestStdioTransport_WriteGivesUpWithTheContext(t *testing.T) {
+	transport, err := startStdio(ServerConfig{
+		Name:    "stalled",
+		Command: os.Args[0],
+		Env:     map[string]string{"MCP_TEST_SERVER": "stalled"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = transport.cmd.Process.Kill()
+		_ = transport.cmd.Wait()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Larger than the pipe buffer, so the write blocks
+	params, _ := json.Marshal(map[string]string{"text": strings.Repeat("x", 1<<20)})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- transport.write(ctx, &message{JSONRPC: "2.0", Method: "notifications/message", Params: params})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write still blocked after the context is done")
+	}
+}
+
+func TestClient_HTTP(t *testing.T) {
+	ctx := context.Background()
+	ended := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			ended = r.Header.Get(SessionHeader) == "session-1"
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var req message
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.Method == "initialize" {
+			w.Header().Set(SessionHeader, "session-1")
+		} else if r.Header.Get(SessionHeader) != "session-1" {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+
+		resp := handle(&req)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		data, _ := json.Marshal(resp)
+
+		// Tool results are streamed after a progress notification
+		if req.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	client, err := Connect(ctx, ServerConfig{Name: "remote", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, err := client.CallTool(ctx, "fail", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.IsError || !strings.Contains(res.Content[0].Text, "it failed") {
+		t.Errorf("unexpected result %v", res)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ended {
+		t.Error("expected the session to be ended")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := t.TempDir() + "/mcp.json"
+	config := `{"servers": [
+		{"name": "flights", "command": "flights-mcp", "tools": ["search"]},
+		{"name": "hotels", "url": "http://localhost:9000/mcp", "timeout": "10s"}
+	]}`
+
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	servers, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(servers) != 2 || servers[1].timeout() != 10*time.Second || servers[0].timeout() != DefaultTimeout {
+		t.Errorf("unexpected servers %+v", servers)
+	}
+
+	if !servers[0].Allows("search") || servers[0].Allows("book") || !servers[1].Allows("book") {
+		t.Error("unexpected allow-list")
+	}
+
+	for _, invalid := range []string{
+		`{"servers": [{"name": "both", "command": "x", "url": "http://x"}]}`,
+		`{"servers": [{"name": "has space", "command": "x"}]}`,
+		`{"servers": [{"name": "a", "command": "x"}, {"name": "a", "command": "y"}]}`,
+	} {
+		_ = os.WriteFile(path, []byte(invalid), 0o600)
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expected error loading %s", invalid)
+		}
+	}
+}
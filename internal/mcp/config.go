@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// DefaultTimeout bounds each request to a server without a timeout.
+const DefaultTimeout = 30 * time.Second
+
+// ServerConfig describes how to reach an MCP server. Servers are started as
+// a subprocess speaking over stdio when Command is set, or reached over
+// streamable HTTP at URL.
+type ServerConfig struct {
+	// Name identifies the server, its tools are exposed to the model as
+	// <name>_<tool>
+	Name string `json:"name"`
+
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Timeout bounds each request to the server, including tool calls
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// Tools allow-lists the tools of the server that are exposed, all when
+	// empty
+	Tools []string `json:"tools,omitempty"`
+
+	// Confirm lists the tools with side effects, e.g. bookings, the user has
+	// to approve each call of
+	Confirm []string `json:"confirm,omitempty"`
+}
+
+var serverNamePattern = regexp.MustCompile(`^[a-zA-Z0-9-]{1,32}$`)
+
+func (c *ServerConfig) Validate() error {
+	if !serverNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid server name %q, use up to 32 letters, digits and dashes", c.Name)
+	}
+
+	if (c.Command == "") == (c.URL == "") {
+		return fmt.Errorf("server %s: either command or url is required", c.Name)
+	}
+
+	if c.Timeout < 0 {
+		return fmt.Errorf("server %s: timeout must be positive", c.Name)
+	}
+
+	return nil
+}
+
+// Allows reports whether the tool of the server is allow-listed.
+func (c *ServerConfig) Allows(tool string) bool {
+	return len(c.Tools) == 0 || slices.Contains(c.Tools, tool)
+}
+
+func (c *ServerConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return time.Duration(c.Timeout)
+	}
+	return DefaultTimeout
+}
+
+// LoadConfig reads the servers of a JSON file like:
+//
+//	{"servers": [
+//	  {"name": "flights", "command": "flights-mcp", "args": ["--stdio"], "tools": ["search_flights", "book_flight"], "confirm": ["book_flight"]},
+//	  {"name": "hotels", "url": "https://hotels.example.com/mcp", "timeout": "10s"}
+//	]}
+func LoadConfig(path string) ([]ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Servers []ServerConfig `json:"servers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid MCP config %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i := range file.Servers {
+		c := &file.Servers[i]
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate MCP server %s", c.Name)
+		}
+		seen[c.Name] = true
+	}
+
+	return file.Servers, nil
+}
+
+// Duration is a time.Duration written as a string in JSON, e.g. "10s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New(`duration must be a string like "10s"`)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
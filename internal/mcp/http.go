@@ -0,0 +1,191 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Headers of the streamable HTTP transport
+const (
+	SessionHeader         = "Mcp-Session-Id"
+	ProtocolVersionHeader = "Mcp-Protocol-Version"
+)
+
+// httpTransport talks to a server with the streamable HTTP transport: each
+// message is POSTed, and responses come back either as a JSON body or as a
+// server-sent event stream.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu      sync.Mutex
+	session string
+}
+
+func newHTTPTransport(config ServerConfig) *httpTransport {
+	return &httpTransport{url: config.URL, headers: config.Headers, client: http.DefaultClient}
+}
+
+func (t *httpTransport) call(ctx context.Context, req *message) (*message, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if id := resp.Header.Get(SessionHeader); id != "" {
+		t.mu.Lock()
+		t.session = id
+		t.mu.Unlock()
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var msg message
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+		return &msg, nil
+
+	case "text/event-stream":
+		return readEvents(resp.Body, req.ID)
+	}
+
+	return nil, fmt.Errorf("unexpected response content type %q", mediaType)
+}
+
+func (t *httpTransport) notify(ctx context.Context, msg *message) error {
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (t *httpTransport) post(ctx context.Context, msg *message) (*http.Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(req, msg.Method != "initialize")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request, initialized bool) {
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	if !initialized {
+		return
+	}
+
+	req.Header.Set(ProtocolVersionHeader, ProtocolVersion)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session != "" {
+		req.Header.Set(SessionHeader, t.session)
+	}
+}
+
+// close ends the session on the server, if it created one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	session := t.session
+	t.mu.Unlock()
+
+	if session == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req, true)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	// Servers may not allow clients to end sessions
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("unexpected status %s ending the session", resp.Status)
+	}
+
+	return nil
+}
+
+// readEvents reads a server-sent event stream until the response to the
+// request. Other messages, like progress notifications, are skipped.
+func readEvents(r io.Reader, id *json.RawMessage) (*message, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageSize)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line != "" {
+			if v, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(v, " "))
+				data.WriteByte('\n')
+			}
+			continue
+		}
+
+		// A blank line ends the event
+		if data.Len() == 0 {
+			continue
+		}
+
+		var msg message
+		err := json.Unmarshal([]byte(data.String()), &msg)
+		data.Reset()
+
+		if err == nil && msg.isResponse() && bytes.Equal(*msg.ID, *id) {
+			return &msg, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, errors.New("event stream ended without a response")
+}
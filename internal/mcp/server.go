@@ -0,0 +1,327 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Handler runs a tool of the server. Errors are reported to the client as a
+// tool result with IsError, so the model can react to them.
+type Handler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// Server offers tools to MCP clients, over stdio or streamable HTTP.
+type Server struct {
+	info         Implementation
+	instructions string
+
+	mu       sync.RWMutex
+	tools    map[string]serverTool
+	sessions map[string]time.Time // last use by ID
+}
+
+const (
+	// sessionIdleTimeout ends the HTTP sessions of clients gone without a
+	// DELETE. Their next request is refused, and they initialize again.
+	sessionIdleTimeout = 30 * time.Minute
+
+	// maxSessions bounds the HTTP sessions, the least recently used ones
+	// end first.
+	maxSessions = 10000
+)
+
+type serverTool struct {
+	tool    Tool
+	handler Handler
+}
+
+func NewServer(info Implementation, instructions string) *Server {
+	return &Server{
+		info:         info,
+		instructions: instructions,
+		tools:        map[string]serverTool{},
+		sessions:     map[string]time.Time{},
+	}
+}
+
+// AddTool offers the tool to clients, replacing any tool of the same name.
+func (s *Server) AddTool(tool Tool, handler Handler) {
+	if tool.InputSchema == nil {
+		tool.InputSchema = map[string]any{"type": "object"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tools[tool.Name] = serverTool{tool: tool, handler: handler}
+}
+
+// handle answers a request, or returns nil for notifications and responses.
+func (s *Server) handle(ctx context.Context, req *message) *message {
+	if req.ID == nil || req.Method == "" {
+		return nil
+	}
+
+	result, err := s.dispatch(ctx, req)
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return &message{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return &message{JSONRPC: "2.0", ID: req.ID, Error: &Error{Code: CodeInternalError, Message: err.Error()}}
+	}
+
+	return &message{JSONRPC: "2.0", ID: req.ID, Result: data}
+}
+
+func (s *Server) dispatch(ctx context.Context, req *message) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return initializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      s.info,
+			Instructions:    s.instructions,
+		}, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		tools := make([]Tool, 0, len(s.tools))
+		for _, t := range s.tools {
+			tools = append(tools, t.tool)
+		}
+		sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+
+		return listToolsResult{Tools: tools}, nil
+
+	case "tools/call":
+		var params callToolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+
+		s.mu.RLock()
+		t, ok := s.tools[params.Name]
+		s.mu.RUnlock()
+
+		if !ok {
+			return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", params.Name)}
+		}
+
+		text, err := t.handler(ctx, params.Arguments)
+		if err != nil {
+			slog.ErrorContext(ctx, "MCP tool failed", "tool", params.Name, "error", err)
+			return CallToolResult{Content: []Content{TextContent(err.Error())}, IsError: true}, nil
+		}
+
+		return CallToolResult{Content: []Content{TextContent(text)}}, nil
+	}
+
+	return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+// ServeStdio answers newline-delimited messages read from r on w, until r
+// ends or the context is cancelled. Requests are handled concurrently and
+// can be cancelled by the client.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writeMu sync.Mutex
+	write := func(msg *message) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return
+		}
+
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, _ = w.Write(append(data, '\n'))
+	}
+
+	var mu sync.Mutex
+	inflight := map[string]context.CancelFunc{}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageSize)
+
+	for scanner.Scan() {
+		var req message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			write(&message{JSONRPC: "2.0", Error: &Error{Code: CodeParseError, Message: err.Error()}})
+			continue
+		}
+
+		if req.Method == "notifications/cancelled" {
+			var params struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			_ = json.Unmarshal(req.Params, &params)
+
+			mu.Lock()
+			if cancel, ok := inflight[string(params.RequestID)]; ok {
+				cancel()
+			}
+			mu.Unlock()
+			continue
+		}
+
+		if req.ID == nil {
+			continue
+		}
+
+		id := string(*req.ID)
+		reqCtx, cancel := context.WithCancel(ctx)
+
+		mu.Lock()
+		inflight[id] = cancel
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(inflight, id)
+				mu.Unlock()
+				cancel()
+			}()
+
+			resp := s.handle(reqCtx, &req)
+
+			// Cancelled requests are not answered
+			if resp != nil && reqCtx.Err() == nil {
+				write(resp)
+			}
+		}()
+	}
+
+	return scanner.Err()
+}
+
+// ServeHTTP implements the streamable HTTP transport. Each POST carries one
+// message, requests are answered with a JSON body. Sessions start with the
+// initialize request and end with a DELETE, or once idle. The server doesn't
+// send messages of its own, so GET streams are not supported.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Browsers may only call it from the same origin, against DNS rebinding
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+	}
+
+	session := r.Header.Get(SessionHeader)
+
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.sessions, session)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req message
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxMessageSize)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, &message{JSONRPC: "2.0", Error: &Error{Code: CodeParseError, Message: err.Error()}})
+		return
+	}
+
+	if req.Method == "initialize" {
+		session = s.startSession()
+		w.Header().Set(SessionHeader, session)
+	} else if !s.useSession(session) {
+		http.Error(w, "unknown or missing session, initialize again", http.StatusNotFound)
+		return
+	}
+
+	resp := s.handle(r.Context(), &req)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// startSession returns the ID of a new session, ending the idle ones first,
+// then the least recently used one when there are too many.
+func (s *Server) startSession() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var oldest string
+	for id, used := range s.sessions {
+		if now.Sub(used) > sessionIdleTimeout {
+			delete(s.sessions, id)
+		} else if oldest == "" || used.Before(s.sessions[oldest]) {
+			oldest = id
+		}
+	}
+
+	if len(s.sessions) >= maxSessions {
+		delete(s.sessions, oldest)
+	}
+
+	id := uuid.NewString()
+	s.sessions[id] = now
+	return id
+}
+
+// useSession reports whether the session exists and isn't idle, recording
+// its use.
+func (s *Server) useSession(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+
+	if time.Since(used) > sessionIdleTimeout {
+		delete(s.sessions, id)
+		return false
+	}
+
+	s.sessions[id] = time.Now()
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, msg *message) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(msg)
+}
@@ -0,0 +1,184 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer() *Server {
+	s := NewServer(Implementation{Name: "test", Version: "1.0"}, "")
+
+	s.AddTool(Tool{Name: "upper", Description: "Uppercases the text"}, func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		var args struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return "", err
+		}
+		if args.Text == "" {
+			return "", errors.New("text is required")
+		}
+		return strings.ToUpper(args.Text), nil
+	})
+
+	s.AddTool(Tool{Name: "wait"}, func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	return s
+}
+
+func TestServer_HTTP(t *testing.T) {
+	ctx := context.Background()
+
+	srv := httptest.NewServer(newTestServer())
+	defer srv.Close()
+
+	client, err := Connect(ctx, ServerConfig{Name: "test", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name != "upper" || tools[0].InputSchema == nil {
+		t.Errorf("unexpected tools %+v", tools)
+	}
+
+	res, err := client.CallTool(ctx, "upper", json.RawMessage(`{"text": "lisbon"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IsError || res.Content[0].Text != "LISBON" {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	t.Run("tool errors are results", func(t *testing.T) {
+		res, err := client.CallTool(ctx, "upper", json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !res.IsError || res.Content[0].Text != "text is required" {
+			t.Errorf("unexpected result %+v", res)
+		}
+	})
+
+	t.Run("unknown tools are protocol errors", func(t *testing.T) {
+		_, err := client.CallTool(ctx, "lower", json.RawMessage(`{}`))
+
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+			t.Fatalf("expected invalid params error, got %v", err)
+		}
+	})
+
+	t.Run("requests need a session", func(t *testing.T) {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("got status %d, want 404", resp.StatusCode)
+		}
+	})
+}
+
+func TestServer_Sessions(t *testing.T) {
+	s := newTestServer()
+
+	t.Run("idle sessions end", func(t *testing.T) {
+		id := s.startSession()
+		if !s.useSession(id) {
+			t.Fatal("expected the new session to be usable")
+		}
+
+		s.sessions[id] = time.Now().Add(-sessionIdleTimeout - time.Second)
+		if s.useSession(id) {
+			t.Error("expected the idle session to end")
+		}
+		if _, ok := s.sessions[id]; ok {
+			t.Error("expected the idle session to be forgotten")
+		}
+	})
+
+	t.Run("the least recently used sessions end first", func(t *testing.T) {
+		oldest := s.startSession()
+		s.sessions[oldest] = time.Now().Add(-time.Minute)
+		for len(s.sessions) < maxSessions {
+			s.sessions[fmt.Sprint(len(s.sessions))] = time.Now()
+		}
+
+		id := s.startSession()
+		if len(s.sessions) != maxSessions {
+			t.Errorf("got %d sessions, want %d", len(s.sessions), maxSessions)
+		}
+		if !s.useSession(id) || s.useSession(oldest) {
+			t.Error("expected the oldest session to end for the new one")
+		}
+	})
+}
+
+func TestServer_Stdio(t *testing.T) {
+	in, stdin := io.Pipe()
+	stdout, out := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- newTestServer().ServeStdio(context.Background(), in, out)
+		_ = out.Close()
+	}()
+
+	lines := bufio.NewScanner(stdout)
+	send := func(msg string) {
+		if _, err := io.WriteString(stdin, msg+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The wait call is cancelled, so only the upper call is answered
+	send(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"wait"}}`)
+	send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"upper","arguments":{"text":"rome"}}}`)
+	send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`)
+
+	if !lines.Scan() {
+		t.Fatal("expected a response")
+	}
+
+	var resp message
+	if err := json.Unmarshal(lines.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(*resp.ID) != "2" || !strings.Contains(string(resp.Result), "ROME") {
+		t.Errorf("unexpected response %s", lines.Bytes())
+	}
+
+	_ = stdin.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the server to stop when its input ends")
+	}
+
+	if lines.Scan() {
+		t.Errorf("unexpected response to a cancelled request: %s", lines.Bytes())
+	}
+}
@@ -0,0 +1,242 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// maxMessageSize bounds a single newline-delimited message.
+const maxMessageSize = 16 << 20
+
+// stopTimeout is how long a subprocess has to exit once its stdin is closed.
+const stopTimeout = 5 * time.Second
+
+// cancelTimeout bounds writing the cancellation of a request given up on.
+const cancelTimeout = time.Second
+
+// stdioTransport talks to a server subprocess over its stdin and stdout,
+// one JSON message per line. Its stderr is logged.
+type stdioTransport struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	// writing holds a token while a message is written, a channel rather
+	// than a mutex so waiting writers can give up with their context
+	writing chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	done    chan struct{}
+	err     error
+}
+
+// inheritedEnv are the variables of the process passed on to the server
+// subprocesses, so they find their commands and can write files. Others,
+// e.g. API keys, have to be set in ServerConfig.Env to be passed on.
+var inheritedEnv = []string{"PATH", "HOME", "TMPDIR", "LANG"}
+
+func startStdio(config ServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Env = subprocessEnv(config)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	t := &stdioTransport{
+		name:    config.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		writing: make(chan struct{}, 1),
+		pending: map[string]chan *message{},
+		done:    make(chan struct{}),
+	}
+
+	go t.read(stdout)
+	go t.log(stderr)
+
+	return t, nil
+}
+
+// subprocessEnv returns the environment of the server subprocess: the
+// inherited variables, then the configured ones, which override them.
+func subprocessEnv(config ServerConfig) []string {
+	var env []string
+	for _, k := range inheritedEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+
+	for k, v := range config.Env {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
+
+func (t *stdioTransport) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageSize)
+
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			slog.Warn("Invalid message from MCP server", "server", t.name, "error", err)
+			continue
+		}
+
+		switch {
+		case msg.isResponse():
+			t.mu.Lock()
+			ch, ok := t.pending[string(*msg.ID)]
+			delete(t.pending, string(*msg.ID))
+			t.mu.Unlock()
+
+			if ok {
+				ch <- &msg
+			}
+
+		case msg.ID != nil:
+			// Requests from the server, only pings are supported
+			reply := &message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage("{}")}
+			if msg.Method != "ping" {
+				reply = &message{JSONRPC: "2.0", ID: msg.ID, Error: &Error{Code: CodeMethodNotFound, Message: "method not found"}}
+			}
+			_ = t.write(context.Background(), reply)
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = errors.New("server closed the connection")
+	}
+
+	t.mu.Lock()
+	t.err = err
+	t.mu.Unlock()
+	close(t.done)
+}
+
+func (t *stdioTransport) log(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		slog.Info("MCP server log", "server", t.name, "line", scanner.Text())
+	}
+}
+
+// write sends a message, giving up when the context is done. A server that
+// stops reading can leave a message half written, so the transport is closed
+// then: nothing written after it could be parsed.
+func (t *stdioTransport) write(ctx context.Context, msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case t.writing <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-t.writing }()
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := t.stdin.Write(append(data, '\n'))
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		return err
+	case <-ctx.Done():
+		// Closing stdin unblocks the write and makes the server exit
+		_ = t.stdin.Close()
+		<-written
+		return ctx.Err()
+	}
+}
+
+func (t *stdioTransport) call(ctx context.Context, req *message) (*message, error) {
+	id := string(*req.ID)
+	ch := make(chan *message, 1)
+
+	t.mu.Lock()
+	t.pending[id] = ch
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(ctx, req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		return nil, t.err
+	case <-ctx.Done():
+		// The request context is done, the notification gets a short one
+		cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+		defer cancel()
+
+		_ = t.write(cctx, cancelled(req.ID, ctx.Err().Error()))
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, msg *message) error {
+	return t.write(ctx, msg)
+}
+
+// close closes the stdin of the server, which should make it exit, and kills
+// it if it doesn't in time.
+func (t *stdioTransport) close() error {
+	_ = t.stdin.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- t.cmd.Wait() }()
+
+	select {
+	case err := <-exited:
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return fmt.Errorf("mcp server %s exited: %w", t.name, err)
+		}
+		return err
+	case <-time.After(stopTimeout):
+		_ = t.cmd.Process.Kill()
+		<-exited
+		return fmt.Errorf("mcp server %s did not exit, killed", t.name)
+	}
+}
@@ -0,0 +1,44 @@
+package mongox
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnect(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rejects invalid connection strings", func(t *testing.T) {
+		_, err := Connect(ctx, "postgres://localhost", "acai")
+		if err == nil || !strings.Contains(err.Error(), "invalid MongoDB connection") {
+			t.Fatalf("expected an invalid connection error, got %v", err)
+		}
+	})
+
+	t.Run("gives up on unreachable deployments", func(t *testing.T) {
+		start := time.Now()
+		_, err := Connect(ctx, "mongodb://127.0.0.1:1/?directConnection=true", "acai",
+			WithTimeout(50*time.Millisecond), WithAttempts(3), WithBackoff(10*time.Millisecond, 10*time.Millisecond))
+
+		if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+			t.Fatalf("expected a connection error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("took %s to give up", elapsed)
+		}
+	})
+
+	t.Run("stops retrying when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		_, err := Connect(ctx, "mongodb://127.0.0.1:1/?directConnection=true", "acai",
+			WithTimeout(50*time.Millisecond), WithAttempts(100), WithBackoff(time.Second, time.Second))
+
+		if err == nil || strings.Contains(err.Error(), "after 100 attempts") {
+			t.Fatalf("expected to give up early, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package mongox
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// untraced are the commands of connection handshakes and authentication,
+// which are not operations of the application.
+var untraced = map[string]bool{
+	"hello":        true,
+	"isMaster":     true,
+	"saslStart":    true,
+	"saslContinue": true,
+	"endSessions":  true,
+}
+
+// TracingMonitor returns a command monitor tracing each operation with a
+// client span named after the command and collection, e.g. "find conversations".
+func TracingMonitor() *event.CommandMonitor {
+	tracer := otel.Tracer("acai.mongo")
+
+	// Spans of the commands in flight, by request ID
+	var spans sync.Map
+
+	end := func(requestID int64, err string) {
+		v, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+
+		span := v.(trace.Span)
+		if err != "" {
+			span.SetStatus(codes.Error, err)
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if untraced[e.CommandName] {
+				return
+			}
+
+			name := e.CommandName
+			attrs := []attribute.KeyValue{
+				attribute.String("db.system", "mongodb"),
+				attribute.String("db.namespace", e.DatabaseName),
+				attribute.String("db.operation.name", e.CommandName),
+			}
+			attrs = append(attrs, serverAttributes(e.ConnectionID)...)
+
+			// The collection is the value of the command name field, e.g. {"find": "conversations"}
+			if collection, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				name += " " + collection
+				attrs = append(attrs, attribute.String("db.collection.name", collection))
+			}
+
+			_, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+			spans.Store(e.RequestID, span)
+		},
+
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			end(e.RequestID, "")
+		},
+
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			end(e.RequestID, e.Failure)
+		},
+	}
+}
+
+// serverAttributes returns the address and port of the server from the ID
+// of the driver connection, e.g. "mongo:27017[-4]".
+func serverAttributes(connectionID string) []attribute.KeyValue {
+	addr := connectionID
+	if i := strings.LastIndex(addr, "[-"); i >= 0 {
+		addr = addr[:i]
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return []attribute.KeyValue{attribute.String("server.address", addr)}
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("server.address", host)}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, attribute.Int("server.port", p))
+	}
+	return attrs
+}
@@ -0,0 +1,25 @@
+package mongox
+
+import (
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestServerAttributes(t *testing.T) {
+	tests := []struct {
+		connectionID string
+		want         []attribute.KeyValue
+	}{
+		{"mongo:27017[-4]", []attribute.KeyValue{attribute.String("server.address", "mongo"), attribute.Int("server.port", 27017)}},
+		{"[::1]:27017[-12]", []attribute.KeyValue{attribute.String("server.address", "::1"), attribute.Int("server.port", 27017)}},
+		{"mongo", []attribute.KeyValue{attribute.String("server.address", "mongo")}},
+	}
+
+	for _, tt := range tests {
+		if got := serverAttributes(tt.connectionID); !slices.Equal(got, tt.want) {
+			t.Errorf("serverAttributes(%q) = %v, want %v", tt.connectionID, got, tt.want)
+		}
+	}
+}
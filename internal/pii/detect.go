@@ -0,0 +1,166 @@
+package pii
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// detector finds personal data of a kind: candidates matching the pattern,
+// kept when valid. Patterns may match more than the data, e.g. a word
+// following an IBAN, so shorter candidates ending at a word boundary are
+// tried too, longest first.
+type detector struct {
+	kind    Kind
+	pattern *regexp.Regexp
+	valid   func(candidate string) bool
+}
+
+var detectors = []detector{
+	{
+		kind:    KindEmail,
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	{
+		kind:    KindIBAN,
+		pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}`),
+		valid:   validIBAN,
+	},
+	{
+		kind:    KindCard,
+		pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}`),
+		valid:   validCard,
+	},
+	{
+		// Passport numbers, and Spanish DNI and NIE numbers
+		kind:    KindPassport,
+		pattern: regexp.MustCompile(`\b(?:[A-Z]{1,2}\d{6,9}|\d{8}[A-Z]|[XYZ]\d{7}[A-Z])\b`),
+		valid:   validPassport,
+	},
+	{
+		kind:    KindPhone,
+		pattern: regexp.MustCompile(`(?:\+|\b)\(?\d[\d ().-]{6,18}\d`),
+		valid:   validPhone,
+	},
+}
+
+func (d detector) find(s string) []Match {
+	var matches []Match
+	for _, loc := range d.pattern.FindAllStringIndex(s, -1) {
+		start, end := loc[0], loc[1]
+		if d.valid == nil {
+			matches = append(matches, Match{Kind: d.kind, Start: start, End: end})
+			continue
+		}
+
+		for e := end; e > start; e-- {
+			if e < len(s) && isAlnum(s[e-1]) && isAlnum(s[e]) {
+				continue
+			}
+
+			if isAlnum(s[e-1]) && d.valid(s[start:e]) {
+				matches = append(matches, Match{Kind: d.kind, Start: start, End: e})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// validIBAN checks the length and the mod 97 checksum of the IBAN.
+func validIBAN(s string) bool {
+	iban := strings.ReplaceAll(s, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	// The country code and check digits move to the end, letters count as
+	// numbers from 10 (A) to 35 (Z)
+	var n strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			n.WriteString(strconv.Itoa(int(r - 'A' + 10)))
+		} else {
+			n.WriteRune(r)
+		}
+	}
+
+	v, ok := new(big.Int).SetString(n.String(), 10)
+	return ok && v.Mod(v, big.NewInt(97)).Int64() == 1
+}
+
+// validCard checks the length and the Luhn checksum of the card number.
+func validCard(s string) bool {
+	number := digits(s)
+	if len(number) < 13 || len(number) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range len(number) {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// dniLetters are the control letters of DNI and NIE numbers, by the number
+// modulo 23.
+const dniLetters = "TRWAGMYFPDXBNJZSQVHLCKE"
+
+// validPassport checks the control letter of DNI and NIE numbers. Passport
+// numbers have no checksum shared by all countries.
+func validPassport(s string) bool {
+	last := s[len(s)-1]
+	if last < 'A' || last > 'Z' {
+		return true
+	}
+
+	// The first letter of NIE numbers stands for a digit
+	number := strings.NewReplacer("X", "0", "Y", "1", "Z", "2").Replace(s[:len(s)-1])
+	n := new(big.Int)
+	if _, ok := n.SetString(number, 10); !ok {
+		return false
+	}
+	return dniLetters[n.Mod(n, big.NewInt(23)).Int64()] == last
+}
+
+// date matches dates, whose digits could pass for a phone number, e.g.
+// 2025-06-15, 15.06.2025 or 15-06-2025, alone or in ranges.
+var date = regexp.MustCompile(`\b(?:\d{4}-\d{2}-\d{2}|\d{1,2}[.-]\d{1,2}[.-]\d{4})\b`)
+
+// validPhone checks the number of digits of the phone number: 9 or 10 for
+// national numbers, up to 15 for international ones, starting with +, so
+// longer numbers like failed card numbers aren't taken for phones. National
+// numbers must be written in groups, as a run of digits is more likely an
+// order number or a timestamp.
+func validPhone(s string) bool {
+	limit := 10
+	if strings.HasPrefix(s, "+") {
+		limit = 15
+	} else if digits(s) == s {
+		return false
+	}
+
+	n := len(digits(s))
+	return n >= 9 && n <= limit && !date.MatchString(s)
+}
@@ -0,0 +1,68 @@
+package pii
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// LogHandler masks the personal data r finds in the message, string and
+// error attributes of log records, e.g. the arguments of tool calls. It
+// should wrap the handlers adding attributes of their own, e.g. request IDs,
+// which aren't personal data.
+func LogHandler(h slog.Handler, r *Redactor) slog.Handler {
+	return &logHandler{Handler: h, redactor: r}
+}
+
+type logHandler struct {
+	slog.Handler
+	redactor *Redactor
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	masked := slog.NewRecord(r.Time, r.Level, h.redactor.Mask(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(h.mask(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, masked)
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = h.mask(a)
+	}
+	return &logHandler{Handler: h.Handler.WithAttrs(masked), redactor: h.redactor}
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return &logHandler{Handler: h.Handler.WithGroup(name), redactor: h.redactor}
+}
+
+func (h *logHandler) mask(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redactor.Mask(v.String()))
+
+	case slog.KindGroup:
+		group := v.Group()
+		masked := make([]any, len(group))
+		for i, ga := range group {
+			masked[i] = h.mask(ga)
+		}
+		return slog.Group(a.Key, masked...)
+
+	case slog.KindAny:
+		// Errors are only replaced when masked, keeping their type otherwise
+		if err, ok := v.Any().(error); ok {
+			if msg := h.redactor.Mask(err.Error()); msg != err.Error() {
+				return slog.Any(a.Key, errors.New(msg))
+			}
+		}
+	}
+
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,163 @@
+// Package pii detects personal data travellers paste into conversations,
+// e.g. card or passport numbers, to keep it from the model provider and the
+// logs. Detectors combine patterns with checksums where the data has one,
+// so numbers like prices or flight numbers are left alone.
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Kind is a kind of personal data.
+type Kind string
+
+const (
+	KindEmail    Kind = "email"
+	KindIBAN     Kind = "iban"
+	KindCard     Kind = "card"
+	KindPassport Kind = "passport"
+	KindPhone    Kind = "phone"
+)
+
+// Kinds are the kinds of personal data detected, by priority: when matches
+// overlap, the first kind wins, e.g. a card number isn't a phone number.
+var Kinds = []Kind{KindEmail, KindIBAN, KindCard, KindPassport, KindPhone}
+
+// ParseKind returns the kind named s, e.g. "card".
+func ParseKind(s string) (Kind, error) {
+	k := Kind(strings.ToLower(strings.TrimSpace(s)))
+	if !slices.Contains(Kinds, k) {
+		return "", fmt.Errorf("unknown personal data kind %q", s)
+	}
+	return k, nil
+}
+
+// Match is personal data found in a text, at text[Start:End].
+type Match struct {
+	Kind       Kind
+	Start, End int
+}
+
+// Redactor finds personal data of some kinds. A nil Redactor finds nothing.
+type Redactor struct {
+	detectors []detector
+}
+
+// New returns a redactor of the kinds, all of them when none is given.
+func New(kinds ...Kind) *Redactor {
+	if len(kinds) == 0 {
+		kinds = Kinds
+	}
+
+	r := &Redactor{}
+	for _, d := range detectors {
+		if slices.Contains(kinds, d.kind) {
+			r.detectors = append(r.detectors, d)
+		}
+	}
+	return r
+}
+
+// Find returns the personal data of s, in order and without overlaps.
+func (r *Redactor) Find(s string) []Match {
+	if r == nil {
+		return nil
+	}
+
+	var matches []Match
+	for _, d := range r.detectors {
+		for _, m := range d.find(s) {
+			overlaps := slices.ContainsFunc(matches, func(o Match) bool {
+				return m.Start < o.End && o.Start < m.End
+			})
+			if !overlaps {
+				matches = append(matches, m)
+			}
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b Match) int { return a.Start - b.Start })
+	return matches
+}
+
+// Mask replaces the personal data of s by its kind, e.g. [CARD], when it
+// doesn't need to be restored, as in logs.
+func (r *Redactor) Mask(s string) string {
+	return r.replace(s, func(m Match, value string) string {
+		return "[" + strings.ToUpper(string(m.Kind)) + "]"
+	})
+}
+
+func (r *Redactor) replace(s string, fn func(m Match, value string) string) string {
+	matches := r.Find(s)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m.Start])
+		b.WriteString(fn(m, s[m.Start:m.End]))
+		last = m.End
+	}
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+// placeholder matches the placeholders of Vault.Redact.
+var placeholder = regexp.MustCompile(`\[(?:EMAIL|IBAN|CARD|PASSPORT|PHONE)_\d+\]`)
+
+// Vault replaces personal data by numbered placeholders, e.g. [CARD_1], and
+// remembers them to restore the data in texts referring to them, e.g. the
+// reply of the model. The same value always gets the same placeholder. A
+// vault isn't safe for concurrent use, a nil Vault redacts nothing.
+type Vault struct {
+	redactor *Redactor
+	values   map[string]string // by placeholder
+	ids      map[string]string // placeholders by kind and value
+	counts   map[Kind]int
+}
+
+// NewVault returns an empty vault redacting what r finds.
+func (r *Redactor) NewVault() *Vault {
+	return &Vault{redactor: r, values: map[string]string{}, ids: map[string]string{}, counts: map[Kind]int{}}
+}
+
+// Redact replaces the personal data of s by placeholders.
+func (v *Vault) Redact(s string) string {
+	if v == nil {
+		return s
+	}
+
+	return v.redactor.replace(s, func(m Match, value string) string {
+		key := string(m.Kind) + ":" + value
+		if id, ok := v.ids[key]; ok {
+			return id
+		}
+
+		v.counts[m.Kind]++
+		id := fmt.Sprintf("[%s_%d]", strings.ToUpper(string(m.Kind)), v.counts[m.Kind])
+		v.ids[key], v.values[id] = id, value
+		return id
+	})
+}
+
+// Restore replaces the placeholders of s by the data they stand for.
+// Unknown placeholders are left as is.
+func (v *Vault) Restore(s string) string {
+	if v == nil || len(v.values) == 0 {
+		return s
+	}
+
+	return placeholder.ReplaceAllStringFunc(s, func(id string) string {
+		if value, ok := v.values[id]; ok {
+			return value
+		}
+		return id
+	})
+}
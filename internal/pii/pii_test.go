@@ -0,0 +1,98 @@
+package pii
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactor_Mask(t *testing.T) {
+	r := New()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"email", "Write to jane.doe+trips@example.co.uk please", "Write to [EMAIL] please"},
+		{"card with spaces", "My card is 4111 1111 1111 1111.", "My card is [CARD]."},
+		{"card failing the checksum", "Order 4111 1111 1111 1112 shipped", "Order 4111 1111 1111 1112 shipped"},
+		{"iban followed by a word", "Pay to ES91 2100 0418 4502 0005 1332 I think", "Pay to [IBAN] I think"},
+		{"iban failing the checksum", "GB00 WEST 1234 5698 7654 32", "GB00 WEST 1234 5698 7654 32"},
+		{"passport", "Passport AB1234567, expires in May", "Passport [PASSPORT], expires in May"},
+		{"dni and nie", "DNI 12345678Z or NIE X1234567L", "DNI [PASSPORT] or NIE [PASSPORT]"},
+		{"dni with a wrong letter", "DNI 12345678A", "DNI 12345678A"},
+		{"phone", "Call me at +34 612 345 678 tomorrow", "Call me at [PHONE] tomorrow"},
+		{"national phone", "Call 612 345 678 or 91-234-56-78", "Call [PHONE] or [PHONE]"},
+		{"travel details", "Flight IB3456 on 2025-06-30 10:30 costs 1250 EUR", "Flight IB3456 on 2025-06-30 10:30 costs 1250 EUR"},
+		{"date range", "Dates: 15.06.2025 - 20.06.2025", "Dates: 15.06.2025 - 20.06.2025"},
+		{"date range without spaces", "Dates: 15.06.2025-20.06.2025", "Dates: 15.06.2025-20.06.2025"},
+		{"dashed dates", "From 15-06-2025 to 20-06-2025", "From 15-06-2025 to 20-06-2025"},
+		{"order number and timestamp", "Order 123456789 created at 1718000000", "Order 123456789 created at 1718000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Mask(tt.in); got != tt.want {
+				t.Errorf("Mask(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("only the kinds of the redactor", func(t *testing.T) {
+		in := "jane@example.com, +34 612 345 678"
+		if got, want := New(KindEmail).Mask(in), "[EMAIL], +34 612 345 678"; got != want {
+			t.Errorf("Mask(%q) = %q, want %q", in, got, want)
+		}
+	})
+
+	t.Run("nil redactor", func(t *testing.T) {
+		var r *Redactor
+		if got := r.Mask("jane@example.com"); got != "jane@example.com" {
+			t.Errorf("expected nothing masked, got %q", got)
+		}
+	})
+}
+
+func TestVault(t *testing.T) {
+	v := New().NewVault()
+
+	redacted := v.Redact("Book for jane@example.com, card 4111 1111 1111 1111, cc jane@example.com and bob@example.com")
+	want := "Book for [EMAIL_1], card [CARD_1], cc [EMAIL_1] and [EMAIL_2]"
+	if redacted != want {
+		t.Fatalf("Redact() = %q, want %q", redacted, want)
+	}
+
+	reply := "Booked, the confirmation goes to [EMAIL_2] and [EMAIL_3] was charged to [CARD_1]"
+	got := v.Restore(reply)
+	want = "Booked, the confirmation goes to bob@example.com and [EMAIL_3] was charged to 4111 1111 1111 1111"
+	if got != want {
+		t.Errorf("Restore() = %q, want %q", got, want)
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(LogHandler(slog.NewTextHandler(&buf, nil), New())).
+		With("user", "jane@example.com")
+
+	logger.Info("Tool call received for +34 612 345 678",
+		"args", `{"card": "4111 1111 1111 1111"}`,
+		"conversation_id", "650000000012abcdef012345",
+		slog.Group("passenger", "passport", "AB1234567"),
+		"error", errors.New("IBAN ES91 2100 0418 4502 0005 1332 rejected"),
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"jane@example.com", "612 345 678", "4111", "AB1234567", "ES91"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %s to be masked, got:\n%s", secret, out)
+		}
+	}
+
+	if !strings.Contains(out, "650000000012abcdef012345") {
+		t.Errorf("expected IDs to be kept, got:\n%s", out)
+	}
+}
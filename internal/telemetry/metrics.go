@@ -3,50 +3,147 @@ package telemetry
 import (
 	"context"
 	"fmt"
-	"time"
+	"net/http"
+	"path"
+	"runtime/debug"
 
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
-// InitMetrics initializes OpenTelemetry metrics with a simple stdout exporter
-func InitMetrics(ctx context.Context) (func(context.Context) error, error) {
-	// Create a stdout exporter for simplicity
-	exporter, err := stdoutmetric.New(
-		stdoutmetric.WithPrettyPrint(), // Human-readable output
-	)
+// MetricsPath is the path Prometheus scrapes metrics from.
+const MetricsPath = "/metrics"
+
+// InitMetrics initializes OpenTelemetry metrics, exporting them with the
+// named exporter:
+//   - prometheus: scraped by Prometheus from the handler returned,
+//     to be served on MetricsPath
+//   - otlp: pushed to an OTLP/HTTP collector, configured with the standard
+//     OTEL_EXPORTER_OTLP_* variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT
+//   - stdout: as JSON to stdout
+//   - none: nowhere
+//
+// Pushing exporters export every OTEL_METRIC_EXPORT_INTERVAL milliseconds,
+// 60s by default. The handler is nil unless metrics are scraped.
+func InitMetrics(ctx context.Context, name string) (http.Handler, func(context.Context) error, error) {
+	res, err := newResource(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		return nil, nil, err
+	}
+
+	opts := []metric.Option{metric.WithResource(res)}
+	var handler http.Handler
+
+	switch name {
+	case "prometheus":
+		// A registry of our own, so only the metrics of this service are served
+		registry := promclient.NewRegistry()
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+
+		exporter, err := prometheus.New(prometheus.WithRegisterer(registry))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create Prometheus exporter: %w", err)
+		}
+		opts = append(opts, metric.WithReader(exporter))
+		handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+	case "otlp":
+		exporter, err := otlpmetrichttp.New(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+		opts = append(opts, metric.WithReader(metric.NewPeriodicReader(exporter)))
+
+	case "stdout":
+		exporter, err := stdoutmetric.New()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stdout metric exporter: %w", err)
+		}
+		opts = append(opts, metric.WithReader(metric.NewPeriodicReader(exporter)))
+
+	case "none":
+
+	default:
+		return nil, nil, fmt.Errorf("unknown metrics exporter %q, use prometheus, otlp, stdout or none", name)
 	}
 
-	// Create resource to identify this service
+	meterProvider := metric.NewMeterProvider(opts...)
+	otel.SetMeterProvider(meterProvider)
+
+	return handler, meterProvider.Shutdown, nil
+}
+
+// newResource identifies this service in metrics and traces, by the name of
+// its binary and the version it was built from. OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES take precedence.
+func newResource(ctx context.Context) (*resource.Resource, error) {
+	name, version := buildInfo()
+
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
-			semconv.ServiceName("acai-chat-service"),
-			semconv.ServiceVersion("1.0.0"),
+			semconv.ServiceName(name),
+			semconv.ServiceVersion(version),
 		),
+		resource.WithFromEnv(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Create meter provider with periodic reader
-	meterProvider := metric.NewMeterProvider(
-		metric.WithResource(res),
-		metric.WithReader(
-			metric.NewPeriodicReader(
-				exporter,
-				metric.WithInterval(10*time.Second), // Export every 10 seconds
-			),
-		),
-	)
+	return res, nil
+}
 
-	// Set global meter provider
-	otel.SetMeterProvider(meterProvider)
+// buildInfo returns the service name and version from the build info of the
+// binary, e.g. acai-server and v1.2.0, or the VCS revision it was built from
+// for development builds.
+func buildInfo() (name, version string) {
+	name, version = "acai-chat-service", "devel"
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return name, version
+	}
+
+	// The path of the main package, e.g. .../cmd/server, tests have none
+	if info.Path != "" {
+		name = "acai-" + path.Base(info.Path)
+	}
+
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return name, v
+	}
+
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+
+	if revision == "" {
+		return name, version
+	}
+
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
 
-	// Return shutdown function
-	return meterProvider.Shutdown, nil
+	return name, revision
 }
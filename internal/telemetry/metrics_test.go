@@ -0,0 +1,55 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestInitMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("serves Prometheus metrics", func(t *testing.T) {
+		handler, shutdown, err := InitMetrics(ctx, "prometheus")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer shutdown(ctx)
+
+		counter, _ := otel.Meter("test").Int64Counter("test.requests")
+		counter.Add(ctx, 3)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+
+		body := w.Body.String()
+		if w.Code != http.StatusOK || !strings.Contains(body, "test_requests_total") {
+			t.Fatalf("expected the counter to be served, got %d %s", w.Code, body)
+		}
+		if !strings.Contains(body, `service_name="acai-`) {
+			t.Errorf("expected the service name in target_info, got %s", body)
+		}
+	})
+
+	t.Run("has no handler when metrics are not scraped", func(t *testing.T) {
+		handler, shutdown, err := InitMetrics(ctx, "none")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer shutdown(ctx)
+
+		if handler != nil {
+			t.Error("expected no handler")
+		}
+	})
+
+	t.Run("rejects unknown exporters", func(t *testing.T) {
+		if _, _, err := InitMetrics(ctx, "statsd"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/twitchtv/twirp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// twirpSpanKey holds the span of the method, requests failing before they are
+// routed to a method have none.
+type twirpSpanKey struct{}
+
+// TwirpTracing returns server hooks tracing each Twirp method call with a
+// span named after the service and method, e.g. acai.chat.ChatService/StartConversation.
+func TwirpTracing() *twirp.ServerHooks {
+	tracer := otel.Tracer("acai.chat.twirp")
+
+	return &twirp.ServerHooks{
+		RequestRouted: func(ctx context.Context) (context.Context, error) {
+			pkg, _ := twirp.PackageName(ctx)
+			service, _ := twirp.ServiceName(ctx)
+			method, _ := twirp.MethodName(ctx)
+
+			if pkg != "" {
+				service = pkg + "." + service
+			}
+
+			// A child of the span of the HTTP request, see httpx.Tracing
+			ctx, span := tracer.Start(ctx, service+"/"+method,
+				trace.WithAttributes(
+					attribute.String("rpc.system", "twirp"),
+					attribute.String("rpc.service", service),
+					attribute.String("rpc.method", method),
+				),
+			)
+
+			return context.WithValue(ctx, twirpSpanKey{}, span), nil
+		},
+
+		Error: func(ctx context.Context, err twirp.Error) context.Context {
+			span, ok := ctx.Value(twirpSpanKey{}).(trace.Span)
+			if !ok {
+				return ctx
+			}
+
+			span.SetAttributes(attribute.String("rpc.twirp.error_code", string(err.Code())))
+
+			// Client errors are the caller's, only server errors fail the span
+			if twirp.ServerHTTPStatusFromErrorCode(err.Code()) >= 500 {
+				span.SetStatus(codes.Error, err.Msg())
+				span.RecordError(err)
+			}
+
+			return ctx
+		},
+
+		ResponseSent: func(ctx context.Context) {
+			span, ok := ctx.Value(twirpSpanKey{}).(trace.Span)
+			if !ok {
+				return
+			}
+
+			if status, ok := twirp.StatusCode(ctx); ok {
+				span.SetAttributes(attribute.String("http.response.status_code", status))
+			}
+			span.End()
+		},
+	}
+}
+
+// twirpCallKey holds the twirpCall of the request.
+type twirpCallKey struct{}
+
+// twirpCall is what is known of a Twirp request as it is handled.
+type twirpCall struct {
+	start time.Time
+	code  twirp.ErrorCode
+}
+
+// TwirpMetrics returns server hooks counting Twirp requests and recording
+// their duration by service, method and twirp error code, which is empty for
+// successful requests. Requests for unknown methods are recorded under the
+// unknown method.
+func TwirpMetrics() (*twirp.ServerHooks, error) {
+	meter := otel.Meter("acai.chat.twirp")
+
+	// Total number of Twirp requests
+	requestCounter, err := meter.Int64Counter(
+		"rpc.server.request.count",
+		metric.WithDescription("Total number of Twirp requests by method and error code"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create twirp request counter: %w", err)
+	}
+
+	// Twirp request duration in seconds
+	requestDuration, err := meter.Float64Histogram(
+		"rpc.server.duration",
+		metric.WithDescription("Duration of Twirp requests by method and error code"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create twirp request duration histogram: %w", err)
+	}
+
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			return context.WithValue(ctx, twirpCallKey{}, &twirpCall{start: time.Now()}), nil
+		},
+
+		Error: func(ctx context.Context, err twirp.Error) context.Context {
+			if call, ok := ctx.Value(twirpCallKey{}).(*twirpCall); ok {
+				call.code = err.Code()
+			}
+			return ctx
+		},
+
+		ResponseSent: func(ctx context.Context) {
+			call, ok := ctx.Value(twirpCallKey{}).(*twirpCall)
+			if !ok {
+				return
+			}
+
+			service, _ := twirp.ServiceName(ctx)
+			if pkg, _ := twirp.PackageName(ctx); pkg != "" {
+				service = pkg + "." + service
+			}
+
+			method, ok := twirp.MethodName(ctx)
+			if !ok {
+				method = "unknown"
+			}
+
+			attrs := metric.WithAttributes(
+				attribute.String("rpc.system", "twirp"),
+				attribute.String("rpc.service", service),
+				attribute.String("rpc.method", method),
+				attribute.String("rpc.twirp.error_code", string(call.code)),
+			)
+
+			requestCounter.Add(ctx, 1, attrs)
+			requestDuration.Record(ctx, time.Since(call.start).Seconds(), attrs)
+		},
+	}, nil
+}
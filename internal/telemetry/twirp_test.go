@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/twitchtv/twirp"
+	"github.com/twitchtv/twirp/ctxsetters"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestTwirpMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(prev) })
+
+	hooks, err := TwirpMetrics()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	call := func(method string, err twirp.Error) {
+		ctx := ctxsetters.WithPackageName(context.Background(), "acai.chat")
+		ctx = ctxsetters.WithServiceName(ctx, "ChatService")
+
+		ctx, _ = hooks.RequestReceived(ctx)
+		if method != "" {
+			ctx = ctxsetters.WithMethodName(ctx, method)
+		}
+		if err != nil {
+			ctx = hooks.Error(ctx, err)
+		}
+		hooks.ResponseSent(ctx)
+	}
+
+	call("StartConversation", nil)
+	call("StartConversation", twirp.NewError(twirp.Unavailable, "down"))
+	call("ContinueConversation", twirp.NotFoundError("conversation not found"))
+	call("", twirp.NewError(twirp.BadRoute, "no such method"))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	got := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+
+			for _, dp := range sum.DataPoints {
+				service, _ := dp.Attributes.Value("rpc.service")
+				method, _ := dp.Attributes.Value("rpc.method")
+				code, _ := dp.Attributes.Value("rpc.twirp.error_code")
+				got[service.AsString()+"/"+method.AsString()+":"+code.AsString()] += dp.Value
+			}
+		}
+	}
+
+	for key, want := range map[string]int64{
+		"acai.chat.ChatService/StartConversation:":             1,
+		"acai.chat.ChatService/StartConversation:unavailable":  1,
+		"acai.chat.ChatService/ContinueConversation:not_found": 1,
+		"acai.chat.ChatService/unknown:bad_route":              1,
+	} {
+		if got[key] != want {
+			t.Errorf("got %d requests for %s, want %d (all: %v)", got[key], key, want, got)
+		}
+	}
+}
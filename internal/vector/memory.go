@@ -0,0 +1,107 @@
+package vector
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is an in-process Store doing an exhaustive (brute-force)
+// search. It needs no external service and is fast enough for tens of
+// thousands of records, but its content is lost on restart.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	namespaces map[string]map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{namespaces: map[string]map[string]Record{}}
+}
+
+func (s *MemoryStore) Upsert(ctx context.Context, namespace string, records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, ok := s.namespaces[namespace]
+	if !ok {
+		ns = map[string]Record{}
+		s.namespaces[namespace] = ns
+	}
+
+	for _, r := range records {
+		// Vectors are stored normalized, so queries only need a dot product
+		r.Vector = Normalize(r.Vector)
+		ns[r.ID] = r
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Query(ctx context.Context, namespace string, vector []float32, k int, filter Filter) ([]Match, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if k <= 0 {
+		return nil, nil
+	}
+
+	query := Normalize(vector)
+
+	var matches []Match
+	for _, r := range s.namespaces[namespace] {
+		if !filter.Matches(r) || len(r.Vector) != len(query) {
+			continue
+		}
+
+		var score float64
+		for i := range query {
+			score += float64(query[i]) * float64(r.Vector[i])
+		}
+
+		matches = append(matches, Match{Record: r, Score: score})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+
+	return matches, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, namespace string, filter Filter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, r := range s.namespaces[namespace] {
+		if filter.Matches(r) {
+			delete(s.namespaces[namespace], id)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Values(ctx context.Context, namespace, key string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var values []string
+	for _, r := range s.namespaces[namespace] {
+		if v, ok := r.Metadata[key]; ok && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+
+	return values, nil
+}
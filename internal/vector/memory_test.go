@@ -0,0 +1,101 @@
+package vector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func ids(matches []Match) []string {
+	out := make([]string, len(matches))
+	for i, m := range matches {
+		out[i] = m.ID
+	}
+	return out
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	newStore := func(t *testing.T) *MemoryStore {
+		s := NewMemoryStore()
+		err := s.Upsert(ctx, "messages",
+			Record{ID: "a", Vector: []float32{1, 0}, Metadata: map[string]string{"owner": "jane"}},
+			Record{ID: "b", Vector: []float32{2, 2}, Metadata: map[string]string{"owner": "jane"}},
+			Record{ID: "c", Vector: []float32{0, 3}, Metadata: map[string]string{"owner": "bob"}},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return s
+	}
+
+	t.Run("query the most similar records first", func(t *testing.T) {
+		s := newStore(t)
+
+		matches, err := s.Query(ctx, "messages", []float32{1, 0.1}, 2, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if diff := cmp.Diff([]string{"a", "b"}, ids(matches)); diff != "" {
+			t.Errorf("unexpected matches (-want +got):\n%s", diff)
+		}
+		if matches[0].Score < 0.99 || matches[0].Score > 1 {
+			t.Errorf("expected a cosine similarity close to 1, got %f", matches[0].Score)
+		}
+	})
+
+	t.Run("filter queries by metadata and namespace", func(t *testing.T) {
+		s := newStore(t)
+
+		matches, err := s.Query(ctx, "messages", []float32{0, 1}, 10, Filter{"owner": "jane"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff([]string{"b", "a"}, ids(matches)); diff != "" {
+			t.Errorf("unexpected matches (-want +got):\n%s", diff)
+		}
+
+		matches, err = s.Query(ctx, "documents", []float32{0, 1}, 10, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(matches) != 0 {
+			t.Errorf("expected no matches in another namespace, got %v", ids(matches))
+		}
+	})
+
+	t.Run("replace records with the same ID", func(t *testing.T) {
+		s := newStore(t)
+
+		if err := s.Upsert(ctx, "messages", Record{ID: "a", Vector: []float32{0, 1}, Text: "updated"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		matches, err := s.Query(ctx, "messages", []float32{0, 1}, 1, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(matches) != 1 || matches[0].ID != "a" || matches[0].Text != "updated" {
+			t.Errorf("expected the updated record first, got %+v", matches)
+		}
+	})
+
+	t.Run("delete records matching the filter", func(t *testing.T) {
+		s := newStore(t)
+
+		if err := s.Delete(ctx, "messages", Filter{"owner": "jane"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		matches, err := s.Query(ctx, "messages", []float32{1, 1}, 10, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff([]string{"c"}, ids(matches)); diff != "" {
+			t.Errorf("unexpected matches (-want +got):\n%s", diff)
+		}
+	})
+}
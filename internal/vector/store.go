@@ -0,0 +1,74 @@
+// Package vector stores embeddings and finds the nearest ones to a query.
+package vector
+
+import (
+	"context"
+	"math"
+)
+
+// Record is an embedded piece of text. Metadata holds the attributes used to
+// filter queries and to link the record back to its source.
+type Record struct {
+	ID       string
+	Vector   []float32
+	Text     string
+	Metadata map[string]string
+}
+
+// Match is a record returned by a query, with its cosine similarity to the
+// query vector.
+type Match struct {
+	Record
+	Score float64
+}
+
+// Filter restricts queries and deletes to records whose metadata contains all
+// the given key/value pairs.
+type Filter map[string]string
+
+func (f Filter) Matches(r Record) bool {
+	for k, v := range f {
+		if r.Metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Store persists records in namespaces, so unrelated collections (for
+// example messages and documents) never show up in each other's queries.
+type Store interface {
+	// Upsert adds the records, replacing records with the same ID.
+	Upsert(ctx context.Context, namespace string, records ...Record) error
+
+	// Query returns up to k records most similar to the vector, best first.
+	Query(ctx context.Context, namespace string, vector []float32, k int, filter Filter) ([]Match, error)
+
+	// Delete removes all records matching the filter.
+	Delete(ctx context.Context, namespace string, filter Filter) error
+
+	// Values returns the distinct values of the metadata key, e.g. the
+	// sources of the records, in no particular order.
+	Values(ctx context.Context, namespace, key string) ([]string, error)
+}
+
+// Normalize scales the vector to unit length, so cosine similarity becomes a
+// dot product.
+func Normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+
+	if sum == 0 {
+		return v
+	}
+
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+
+	return out
+}